@@ -5,6 +5,7 @@ import (
 	"flag"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -12,8 +13,10 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/sbeyeler/loxone2hue/internal/api"
 	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/history"
 	"github.com/sbeyeler/loxone2hue/internal/hue"
 	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/stats"
 )
 
 var (
@@ -25,6 +28,8 @@ func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "configs/config.yaml", "Path to configuration file")
 	showVersion := flag.Bool("version", false, "Show version information")
+	ingress := flag.Bool("ingress", false, "Enable Home Assistant ingress support")
+	loxonePort := flag.Int("loxone-port", 0, "Port of the token protected Loxone listener (overrides server.ingress.loxone_port)")
 	flag.Parse()
 
 	if *showVersion {
@@ -41,6 +46,17 @@ func main() {
 	// Setup logging
 	setupLogging(cfg.Logging.Level, cfg.Logging.Format)
 
+	// The add-on start script enables ingress independent of the stored config
+	applyFlags := func(c *config.Config) {
+		if *ingress {
+			c.Server.Ingress.Enabled = true
+		}
+		if *loxonePort > 0 {
+			c.Server.Ingress.LoxonePort = *loxonePort
+		}
+	}
+	applyFlags(cfg)
+
 	log.Info().
 		Str("version", version).
 		Str("config", *configPath).
@@ -48,10 +64,23 @@ func main() {
 
 	// Create HUE client
 	hueClient := hue.NewClient(cfg.Hue.BridgeIP, cfg.Hue.ApplicationKey)
+	hueClient.SetClientKey(cfg.Hue.ClientKey)
+
+	// Additional bridges are only used by mappings that reference them
+	bridges := hue.NewRegistry(hueClient)
+	for _, b := range cfg.Hue.Bridges {
+		client := hue.NewClient(b.BridgeIP, b.ApplicationKey)
+		bridges.Add(b.ID, client)
+		if client.IsConfigured() {
+			log.Info().Str("bridge", b.ID).Str("bridge_ip", b.BridgeIP).Msg("Additional HUE Bridge configured, starting event stream")
+			go client.StartEventStream(context.Background())
+		}
+	}
 
 	// Create mapping manager
 	mappingManager := loxone.NewMappingManager()
 	mappingManager.Load(cfg.Mappings)
+	mappingManager.LoadMoodTables(cfg.MoodTables)
 
 	// If HUE is configured, start event stream
 	if hueClient.IsConfigured() {
@@ -62,12 +91,39 @@ func main() {
 	}
 
 	// Create API server
-	server := api.NewServer(hueClient, mappingManager)
+	server := api.NewServer(bridges, mappingManager)
+
+	// Audit log of commands and HUE state changes
+	historyPath := filepath.Join(config.Dir(), "history.jsonl")
+	auditLog, err := history.Open(historyPath, cfg.History.MaxEntries)
+	if err != nil {
+		log.Error().Err(err).Str("path", historyPath).Msg("Failed to open history, audit log disabled")
+	} else {
+		server.SetHistory(auditLog)
+	}
+
+	// Light usage statistics
+	statsPath := filepath.Join(config.Dir(), "stats.db")
+	usageStats, err := stats.Open(statsPath, cfg.Stats.RetentionDays)
+	if err != nil {
+		log.Error().Err(err).Str("path", statsPath).Msg("Failed to open statistics, usage statistics disabled")
+	} else {
+		server.SetStats(usageStats)
+	}
 
 	// Setup context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Optionally follow the Miniserver control states directly
+	reload := &reloader{ctx: ctx, server: server, bridges: bridges, mappingManager: mappingManager}
+	reload.startMiniserver(cfg.Loxone)
+
+	// Apply changes of the config file without a restart
+	watcher := config.NewWatcher(2*time.Second, applyFlags, reload.apply)
+	server.SetConfigWatcher(watcher)
+	go watcher.Run(ctx)
+
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -82,14 +138,24 @@ func main() {
 	log.Info().
 		Str("host", cfg.Server.Host).
 		Int("port", cfg.Server.Port).
+		Bool("tls", cfg.Server.TLS.Enabled).
 		Msg("Starting HTTP server")
 
-	if err := server.Start(ctx, cfg.Server.Host, cfg.Server.Port); err != nil {
+	if err := server.Start(ctx, cfg.Server); err != nil {
 		log.Error().Err(err).Msg("Server error")
 	}
 
 	// Cleanup
-	hueClient.Close()
+	if reload.miniserver != nil {
+		reload.miniserver.Close()
+	}
+	bridges.Close()
+	if auditLog != nil {
+		auditLog.Close()
+	}
+	if usageStats != nil {
+		usageStats.Close()
+	}
 	log.Info().Msg("Loxone2HUE Gateway stopped")
 }
 
@@ -114,6 +180,8 @@ func setupLogging(level, format string) {
 			Out:        os.Stdout,
 			TimeFormat: time.RFC3339,
 		})
+	} else {
+		// Default is JSON format (zerolog default)
+		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
 	}
-	// Default is JSON format (zerolog default)
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/api"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+)
+
+// reloader applies changes of the config file to the running gateway
+type reloader struct {
+	ctx            context.Context
+	server         *api.Server
+	bridges        *hue.Registry
+	mappingManager *loxone.MappingManager
+	miniserver     *loxone.MiniserverClient
+}
+
+// apply reloads everything that can change at runtime and logs the
+// settings that need a restart
+func (r *reloader) apply(old, next *config.Config) {
+	if !reflect.DeepEqual(old.Mappings, next.Mappings) {
+		conflicts := r.mappingManager.Load(next.Mappings)
+		log.Info().Int("mappings", len(next.Mappings)).Int("conflicts", len(conflicts)).Msg("Mappings reloaded")
+	}
+	if !reflect.DeepEqual(old.MoodTables, next.MoodTables) {
+		r.mappingManager.LoadMoodTables(next.MoodTables)
+		log.Info().Int("mood_tables", len(next.MoodTables)).Msg("Mood tables reloaded")
+	}
+
+	if old.Logging != next.Logging {
+		setupLogging(next.Logging.Level, next.Logging.Format)
+		log.Info().Str("level", next.Logging.Level).Str("format", next.Logging.Format).Msg("Logging reconfigured")
+	}
+
+	r.applyBridges(old.Hue, next.Hue)
+
+	if old.Loxone.WebSocket != next.Loxone.WebSocket || old.Loxone.MiniserverIP != next.Loxone.MiniserverIP ||
+		old.Loxone.Username != next.Loxone.Username || old.Loxone.Password != next.Loxone.Password {
+		r.startMiniserver(next.Loxone)
+	}
+
+	// Listeners and stores are only set up on start
+	restart := make([]string, 0)
+	if !reflect.DeepEqual(old.Server, next.Server) {
+		restart = append(restart, "server")
+	}
+	if old.History != next.History {
+		restart = append(restart, "history")
+	}
+	if old.Stats.RetentionDays != next.Stats.RetentionDays {
+		restart = append(restart, "stats.retention_days")
+	}
+	if len(restart) > 0 {
+		log.Warn().Strs("settings", restart).Msg("Changed settings take effect after a restart")
+	}
+}
+
+// applyBridges updates the bridge clients and reconnects their event streams
+func (r *reloader) applyBridges(old, next config.HueConfig) {
+	if old.BridgeIP != next.BridgeIP || old.ApplicationKey != next.ApplicationKey || old.ClientKey != next.ClientKey {
+		r.updateBridge("", r.bridges.Primary(), next.BridgeIP, next.ApplicationKey)
+		r.bridges.Primary().SetClientKey(next.ClientKey)
+	}
+
+	previous := make(map[string]config.BridgeConfig, len(old.Bridges))
+	for _, b := range old.Bridges {
+		previous[b.ID] = b
+	}
+	for _, b := range next.Bridges {
+		before, existed := previous[b.ID]
+		delete(previous, b.ID)
+		if existed && before == b {
+			continue
+		}
+
+		client, err := r.bridges.Get(b.ID)
+		if err != nil {
+			// The hub forwards events only of bridges known on start
+			log.Warn().Str("bridge", b.ID).Msg("New HUE bridge is used after a restart")
+			continue
+		}
+		r.updateBridge(b.ID, client, b.BridgeIP, b.ApplicationKey)
+	}
+	for id := range previous {
+		log.Warn().Str("bridge", id).Msg("Removed HUE bridge stays connected until a restart")
+	}
+}
+
+// updateBridge points a client to a new address or key and restarts its
+// event stream
+func (r *reloader) updateBridge(id string, client *hue.Client, bridgeIP, applicationKey string) {
+	client.SetBridgeIP(bridgeIP)
+	client.SetApplicationKey(applicationKey)
+	log.Info().Str("bridge", id).Str("bridge_ip", bridgeIP).Msg("HUE bridge settings changed")
+
+	if !client.IsConfigured() {
+		return
+	}
+	if client.EventStreamStatus().Started {
+		client.ReconnectEventStream()
+	} else {
+		client.StartEventStream(r.ctx)
+	}
+}
+
+// startMiniserver replaces the Miniserver connection according to the
+// Loxone settings, closing the previous one
+func (r *reloader) startMiniserver(cfg config.LoxoneConfig) {
+	if r.miniserver != nil {
+		r.server.SetMiniserver(nil)
+		r.miniserver.Close()
+		r.miniserver = nil
+	}
+	if !cfg.WebSocket || cfg.MiniserverIP == "" {
+		return
+	}
+
+	log.Info().Str("miniserver_ip", cfg.MiniserverIP).Msg("Connecting to Loxone Miniserver WebSocket")
+	r.miniserver = loxone.NewMiniserverClient(cfg.MiniserverIP, cfg.Username, cfg.Password, r.mappingManager)
+	r.server.SetMiniserver(r.miniserver)
+	r.miniserver.Start(r.ctx)
+}
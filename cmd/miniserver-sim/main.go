@@ -0,0 +1,49 @@
+// Command miniserver-sim is a stand-in for a Loxone Miniserver. It speaks the
+// parts of the Miniserver WebSocket protocol the gateway uses (key exchange,
+// token authentication, structure file, binary status updates) so the
+// loxone.websocket integration can be tried without real hardware.
+//
+// State changes are triggered over HTTP:
+//
+//	curl -X POST 'localhost:8081/sim/value?uuid=<state-uuid>&value=80'
+//	curl -X POST 'localhost:8081/sim/text?uuid=<state-uuid>&text=hsv(120,100,50)'
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/loxone/loxonetest"
+)
+
+func main() {
+	listen := flag.String("listen", ":8081", "Listen address")
+	username := flag.String("user", "admin", "Miniserver user")
+	password := flag.String("password", "admin", "Miniserver password")
+	structurePath := flag.String("structure", "", "LoxAPP3.json to serve (default: built-in sample)")
+	flag.Parse()
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339})
+
+	var structure []byte
+	if *structurePath != "" {
+		var err error
+		if structure, err = os.ReadFile(*structurePath); err != nil {
+			log.Fatal().Err(err).Msg("Failed to read structure file")
+		}
+	}
+
+	sim, err := loxonetest.NewSimulator(*username, *password, structure)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create simulator")
+	}
+
+	log.Info().Str("listen", *listen).Str("user", *username).Msg("Miniserver simulator started")
+	if err := http.ListenAndServe(*listen, sim.Handler()); err != nil {
+		log.Fatal().Err(err).Msg("Server error")
+	}
+}
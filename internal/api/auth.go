@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+)
+
+// API token roles, each role includes the rights of the previous ones
+const (
+	RoleRead    = "read"
+	RoleControl = "control"
+	RoleAdmin   = "admin"
+)
+
+var roleLevels = map[string]int{RoleRead: 1, RoleControl: 2, RoleAdmin: 3}
+
+// tokenPrefix marks gateway API tokens
+const tokenPrefix = "l2h_"
+
+// publicRoutes need no token
+var publicRoutes = map[string]bool{
+	"/api/health":       true,
+	"/api/health/live":  true,
+	"/api/health/ready": true,
+	"/api/auth":         true,
+	"/api/swagger.json": true,
+	"/api/swagger":      true,
+	"/api/swagger/":     true,
+}
+
+// controlRoutes switch lights but do not change the configuration. Other
+// write requests need the admin role, read requests the read role. The
+// WebSocket only needs the read role, its commands are checked per message.
+var controlRoutes = map[string]bool{
+	"/api/devices/{id}":              true,
+	"/api/groups/{id}":               true,
+	"/api/scenes/{id}/activate":      true,
+	"/api/entertainment/stream":      true,
+	"/api/entertainment/{id}/stream": true,
+}
+
+// adminReadRoutes expose data only admins may read
+var adminReadRoutes = map[string]bool{
+	"/api/auth/tokens": true,
+}
+
+// requiredRole returns the role needed for a request, empty for public routes
+func requiredRole(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			path = template
+		}
+	}
+
+	if publicRoutes[path] || (!strings.HasPrefix(path, "/api") && path != "/ws" && path != "/metrics") {
+		return ""
+	}
+	if path == "/ws" && r.URL.Query().Get("cmd") == "" {
+		return RoleRead
+	}
+	if controlRoutes[path] || path == "/ws" {
+		return RoleControl
+	}
+	if adminReadRoutes[path] {
+		return RoleAdmin
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return RoleRead
+	}
+	return RoleAdmin
+}
+
+// tokenRoleKey holds the role of the token a request was authenticated with
+type tokenRoleKey struct{}
+
+// canControl reports whether a request may switch lights. Requests that
+// needed no token, e.g. through ingress, have full access.
+func canControl(r *http.Request) bool {
+	role, ok := r.Context().Value(tokenRoleKey{}).(string)
+	return !ok || roleLevels[role] >= roleLevels[RoleControl]
+}
+
+// hashToken returns the stored form of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestToken reads the token from the Authorization header or, for Loxone
+// virtual outputs and browser WebSockets, from the token query parameter.
+// Basic auth with the token as password is accepted for Loxone addresses like
+// http://loxone:<token>@gateway:8081.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return r.URL.Query().Get("token")
+}
+
+// findToken returns the configured token matching the presented one
+func findToken(tokens []config.APIToken, presented string) *config.APIToken {
+	if presented == "" {
+		return nil
+	}
+	hash := hashToken(presented)
+	for i := range tokens {
+		if tokens[i].Hash == hash {
+			return &tokens[i]
+		}
+	}
+	return nil
+}
+
+// crossSiteRequest reports whether a browser sent the request from a page
+// of another site. Clients without Origin and Sec-Fetch-Site headers such as
+// the Miniserver are not affected.
+func crossSiteRequest(r *http.Request, allowed []string) bool {
+	if isIngress(r) {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return !originAllowed(origin, r.Host, allowed)
+	}
+	// Browsers omit Origin on simple GET requests like <img src="/ws?cmd=...">
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		for _, a := range allowed {
+			if a == "*" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// authMiddleware checks the API token and its role. As long as no token
+// exists the API stays open so the first admin token can be created. Ingress
+// requests were authenticated by Home Assistant, the Loxone listener always
+// needs a token. With ingress the main port always needs a token as well,
+// the first one is created through ingress. Requests that switch lights or
+// change the configuration are refused from other sites, with or without
+// token.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := config.GetAuth()
+		role := requiredRole(r)
+		if (role == RoleControl || role == RoleAdmin) && crossSiteRequest(r, auth.AllowedOrigins) {
+			log.Warn().Str("origin", r.Header.Get("Origin")).Str("path", r.URL.Path).Msg("Cross-site request rejected")
+			errorResponse(w, http.StatusForbidden, "cross-site request not allowed, see auth.allowed_origins")
+			return
+		}
+		outside := isOutsideIngress(r)
+		enforced := auth.Enabled || isLoxonePort(r) || outside
+		if !enforced || role == "" || isIngress(r) || (len(auth.Tokens) == 0 && !outside) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := findToken(auth.Tokens, requestToken(r))
+		if token == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="loxone2hue"`)
+			errorResponse(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		if roleLevels[token.Role] < roleLevels[role] {
+			log.Warn().Str("token", token.Name).Str("path", r.URL.Path).Str("required", role).Msg("API token lacks permission")
+			errorResponse(w, http.StatusForbidden, "API token requires role "+role)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenRoleKey{}, token.Role)))
+	})
+}
+
+// originAllowed reports whether a browser origin may use the API. Requests
+// from the gateway's own host are always allowed.
+func originAllowed(origin, host string, allowed []string) bool {
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == host {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebSocketOrigin allows clients without Origin header (Loxone) and
+// browsers from the gateway itself, Home Assistant ingress or an allowed origin
+func checkWebSocketOrigin(r *http.Request) bool {
+	if isIngress(r) {
+		return true
+	}
+	return originAllowed(r.Header.Get("Origin"), r.Host, config.GetAuth().AllowedOrigins)
+}
+
+// GetAuthStatus returns whether authentication is active and the details of
+// the presented token
+func (h *Handlers) GetAuthStatus(w http.ResponseWriter, r *http.Request) {
+	auth := config.GetAuth()
+	status := map[string]interface{}{
+		"enabled": auth.Enabled,
+		"active":  auth.Enabled && len(auth.Tokens) > 0,
+	}
+	if token := findToken(auth.Tokens, requestToken(r)); token != nil {
+		status["token"] = token
+	}
+	jsonResponse(w, http.StatusOK, status)
+}
+
+// GetTokens lists the API tokens without their secrets
+func (h *Handlers) GetTokens(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, config.GetAuth().Tokens)
+}
+
+// CreateToken creates an API token. The token is only returned once.
+func (h *Handlers) CreateToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" {
+		errorResponse(w, http.StatusBadRequest, "name required")
+		return
+	}
+	if _, ok := roleLevels[req.Role]; !ok {
+		errorResponse(w, http.StatusBadRequest, "role must be read, control or admin")
+		return
+	}
+
+	tokens := config.GetAuth().Tokens
+	if len(tokens) == 0 && req.Role != RoleAdmin {
+		// Otherwise nobody could manage tokens once authentication is active
+		errorResponse(w, http.StatusBadRequest, "the first token must have the admin role")
+		return
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+	plain := tokenPrefix + hex.EncodeToString(secret)
+
+	token := config.APIToken{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Role:      req.Role,
+		Hash:      hashToken(plain),
+		Prefix:    plain[:len(tokenPrefix)+6],
+		CreatedAt: time.Now().UTC(),
+	}
+	config.UpdateTokens(append(tokens, token))
+
+	if err := config.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save config")
+		errorResponse(w, http.StatusInternalServerError, "failed to save config")
+		return
+	}
+
+	log.Info().Str("name", token.Name).Str("role", token.Role).Msg("API token created")
+	jsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"token":   plain,
+		"details": token,
+	})
+}
+
+// DeleteToken revokes an API token
+func (h *Handlers) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	tokens := config.GetAuth().Tokens
+	result := make([]config.APIToken, 0, len(tokens))
+	admins := 0
+	for _, t := range tokens {
+		if t.ID != id {
+			result = append(result, t)
+			if t.Role == RoleAdmin {
+				admins++
+			}
+		}
+	}
+	if len(result) == len(tokens) {
+		errorResponse(w, http.StatusNotFound, "token not found")
+		return
+	}
+	if len(result) > 0 && admins == 0 {
+		errorResponse(w, http.StatusConflict, "the last admin token cannot be revoked while other tokens exist")
+		return
+	}
+
+	config.UpdateTokens(result)
+	if err := config.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save config")
+	}
+
+	log.Info().Str("id", id).Msg("API token revoked")
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
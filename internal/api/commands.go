@@ -0,0 +1,422 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/metrics"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// commandTarget is a Loxone target resolved to a HUE resource
+type commandTarget struct {
+	HueID     string
+	HueType   string
+	Bridge    string
+	Members   []models.MappingMember
+	Overrides *models.MappingOverrides
+	Transform *models.MappingTransform
+	Direction string
+}
+
+// commandResult describes an executed Loxone command
+type commandResult struct {
+	Target  string
+	Action  string
+	HueID   string
+	HueType string
+	State   interface{} // Only set for status queries
+}
+
+// commandError is an error that carries the HTTP status it maps to
+type commandError struct {
+	status  int
+	message string
+	details map[string]string
+}
+
+func (e *commandError) Error() string {
+	return e.message
+}
+
+// toHueTargets drops the targets of mappings that only report HUE state to
+// Loxone. It fails if no target is left.
+func toHueTargets(loxoneID string, targets []commandTarget) ([]commandTarget, error) {
+	result := make([]commandTarget, 0, len(targets))
+	for _, target := range targets {
+		if loxone.SendsToHue(target.Direction) {
+			result = append(result, target)
+		}
+	}
+	if len(result) == 0 {
+		return nil, &commandError{
+			status:  http.StatusForbidden,
+			message: "mapping direction is to_loxone",
+			details: map[string]string{"target": loxoneID},
+		}
+	}
+	return result, nil
+}
+
+// resolveTargets resolves a Loxone target ID through the mappings.
+// A Loxone ID may resolve to several HUE resources.
+func (h *WebSocketHub) resolveTargets(loxoneID string) []commandTarget {
+	mappings := h.mappingManager.GetAllByLoxoneID(loxoneID)
+	if len(mappings) == 0 {
+		// Try using target directly as HUE ID
+		return []commandTarget{{HueID: loxoneID, HueType: "light"}}
+	}
+	return targetsFromMappings(mappings)
+}
+
+func targetsFromMappings(mappings []models.Mapping) []commandTarget {
+	targets := make([]commandTarget, 0, len(mappings))
+	for _, mapping := range mappings {
+		targets = append(targets, commandTarget{
+			HueID:     mapping.HueID,
+			HueType:   mapping.HueType,
+			Bridge:    mapping.Bridge,
+			Members:   mapping.Members,
+			Overrides: mapping.Overrides,
+			Transform: mapping.Transform,
+			Direction: mapping.Direction,
+		})
+	}
+	return targets
+}
+
+// executeCommand runs a parsed Loxone command against the HUE bridges.
+// It is shared by the WebSocket and the HTTP command path. Commands with a
+// source are written to the audit log.
+func (h *WebSocketHub) executeCommand(cmd *models.LoxoneCommand, src *commandSource) (*commandResult, error) {
+	start := time.Now()
+	result, err := h.runCommand(cmd)
+	h.recordCommand(src, cmd, result, err, time.Since(start))
+
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	action := cmd.Action
+	if !knownActions[action] {
+		action = "unknown"
+	}
+	metrics.LoxoneCommands.Inc(action, outcome)
+	return result, err
+}
+
+// knownActions limits the action label of the command metrics
+var knownActions = map[string]bool{"set": true, "scene": true, "mood": true, "stream": true, "STATUS": true}
+
+func (h *WebSocketHub) runCommand(cmd *models.LoxoneCommand) (*commandResult, error) {
+	targets := h.resolveTargets(cmd.Target)
+	result := &commandResult{
+		Target:  cmd.Target,
+		Action:  cmd.Action,
+		HueID:   targets[0].HueID,
+		HueType: targets[0].HueType,
+	}
+
+	switch cmd.Action {
+	case "set":
+		targets, err := toHueTargets(cmd.Target, targets)
+		if err != nil {
+			return nil, err
+		}
+		deviceCmd := h.commandParser.ToDeviceCommand(cmd)
+		h.echo.Record(cmd.Target, deviceCmd.On, deviceCmd.Brightness)
+		return result, fanOut(targets, func(target commandTarget) error {
+			cmd := loxone.ApplyTransform(deviceCmd, target.Transform)
+			return h.applyState(target, loxone.ApplyOverrides(cmd, target.Overrides))
+		})
+
+	case "scene":
+		sceneID, ok := cmd.Params["scene_id"].(string)
+		if !ok {
+			return nil, &commandError{status: http.StatusBadRequest, message: "scene_id required"}
+		}
+
+		// Resolve scene mapping to HUE scene ID
+		scenes := h.resolveTargets(sceneID)
+		if scenes[0].HueType != "scene" {
+			// Try using sceneID directly as HUE scene ID
+			scenes = []commandTarget{{HueID: sceneID, HueType: "scene"}}
+		}
+		result.HueID, result.HueType = scenes[0].HueID, scenes[0].HueType
+
+		scenes, err := toHueTargets(sceneID, scenes)
+		if err != nil {
+			return nil, err
+		}
+		return result, fanOut(scenes, h.activateScene)
+
+	case "mood":
+		moodNum, ok := cmd.Params["mood_number"].(int)
+		if !ok {
+			return nil, &commandError{status: http.StatusBadRequest, message: "mood_number required"}
+		}
+
+		// Mood tables win over <target>_mood_<n> mappings
+		if table := h.mappingManager.MoodTable(cmd.Target); table != nil {
+			return result, h.executeMoodTable(table, moodNum, result)
+		}
+
+		// Resolve mood mapping
+		mappings := h.mappingManager.MoodMappings(cmd.Target, moodNum)
+		if len(mappings) == 0 {
+			return nil, &commandError{
+				status:  http.StatusNotFound,
+				message: "no mapping found for mood",
+				details: map[string]string{
+					"target":      cmd.Target,
+					"mood_number": strconv.Itoa(moodNum),
+				},
+			}
+		}
+
+		moods, err := toHueTargets(cmd.Target, targetsFromMappings(mappings))
+		if err != nil {
+			return nil, err
+		}
+		result.HueID, result.HueType = moods[0].HueID, moods[0].HueType
+
+		switch {
+		case models.IsMoodOff(moodNum):
+			// Mood 0 and 778 = turn off the group/light
+			off := false
+			h.echo.Record(cmd.Target, &off, nil)
+			return result, fanOut(moods, func(target commandTarget) error {
+				return h.applyState(target, models.DeviceCommand{On: &off})
+			})
+
+		case moodNum == models.MoodAllOn:
+			// Mood 777 = all on at full brightness
+			on, full := true, 100.0
+			h.echo.Record(cmd.Target, &on, &full)
+			return result, fanOut(moods, func(target commandTarget) error {
+				return h.applyState(target, models.DeviceCommand{On: &on, Brightness: &full})
+			})
+		}
+
+		// Other moods activate the scene of <target>_mood_<n>
+		if moods[0].HueType != "scene" {
+			return nil, &commandError{status: http.StatusBadRequest, message: "mood mapping must be a scene"}
+		}
+		on := true
+		h.echo.Record(cmd.Target, &on, nil)
+		return result, fanOut(moods, h.activateScene)
+
+	case "stream":
+		// Target resolves to an entertainment configuration
+		result.HueType = "entertainment"
+		targets, err := toHueTargets(cmd.Target, targets)
+		if err != nil {
+			return nil, err
+		}
+		opts, start := h.commandParser.ToStreamOptions(cmd)
+		return result, fanOut(targets, func(target commandTarget) error {
+			client, err := h.bridges.Get(target.Bridge)
+			if err != nil {
+				return err
+			}
+			if start {
+				return client.StartStreaming(target.HueID, opts)
+			}
+			client.StopStreaming()
+			return nil
+		})
+
+	case "STATUS":
+		state, err := h.targetsState(targets)
+		if err != nil {
+			return nil, err
+		}
+		// Brightness goes back to Loxone in the mapping's value range
+		result.State = loxone.ReverseState(state, targets[0].Transform)
+		return result, nil
+
+	default:
+		return nil, &commandError{status: http.StatusBadRequest, message: "unsupported action: " + cmd.Action}
+	}
+}
+
+// fanOut runs fn for all targets in parallel and joins the errors
+func fanOut(targets []commandTarget, fn func(target commandTarget) error) error {
+	if len(targets) == 1 {
+		return fn(targets[0])
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(targets))
+
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target commandTarget) {
+			defer wg.Done()
+
+			if err := fn(target); err != nil {
+				log.Warn().Err(err).Str("hue_id", target.HueID).Str("hue_type", target.HueType).Msg("Command target failed")
+				errs[i] = fmt.Errorf("%s %s: %v", target.HueType, target.HueID, err)
+			}
+		}(i, target)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// applyState sends a device command to a light, group or virtual group
+func (h *WebSocketHub) applyState(target commandTarget, cmd models.DeviceCommand) error {
+	if target.HueType == "virtual_group" {
+		if len(target.Members) == 0 {
+			return fmt.Errorf("virtual group has no members")
+		}
+		members := make([]commandTarget, 0, len(target.Members))
+		for _, member := range target.Members {
+			members = append(members, commandTarget{HueID: member.HueID, HueType: member.HueType, Bridge: member.Bridge})
+		}
+		return fanOut(members, func(member commandTarget) error {
+			return h.applyState(member, cmd)
+		})
+	}
+
+	client, err := h.bridges.Get(target.Bridge)
+	if err != nil {
+		return err
+	}
+
+	switch target.HueType {
+	case "light":
+		return client.SetLightState(target.HueID, cmd)
+	case "group":
+		return client.SetGroupState(target.HueID, cmd)
+	}
+	return nil
+}
+
+// activateScene activates a scene on the bridge it belongs to
+func (h *WebSocketHub) activateScene(scene commandTarget) error {
+	client, err := h.bridges.Get(scene.Bridge)
+	if err != nil {
+		return err
+	}
+	return client.ActivateScene(scene.HueID)
+}
+
+// targetsState returns the current state of the targets for status queries.
+// Several targets are reported like a virtual group.
+func (h *WebSocketHub) targetsState(targets []commandTarget) (interface{}, error) {
+	if len(targets) == 1 {
+		return h.targetState(targets[0])
+	}
+
+	members := make([]models.MappingMember, 0)
+	for _, target := range targets {
+		if target.HueType == "virtual_group" {
+			members = append(members, target.Members...)
+			continue
+		}
+		members = append(members, models.MappingMember{HueID: target.HueID, HueType: target.HueType, Bridge: target.Bridge})
+	}
+	return aggregateState(h.bridges, members)
+}
+
+// targetState returns the current state of a target for status queries
+func (h *WebSocketHub) targetState(target commandTarget) (interface{}, error) {
+	switch target.HueType {
+	case "virtual_group":
+		return aggregateState(h.bridges, target.Members)
+	case "group":
+		return aggregateState(h.bridges, []models.MappingMember{{HueID: target.HueID, HueType: "group", Bridge: target.Bridge}})
+	}
+
+	client, err := h.bridges.Get(target.Bridge)
+	if err != nil {
+		return nil, err
+	}
+	light, err := client.GetLight(target.HueID)
+	if err != nil {
+		return nil, err
+	}
+	return light.State, nil
+}
+
+// aggregateState combines the states of all lights behind the members.
+// Brightness is the average of the lights that are on.
+func aggregateState(bridges *hue.Registry, members []models.MappingMember) (models.GroupState, error) {
+	state := models.GroupState{}
+	lights, err := memberLights(bridges, members)
+	if err != nil {
+		return state, err
+	}
+
+	on := 0
+	total := 0.0
+	for _, light := range lights {
+		if light.State.On {
+			on++
+			total += light.State.Brightness
+		}
+	}
+
+	state.AnyOn = on > 0
+	state.AllOn = len(lights) > 0 && on == len(lights)
+	if on > 0 {
+		state.Brightness = total / float64(on)
+	}
+	return state, nil
+}
+
+// memberLights expands members into the distinct lights they contain
+func memberLights(bridges *hue.Registry, members []models.MappingMember) ([]*models.Light, error) {
+	seen := make(map[string]bool)
+	lights := make([]*models.Light, 0)
+
+	add := func(client *hue.Client, bridge, id string) error {
+		key := bridge + "/" + id
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+
+		light, err := client.GetLight(id)
+		if err != nil {
+			return err
+		}
+		lights = append(lights, light)
+		return nil
+	}
+
+	for _, member := range members {
+		client, err := bridges.Get(member.Bridge)
+		if err != nil {
+			return nil, err
+		}
+
+		switch member.HueType {
+		case "light":
+			if err := add(client, member.Bridge, member.HueID); err != nil {
+				return nil, err
+			}
+		case "group":
+			group, err := client.GetGroup(member.HueID)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range group.Lights {
+				if err := add(client, member.Bridge, id); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return lights, nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// bridgeRequest is a request received by the stand-in bridge
+type bridgeRequest struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// newTestHub returns a hub whose primary bridge records the requests it
+// receives
+func newTestHub(t *testing.T, mappings []models.Mapping) (*WebSocketHub, func() []bridgeRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []bridgeRequest
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := bridgeRequest{Method: r.Method, Path: r.URL.Path}
+		json.NewDecoder(r.Body).Decode(&req.Body)
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		w.Write([]byte(`{"errors":[],"data":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	mappingManager := loxone.NewMappingManager()
+	mappingManager.Load(mappings)
+	bridges := hue.NewRegistry(hue.NewClient(strings.TrimPrefix(server.URL, "https://"), "test-key"))
+
+	return NewWebSocketHub(bridges, mappingManager), func() []bridgeRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]bridgeRequest(nil), requests...)
+	}
+}
+
+func TestRunCommandLegacyMoods(t *testing.T) {
+	hub, requests := newTestHub(t, []models.Mapping{
+		{ID: "wz", LoxoneID: "wohnzimmer", HueID: "light-1", HueType: "light", Enabled: true},
+		{ID: "wz1", LoxoneID: "wohnzimmer_mood_1", HueID: "scene-1", HueType: "scene", Enabled: true},
+	})
+
+	tests := []struct {
+		mood int
+		path string
+		body string
+	}{
+		{models.MoodOff, "/clip/v2/resource/light/light-1", `{"on":{"on":false}}`},
+		{models.MoodAllOff, "/clip/v2/resource/light/light-1", `{"on":{"on":false}}`},
+		{models.MoodAllOn, "/clip/v2/resource/light/light-1", `{"dimming":{"brightness":100},"on":{"on":true}}`},
+		{1, "/clip/v2/resource/scene/scene-1", `{"recall":{"action":"active"}}`},
+	}
+	for _, tt := range tests {
+		before := len(requests())
+		cmd := &models.LoxoneCommand{Type: "command", Target: "wohnzimmer", Action: "mood", Params: map[string]interface{}{"mood_number": tt.mood}}
+		if _, err := hub.runCommand(cmd); err != nil {
+			t.Fatalf("mood %d: %v", tt.mood, err)
+		}
+
+		sent := requests()[before:]
+		if len(sent) != 1 || sent[0].Method != http.MethodPut || sent[0].Path != tt.path {
+			t.Fatalf("mood %d: requests = %+v", tt.mood, sent)
+		}
+		if body, _ := json.Marshal(sent[0].Body); string(body) != tt.body {
+			t.Fatalf("mood %d: body = %s, want %s", tt.mood, body, tt.body)
+		}
+	}
+
+	cmd := &models.LoxoneCommand{Type: "command", Target: "wohnzimmer", Action: "mood", Params: map[string]interface{}{"mood_number": 2}}
+	if _, err := hub.runCommand(cmd); err == nil {
+		t.Fatal("unmapped mood accepted")
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// GetEntertainment returns all entertainment configurations and the stream status
+func (h *Handlers) GetEntertainment(w http.ResponseWriter, r *http.Request) {
+	configs, err := h.hueClient.GetEntertainmentConfigurations()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"configurations": configs,
+		"stream":         h.hueClient.StreamingStatus(),
+		"client_key":     h.hueClient.ClientKey() != "",
+	})
+}
+
+// CreateEntertainment creates a new entertainment configuration
+func (h *Handlers) CreateEntertainment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string   `json:"name"`
+		Type   string   `json:"type"`
+		Lights []string `json:"lights"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Name == "" || len(req.Lights) == 0 {
+		errorResponse(w, http.StatusBadRequest, "name and lights required")
+		return
+	}
+
+	id, err := h.hueClient.CreateEntertainmentConfiguration(req.Name, req.Type, req.Lights)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+// GetStream returns the status of the entertainment stream
+func (h *Handlers) GetStream(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, h.hueClient.StreamingStatus())
+}
+
+// StartStream starts streaming a pattern to an entertainment configuration
+func (h *Handlers) StartStream(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var opts models.StreamOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	start := time.Now()
+	err := h.hueClient.StartStreaming(id, opts)
+	h.recordAPICommand(r, "entertainment", id, "stream_start", opts, err, time.Since(start))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, h.hueClient.StreamingStatus())
+}
+
+// StopStream stops the entertainment stream
+func (h *Handlers) StopStream(w http.ResponseWriter, r *http.Request) {
+	status := h.hueClient.StreamingStatus()
+	start := time.Now()
+	h.hueClient.StopStreaming()
+	if status.Active {
+		h.recordAPICommand(r, "entertainment", status.ConfigID, "stream_stop", nil, nil, time.Since(start))
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+)
+
+// GenerateMappings proposes mappings for the rooms, zones, lights and scenes
+// of a bridge and merges them into the existing mappings unless dry_run is set
+func (h *Handlers) GenerateMappings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DryRun bool   `json:"dry_run"`
+		Bridge string `json:"bridge,omitempty"`
+		Groups *bool  `json:"groups,omitempty"` // Defaults to true
+		Lights *bool  `json:"lights,omitempty"` // Defaults to true
+		Scenes *bool  `json:"scenes,omitempty"` // Defaults to true
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	client, err := h.bridges.Get(req.Bridge)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groups, err := client.GetGroups()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	lights, err := client.GetLights()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	scenes, err := client.GetScenes()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	enabled := func(b *bool) bool { return b == nil || *b }
+	existing := config.GetMappings()
+	proposed := loxone.GenerateMappings(groups, lights, scenes, existing, loxone.GenerateOptions{
+		Groups: enabled(req.Groups),
+		Lights: enabled(req.Lights),
+		Scenes: enabled(req.Scenes),
+		Bridge: req.Bridge,
+	})
+
+	if req.DryRun {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"dry_run":  true,
+			"mappings": proposed,
+			"total":    len(proposed),
+		})
+		return
+	}
+
+	// Same merge as ImportMappings, generated mappings are always new targets
+	resultMappings, imported, updated := mergeMappings(existing, proposed)
+
+	config.UpdateMappings(resultMappings)
+	conflicts := h.mappingManager.Load(resultMappings)
+
+	if err := config.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save config after generating mappings")
+		errorResponse(w, http.StatusInternalServerError, "failed to save config")
+		return
+	}
+
+	log.Info().Int("imported", imported).Msg("Generated mappings from HUE Bridge")
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"dry_run":   false,
+		"mappings":  resultMappings[len(resultMappings)-imported:],
+		"imported":  imported,
+		"updated":   updated,
+		"total":     len(resultMappings),
+		"conflicts": conflicts,
+	})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// CreateZone creates a new zone
+func (h *Handlers) CreateZone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string   `json:"name"`
+		Archetype string   `json:"archetype"`
+		Lights    []string `json:"lights"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	group, err := h.hueClient.CreateZone(req.Name, req.Archetype, req.Lights)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, group)
+}
+
+// AddGroupLight adds a light to a room or zone
+func (h *Handlers) AddGroupLight(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	group, err := h.hueClient.AddLightToGroup(vars["id"], vars["lightId"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, group)
+}
+
+// RemoveGroupLight removes a light from a room or zone
+func (h *Handlers) RemoveGroupLight(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	group, err := h.hueClient.RemoveLightFromGroup(vars["id"], vars["lightId"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, group)
+}
+
+// DeleteGroup deletes a zone. Mappings and mood tables pointing to the zone
+// or its scenes are reported and the deletion is refused unless force=true
+// is given.
+func (h *Handlers) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	force := r.URL.Query().Get("force") == "true"
+
+	affected, tables := h.referencesToGroup(id)
+	if (len(affected) > 0 || len(tables) > 0) && !force {
+		jsonResponse(w, http.StatusConflict, map[string]interface{}{
+			"error":       "group is used by mappings, use force=true to delete anyway",
+			"mappings":    affected,
+			"mood_tables": tables,
+		})
+		return
+	}
+
+	if err := h.hueClient.DeleteZone(id); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, m := range affected {
+		log.Warn().
+			Str("group_id", id).
+			Str("mapping_id", m.ID).
+			Str("loxone_id", m.LoxoneID).
+			Msg("Mapped group deleted, mapping no longer resolves")
+	}
+	for _, t := range tables {
+		log.Warn().
+			Str("group_id", id).
+			Str("target", t.Target).
+			Msg("Mapped group deleted, mood table no longer resolves")
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":               "deleted",
+		"orphaned":             affected,
+		"orphaned_mood_tables": tables,
+	})
+}
+
+// referencesToGroup returns the mappings and mood tables that reference a
+// zone of the primary bridge or one of its scenes, including virtual
+// groups with the zone as a member
+func (h *Handlers) referencesToGroup(groupID string) ([]models.Mapping, []models.MoodTable) {
+	ids := map[string]bool{groupID: true}
+
+	scenes, err := h.hueClient.GetScenes()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch scenes for mapping check")
+	}
+	for _, scene := range scenes {
+		if scene.GroupID == groupID {
+			ids[scene.ID] = true
+		}
+	}
+
+	affected := make([]models.Mapping, 0)
+	for _, m := range config.GetMappings() {
+		if mappingReferences(m, ids) {
+			affected = append(affected, m)
+		}
+	}
+
+	tables := make([]models.MoodTable, 0)
+	for _, t := range config.GetMoodTables() {
+		if moodTableReferences(t, ids) {
+			tables = append(tables, t)
+		}
+	}
+	return affected, tables
+}
+
+// mappingReferences reports whether a mapping points to one of the given
+// resources of the primary bridge
+func mappingReferences(m models.Mapping, ids map[string]bool) bool {
+	if m.HueType == "virtual_group" {
+		for _, member := range m.Members {
+			if member.Bridge == "" && ids[member.HueID] {
+				return true
+			}
+		}
+		return false
+	}
+	return m.Bridge == "" && ids[m.HueID]
+}
+
+// moodTableReferences reports whether a mood table switches or recalls one
+// of the given resources of the primary bridge
+func moodTableReferences(t models.MoodTable, ids map[string]bool) bool {
+	if t.Bridge != "" {
+		return false
+	}
+	if ids[t.HueID] {
+		return true
+	}
+	for _, mood := range t.Moods {
+		if ids[mood.SceneID] {
+			return true
+		}
+	}
+	return false
+}
@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/history"
 	"github.com/sbeyeler/loxone2hue/internal/hue"
 	"github.com/sbeyeler/loxone2hue/internal/loxone"
 	"github.com/sbeyeler/loxone2hue/internal/models"
@@ -20,13 +22,21 @@ import (
 // Handlers contains all HTTP handlers
 type Handlers struct {
 	hueClient      *hue.Client
+	bridges        *hue.Registry
 	mappingManager *loxone.MappingManager
+
+	structure   *loxone.Structure // Last imported LoxAPP3.json
+	structureMu sync.RWMutex
+
+	history       *history.Store  // Audit log, nil if disabled
+	configWatcher *config.Watcher // Applies restored and updated configurations
 }
 
 // NewHandlers creates a new handlers instance
-func NewHandlers(hueClient *hue.Client, mappingManager *loxone.MappingManager) *Handlers {
+func NewHandlers(bridges *hue.Registry, mappingManager *loxone.MappingManager) *Handlers {
 	return &Handlers{
-		hueClient:      hueClient,
+		hueClient:      bridges.Primary(),
+		bridges:        bridges,
 		mappingManager: mappingManager,
 	}
 }
@@ -46,8 +56,8 @@ func errorResponse(w http.ResponseWriter, status int, message string) {
 // Health returns the service health status
 func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
+		"status":         "healthy",
+		"timestamp":      time.Now().UTC(),
 		"hue_configured": h.hueClient.IsConfigured(),
 	})
 }
@@ -194,8 +204,8 @@ func (h *Handlers) PairBridge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Save configuration
-	config.UpdateHue(req.BridgeIP, appKey)
+	// Save configuration, the client key is only kept in the config file
+	config.UpdateHue(req.BridgeIP, appKey, h.hueClient.ClientKey())
 	if err := config.Save(); err != nil {
 		log.Error().Err(err).Msg("Failed to save config")
 	}
@@ -203,6 +213,7 @@ func (h *Handlers) PairBridge(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"success":         true,
 		"application_key": appKey,
+		"has_client_key":  h.hueClient.ClientKey() != "",
 	})
 }
 
@@ -244,7 +255,10 @@ func (h *Handlers) SetDevice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.hueClient.SetLightState(id, cmd); err != nil {
+	start := time.Now()
+	err := h.hueClient.SetLightState(id, cmd)
+	h.recordAPICommand(r, "light", id, "set", cmd, err, time.Since(start))
+	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -297,7 +311,10 @@ func (h *Handlers) SetGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.hueClient.SetGroupState(id, cmd); err != nil {
+	start := time.Now()
+	err := h.hueClient.SetGroupState(id, cmd)
+	h.recordAPICommand(r, "group", id, "set", cmd, err, time.Since(start))
+	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -323,7 +340,10 @@ func (h *Handlers) ActivateScene(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if err := h.hueClient.ActivateScene(id); err != nil {
+	start := time.Now()
+	err := h.hueClient.ActivateScene(id)
+	h.recordAPICommand(r, "scene", id, "activate", nil, err, time.Since(start))
+	if err != nil {
 		errorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -335,7 +355,8 @@ func (h *Handlers) ActivateScene(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) GetMappings(w http.ResponseWriter, r *http.Request) {
 	mappings := config.GetMappings()
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"mappings": mappings,
+		"mappings":  mappings,
+		"conflicts": h.mappingManager.Conflicts(),
 	})
 }
 
@@ -351,6 +372,10 @@ func (h *Handlers) CreateMapping(w http.ResponseWriter, r *http.Request) {
 	mapping.Enabled = true
 
 	mappings := config.GetMappings()
+	if errs := newMappingValidator(h.bridges, false).validate(mapping, mappings); len(errs) > 0 {
+		validationErrorResponse(w, errs)
+		return
+	}
 	mappings = append(mappings, mapping)
 	config.UpdateMappings(mappings)
 
@@ -390,6 +415,11 @@ func (h *Handlers) UpdateMapping(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if errs := newMappingValidator(h.bridges, false).validate(update, mappings); len(errs) > 0 {
+		validationErrorResponse(w, errs)
+		return
+	}
+
 	config.UpdateMappings(mappings)
 	h.mappingManager.Load(mappings)
 
@@ -440,10 +470,12 @@ func (h *Handlers) GetConfig(w http.ResponseWriter, r *http.Request) {
 	safeConfig := map[string]interface{}{
 		"server": cfg.Server,
 		"hue": map[string]interface{}{
-			"bridge_ip":  cfg.Hue.BridgeIP,
-			"configured": cfg.Hue.ApplicationKey != "",
+			"bridge_ip":      cfg.Hue.BridgeIP,
+			"configured":     cfg.Hue.ApplicationKey != "",
+			"has_client_key": cfg.Hue.ClientKey != "",
 		},
 		"loxone":  cfg.Loxone,
+		"auth":    cfg.Auth,
 		"logging": cfg.Logging,
 	}
 
@@ -454,6 +486,7 @@ func (h *Handlers) GetConfig(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	var update struct {
 		Loxone *config.LoxoneConfig `json:"loxone,omitempty"`
+		Auth   *config.AuthConfig   `json:"auth,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
@@ -461,10 +494,12 @@ func (h *Handlers) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := config.Get()
-
+	old := config.Get()
 	if update.Loxone != nil {
-		cfg.Loxone = *update.Loxone
+		config.UpdateLoxone(*update.Loxone)
+	}
+	if update.Auth != nil {
+		config.UpdateAuth(*update.Auth)
 	}
 
 	if err := config.Save(); err != nil {
@@ -472,6 +507,12 @@ func (h *Handlers) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Saving updates the file checksum, so the watcher does not apply the
+	// change, e.g. a new Miniserver address
+	if h.configWatcher != nil {
+		h.configWatcher.Apply(old, config.Get())
+	}
+
 	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
@@ -502,6 +543,46 @@ func (h *Handlers) ExportMappings(w http.ResponseWriter, r *http.Request) {
 	encoder.Encode(backup)
 }
 
+// mergeMappings merges mappings into the existing ones. Mappings are updated
+// by id or by loxone_id and target, a Loxone ID may map to several HUE resources.
+func mergeMappings(existingMappings, newMappings []models.Mapping) (result []models.Mapping, imported, updated int) {
+	existingByID := make(map[string]int)
+	existingByTarget := make(map[string]int)
+	for i, m := range existingMappings {
+		existingByID[m.ID] = i
+		existingByTarget[mappingTargetKey(m)] = i
+	}
+
+	result = existingMappings
+
+	for _, newMapping := range newMappings {
+		idx, exists := existingByID[newMapping.ID]
+		if !exists || newMapping.ID == "" {
+			idx, exists = existingByTarget[mappingTargetKey(newMapping)]
+		}
+		if exists {
+			// Update existing mapping
+			newMapping.ID = result[idx].ID
+			result[idx] = newMapping
+			updated++
+		} else {
+			// Add new mapping
+			if newMapping.ID == "" {
+				newMapping.ID = uuid.New().String()
+			}
+			result = append(result, newMapping)
+			imported++
+		}
+	}
+
+	return result, imported, updated
+}
+
+// mappingTargetKey identifies the Loxone ID and HUE resource of a mapping
+func mappingTargetKey(m models.Mapping) string {
+	return m.LoxoneID + "|" + m.HueType + "|" + m.Bridge + "|" + m.HueID
+}
+
 // ImportMappings imports mappings from a JSON backup
 func (h *Handlers) ImportMappings(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -519,14 +600,22 @@ func (h *Handlers) ImportMappings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	importedMappings := req.Backup.Mappings
-	if importedMappings == nil {
-		importedMappings = []models.Mapping{}
-	}
-
 	var resultMappings []models.Mapping
 	var imported, skipped, updated int
 
+	// Invalid mappings are skipped and reported
+	importedMappings := make([]models.Mapping, 0, len(req.Backup.Mappings))
+	invalid := make([]MappingIssue, 0)
+	validator := newMappingValidator(h.bridges, false)
+	for _, m := range req.Backup.Mappings {
+		if errs := validator.validate(m, nil); len(errs) > 0 {
+			invalid = append(invalid, MappingIssue{MappingID: m.ID, LoxoneID: m.LoxoneID, Name: m.Name, Errors: errs})
+			skipped++
+			continue
+		}
+		importedMappings = append(importedMappings, m)
+	}
+
 	switch req.Mode {
 	case "replace":
 		// Replace all existing mappings
@@ -539,30 +628,7 @@ func (h *Handlers) ImportMappings(w http.ResponseWriter, r *http.Request) {
 		imported = len(importedMappings)
 
 	case "merge":
-		// Merge with existing mappings (skip duplicates by loxone_id)
-		existingMappings := config.GetMappings()
-		existingByLoxoneID := make(map[string]int)
-		for i, m := range existingMappings {
-			existingByLoxoneID[m.LoxoneID] = i
-		}
-
-		resultMappings = existingMappings
-
-		for _, newMapping := range importedMappings {
-			if idx, exists := existingByLoxoneID[newMapping.LoxoneID]; exists {
-				// Update existing mapping
-				newMapping.ID = resultMappings[idx].ID
-				resultMappings[idx] = newMapping
-				updated++
-			} else {
-				// Add new mapping
-				if newMapping.ID == "" {
-					newMapping.ID = uuid.New().String()
-				}
-				resultMappings = append(resultMappings, newMapping)
-				imported++
-			}
-		}
+		resultMappings, imported, updated = mergeMappings(config.GetMappings(), importedMappings)
 
 	default:
 		errorResponse(w, http.StatusBadRequest, "invalid mode: use 'replace' or 'merge'")
@@ -571,7 +637,7 @@ func (h *Handlers) ImportMappings(w http.ResponseWriter, r *http.Request) {
 
 	// Update config and mapping manager
 	config.UpdateMappings(resultMappings)
-	h.mappingManager.Load(resultMappings)
+	conflicts := h.mappingManager.Load(resultMappings)
 
 	if err := config.Save(); err != nil {
 		log.Error().Err(err).Msg("Failed to save config after import")
@@ -580,10 +646,12 @@ func (h *Handlers) ImportMappings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"status":   "ok",
-		"imported": imported,
-		"updated":  updated,
-		"skipped":  skipped,
-		"total":    len(resultMappings),
+		"status":    "ok",
+		"imported":  imported,
+		"updated":   updated,
+		"skipped":   skipped,
+		"total":     len(resultMappings),
+		"conflicts": conflicts,
+		"invalid":   invalid,
 	})
 }
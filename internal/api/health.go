@@ -0,0 +1,293 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+)
+
+// Component states
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthFailing  = "failing"
+	healthDisabled = "disabled"
+)
+
+// Overall states
+const (
+	statusOK          = "ok"
+	statusDegraded    = "degraded"
+	statusUnavailable = "unavailable"
+)
+
+const (
+	bridgeProbeTTL  = 30 * time.Second
+	configProbeTTL  = time.Minute
+	mappingProbeTTL = time.Minute
+
+	// eventStreamGrace is how long the event stream may be disconnected
+	// before the gateway reports not ready, it reconnects every 5 seconds
+	eventStreamGrace = 30 * time.Second
+)
+
+// componentHealth is the result of a single health check. Failing critical
+// components make the gateway not ready, the others only degrade it.
+type componentHealth struct {
+	Status   string                 `json:"status"`
+	Critical bool                   `json:"critical"`
+	Message  string                 `json:"message,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// healthProbe caches the result of an expensive check
+type healthProbe struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	at     time.Time
+	result componentHealth
+}
+
+func (p *healthProbe) get(check func() componentHealth) componentHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.at.IsZero() || time.Since(p.at) > p.ttl {
+		p.result = check()
+		p.at = time.Now()
+		if p.result.Details == nil {
+			p.result.Details = make(map[string]interface{})
+		}
+		p.result.Details["checked_at"] = p.at.UTC()
+	}
+	return p.result
+}
+
+// healthChecks holds the cached probes of the readiness check
+type healthChecks struct {
+	started  time.Time
+	mu       sync.Mutex
+	bridges  map[string]*healthProbe
+	config   *healthProbe
+	mappings *healthProbe
+}
+
+func newHealthChecks() *healthChecks {
+	return &healthChecks{
+		started:  time.Now(),
+		bridges:  make(map[string]*healthProbe),
+		config:   &healthProbe{ttl: configProbeTTL},
+		mappings: &healthProbe{ttl: mappingProbeTTL},
+	}
+}
+
+func (c *healthChecks) bridgeProbe(id string) *healthProbe {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	probe, ok := c.bridges[id]
+	if !ok {
+		probe = &healthProbe{ttl: bridgeProbeTTL}
+		c.bridges[id] = probe
+	}
+	return probe
+}
+
+// componentName appends the bridge ID for additional bridges
+func componentName(name, bridge string) string {
+	if bridge == "" {
+		return name
+	}
+	return name + ":" + bridge
+}
+
+// checkHub reports whether the WebSocket hub is running
+func (s *Server) checkHub() componentHealth {
+	s.wsHub.mu.RLock()
+	clients := len(s.wsHub.clients)
+	s.wsHub.mu.RUnlock()
+
+	result := componentHealth{Status: healthOK, Critical: true, Details: map[string]interface{}{"clients": clients}}
+	if !s.wsHub.running.Load() {
+		result.Status, result.Message = healthFailing, "websocket hub is not running"
+	}
+	return result
+}
+
+// checkBridge probes the bridge API, the result is cached
+func (s *Server) checkBridge(id string, client *hue.Client) componentHealth {
+	if !client.IsConfigured() {
+		return componentHealth{Status: healthDisabled, Message: "bridge not paired"}
+	}
+
+	return s.health.bridgeProbe(id).get(func() componentHealth {
+		start := time.Now()
+		_, err := client.GetBridgeInfo()
+		latency := time.Since(start)
+
+		result := componentHealth{Status: healthOK, Critical: true, Details: map[string]interface{}{
+			"latency_ms": float64(latency.Microseconds()) / 1000,
+		}}
+		if err != nil {
+			result.Status, result.Message = healthFailing, err.Error()
+		}
+		return result
+	})
+}
+
+// checkEventStream reports the SSE connection of a bridge
+func (s *Server) checkEventStream(client *hue.Client) componentHealth {
+	if !client.IsConfigured() {
+		return componentHealth{Status: healthDisabled, Message: "bridge not paired"}
+	}
+
+	stream := client.EventStreamStatus()
+	if !stream.Started {
+		return componentHealth{Status: healthDegraded, Message: "event stream not started, restart the gateway after pairing"}
+	}
+
+	details := map[string]interface{}{"since": stream.Since.UTC()}
+	if !stream.LastEvent.IsZero() {
+		details["last_event"] = stream.LastEvent.UTC()
+		details["last_event_age_seconds"] = int(time.Since(stream.LastEvent).Seconds())
+	}
+	result := componentHealth{Status: healthOK, Critical: true, Details: details}
+
+	if !stream.Connected {
+		result.Status, result.Message = healthDegraded, "event stream reconnecting"
+		if stream.LastError != "" {
+			result.Message += ": " + stream.LastError
+		}
+		if time.Since(stream.Since) > eventStreamGrace {
+			result.Status = healthFailing
+		}
+	}
+	return result
+}
+
+// checkConfigWritable verifies that the configuration directory accepts
+// new files, which saving the configuration needs
+func (s *Server) checkConfigWritable() componentHealth {
+	return s.health.config.get(func() componentHealth {
+		dir := config.Dir()
+		result := componentHealth{Status: healthOK, Details: map[string]interface{}{"dir": dir}}
+
+		file, err := os.CreateTemp(dir, ".health-*")
+		if err != nil {
+			result.Status, result.Message = healthFailing, err.Error()
+			return result
+		}
+		file.Close()
+		os.Remove(file.Name())
+		return result
+	})
+}
+
+// checkMappings summarizes the mapping validation against the cached
+// bridge resources
+func (s *Server) checkMappings() componentHealth {
+	return s.health.mappings.get(func() componentHealth {
+		report := s.handlers.validationReport(false)
+
+		result := componentHealth{Status: healthOK, Details: map[string]interface{}{
+			"total":      report.Total,
+			"valid":      report.Valid,
+			"orphaned":   len(report.Orphaned),
+			"duplicates": len(report.Duplicates),
+			"invalid":    len(report.Invalid),
+		}}
+		if len(report.Unverified) > 0 {
+			result.Details["unverified"] = report.Unverified
+		}
+		if report.Valid < report.Total || len(report.Duplicates) > 0 {
+			result.Status, result.Message = healthDegraded, "some mappings are invalid, see /api/mappings/validate"
+		}
+		return result
+	})
+}
+
+// checkMiniserver reports the connection to the Loxone Miniserver
+func (s *Server) checkMiniserver() componentHealth {
+	client := s.currentMiniserver()
+	if client == nil {
+		return componentHealth{Status: healthDisabled}
+	}
+
+	status := client.Status()
+	result := componentHealth{Status: healthOK, Details: map[string]interface{}{"host": status.Host}}
+	if status.ConnectedAt != nil {
+		result.Details["connected_at"] = status.ConnectedAt.UTC()
+	}
+	if !status.Connected {
+		result.Status, result.Message = healthFailing, "not connected"
+		if status.LastError != "" {
+			result.Message += ": " + status.LastError
+		}
+	}
+	return result
+}
+
+// overallStatus derives the gateway state from its components
+func overallStatus(components map[string]componentHealth) string {
+	status := statusOK
+	for _, c := range components {
+		switch {
+		case c.Status == healthFailing && c.Critical:
+			return statusUnavailable
+		case c.Status == healthFailing || c.Status == healthDegraded:
+			status = statusDegraded
+		}
+	}
+	return status
+}
+
+// HealthLive reports whether the process is running and able to serve
+// requests, used as liveness probe and watchdog
+func (s *Server) HealthLive(w http.ResponseWriter, r *http.Request) {
+	hub := s.checkHub()
+
+	status, code := statusOK, http.StatusOK
+	if hub.Status == healthFailing {
+		status, code = statusUnavailable, http.StatusServiceUnavailable
+	}
+	jsonResponse(w, code, map[string]interface{}{
+		"status":         status,
+		"timestamp":      time.Now().UTC(),
+		"uptime_seconds": int(time.Since(s.health.started).Seconds()),
+	})
+}
+
+// HealthReady checks all components and answers 503 while a critical one
+// is failing
+func (s *Server) HealthReady(w http.ResponseWriter, r *http.Request) {
+	components := map[string]componentHealth{
+		"websocket_hub": s.checkHub(),
+		"config":        s.checkConfigWritable(),
+		"mappings":      s.checkMappings(),
+		"miniserver":    s.checkMiniserver(),
+	}
+	for _, id := range append([]string{""}, s.bridges.IDs()...) {
+		client, err := s.bridges.Get(id)
+		if err != nil {
+			continue
+		}
+		components[componentName("bridge", id)] = s.checkBridge(id, client)
+		components[componentName("event_stream", id)] = s.checkEventStream(client)
+	}
+
+	status := overallStatus(components)
+	code := http.StatusOK
+	if status == statusUnavailable {
+		code = http.StatusServiceUnavailable
+	}
+	jsonResponse(w, code, map[string]interface{}{
+		"status":     status,
+		"ready":      status != statusUnavailable,
+		"timestamp":  time.Now().UTC(),
+		"components": components,
+	})
+}
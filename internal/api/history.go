@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/history"
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// Limits of /api/history
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// recordedEventTypes are the HUE resource types whose events are logged
+var recordedEventTypes = map[string]bool{"light": true, "grouped_light": true, "scene": true}
+
+// commandSource describes where a command came from for the audit log
+type commandSource struct {
+	source     string
+	client     string
+	remoteAddr string
+	raw        string
+}
+
+// SetHistory enables the audit log
+func (s *Server) SetHistory(store *history.Store) {
+	s.history = store
+	s.wsHub.history = store
+	s.handlers.history = store
+}
+
+// recordCommand logs an executed command
+func (h *WebSocketHub) recordCommand(src *commandSource, cmd *models.LoxoneCommand, result *commandResult, err error, latency time.Duration) {
+	if h.history == nil || src == nil {
+		return
+	}
+
+	entry := history.Entry{
+		Kind:       history.KindCommand,
+		Source:     src.source,
+		Client:     src.client,
+		RemoteAddr: src.remoteAddr,
+		Raw:        src.raw,
+		Target:     cmd.Target,
+		Action:     cmd.Action,
+		Params:     cmd.Params,
+		Result:     "ok",
+		LatencyMs:  float64(latency.Microseconds()) / 1000,
+	}
+	if result != nil {
+		entry.HueID, entry.HueType = result.HueID, result.HueType
+	}
+	if err != nil {
+		entry.Result, entry.Error = "error", err.Error()
+	}
+	h.history.Add(entry)
+}
+
+// recordAPICommand logs a light change requested through the REST API
+func (h *Handlers) recordAPICommand(r *http.Request, hueType, hueID, action string, params interface{}, err error, latency time.Duration) {
+	if h.history == nil {
+		return
+	}
+
+	entry := history.Entry{
+		Kind:       history.KindCommand,
+		Source:     history.SourceAPI,
+		RemoteAddr: commandRemoteAddr(r),
+		Raw:        r.Method + " " + r.URL.Path,
+		Target:     hueID,
+		Action:     action,
+		Params:     apiCommandParams(params),
+		HueID:      hueID,
+		HueType:    hueType,
+		Result:     "ok",
+		LatencyMs:  float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		entry.Result, entry.Error = "error", err.Error()
+	}
+	h.history.Add(entry)
+}
+
+// apiCommandParams converts a request body to the params of a history entry
+func apiCommandParams(params interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil || len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// recordHueEvent logs a state change reported by a bridge
+func (h *WebSocketHub) recordHueEvent(bridge string, event hue.Event) {
+	if h.history == nil || !recordedEventTypes[event.Type] {
+		return
+	}
+
+	entry := history.Entry{
+		Kind:    history.KindState,
+		Source:  history.SourceHue,
+		Bridge:  bridge,
+		HueID:   event.ID,
+		HueType: event.Type,
+		State:   event.Data,
+	}
+	if mappings := h.mappingManager.GetAllByHueID(bridge, event.ID); len(mappings) > 0 {
+		entry.Target = mappings[0].LoxoneID
+	}
+	h.history.Add(entry)
+}
+
+// parseHistoryTime accepts RFC 3339 timestamps or a duration back from now,
+// e.g. 2h
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or a duration like 2h", value)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// parseHistoryFilter reads the filter query parameters
+func parseHistoryFilter(r *http.Request) (history.Filter, error) {
+	q := r.URL.Query()
+	f := history.Filter{
+		Target: q.Get("target"),
+		Source: q.Get("source"),
+		Kind:   q.Get("kind"),
+		Limit:  defaultHistoryLimit,
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = parseHistoryTime(v); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = parseHistoryTime(v); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return f, fmt.Errorf("limit must be a positive number")
+		}
+		f.Limit = min(limit, maxHistoryLimit)
+	}
+	return f, nil
+}
+
+// GetHistory returns audit log entries, newest first
+func (s *Server) GetHistory(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		errorResponse(w, http.StatusServiceUnavailable, "history not available")
+		return
+	}
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries := s.history.Query(filter)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// HistoryLive streams new audit log entries matching the filter over a
+// WebSocket
+func (s *Server) HistoryLive(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		errorResponse(w, http.StatusServiceUnavailable, "history not available")
+		return
+	}
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	entries, cancel := s.history.Subscribe()
+	defer cancel()
+
+	// Detect the client closing the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case entry := <-entries:
+			if !filter.Match(entry) {
+				continue
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// commandRemoteAddr returns the client address of an HTTP command
+func commandRemoteAddr(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" && isIngress(r) {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return remoteIP(r)
+}
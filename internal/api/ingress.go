@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sbeyeler/loxone2hue/internal/config"
+)
+
+// defaultSupervisorIP is the address the Home Assistant supervisor proxies
+// ingress requests from
+const defaultSupervisorIP = "172.30.32.2"
+
+// ingressHeader carries the path prefix of the add-on behind ingress
+const ingressHeader = "X-Ingress-Path"
+
+// requestSource tells the middlewares how a request reached the gateway
+type requestSource int
+
+const (
+	sourceDirect requestSource = iota
+	sourceIngress
+	sourceLoxonePort
+	sourceOutsideIngress // Direct request to the main port while ingress is enabled
+)
+
+type requestInfoKey struct{}
+
+type requestInfo struct {
+	source      requestSource
+	ingressPath string
+}
+
+func withRequestInfo(r *http.Request, info requestInfo) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+}
+
+func getRequestInfo(r *http.Request) requestInfo {
+	info, _ := r.Context().Value(requestInfoKey{}).(requestInfo)
+	return info
+}
+
+// ingressPath returns the ingress prefix of a request, empty outside ingress
+func ingressPath(r *http.Request) string {
+	return getRequestInfo(r).ingressPath
+}
+
+// isIngress reports whether a request came through Home Assistant ingress,
+// which already authenticated the user
+func isIngress(r *http.Request) bool {
+	return getRequestInfo(r).source == sourceIngress
+}
+
+// isOutsideIngress reports whether a request reached the main port of the
+// add-on directly instead of through ingress
+func isOutsideIngress(r *http.Request) bool {
+	return getRequestInfo(r).source == sourceOutsideIngress
+}
+
+// isLoxonePort reports whether a request came in on the Loxone listener
+func isLoxonePort(r *http.Request) bool {
+	return getRequestInfo(r).source == sourceLoxonePort
+}
+
+// remoteIP returns the IP address of the client
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// ingressHandler marks requests from the supervisor as ingress requests. The
+// ingress header of any other client is dropped so it cannot fake a prefix
+// or skip authentication.
+func ingressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ingress := config.Get().Server.Ingress
+		path := strings.TrimSuffix(r.Header.Get(ingressHeader), "/")
+		r.Header.Del(ingressHeader)
+
+		supervisor := ingress.SupervisorIP
+		if supervisor == "" {
+			supervisor = defaultSupervisorIP
+		}
+		switch {
+		case ingress.Enabled && path != "" && remoteIP(r) == supervisor:
+			r = withRequestInfo(r, requestInfo{source: sourceIngress, ingressPath: path})
+		case ingress.Enabled:
+			r = withRequestInfo(r, requestInfo{source: sourceOutsideIngress})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loxoneHandler serves only the endpoints used by Loxone virtual outputs and
+// inputs. Requests on this listener always need a token once tokens exist.
+func loxoneHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoxoneEndpoint(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, withRequestInfo(r, requestInfo{source: sourceLoxonePort}))
+	})
+}
+
+// frontendHandler serves the web frontend. Behind ingress the prefix is
+// injected into index.html so the frontend can build its API and WebSocket
+// URLs.
+func frontendHandler(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := ingressPath(r)
+		if prefix == "" || (r.URL.Path != "/" && r.URL.Path != "/index.html") {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "index.html"))
+		if err != nil {
+			files.ServeHTTP(w, r)
+			return
+		}
+		encoded, _ := json.Marshal(prefix)
+		script := []byte("<script>window.__INGRESS_PATH__ = " + string(encoded) + ";</script></head>")
+		data = bytes.Replace(data, []byte("</head>"), script, 1)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(data)
+	})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GetInventory returns all HUE devices with model, firmware and connectivity information
+func (h *Handlers) GetInventory(w http.ResponseWriter, r *http.Request) {
+	devices, err := h.hueClient.GetInventory()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Summaries for a quick audit
+	updates := 0
+	unreachable := 0
+	for _, d := range devices {
+		if d.UpdateState != "" && d.UpdateState != "no_update" {
+			updates++
+		}
+		if d.Connectivity != "" && d.Connectivity != "connected" {
+			unreachable++
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"devices":           devices,
+		"total":             len(devices),
+		"updates_available": updates,
+		"unreachable":       unreachable,
+	})
+}
+
+// renameRequest is the body of all rename endpoints
+type renameRequest struct {
+	Name string `json:"name"`
+}
+
+// RenameInventoryDevice renames a HUE device
+func (h *Handlers) RenameInventoryDevice(w http.ResponseWriter, r *http.Request) {
+	h.handleRename(w, r, h.hueClient.RenameDevice)
+}
+
+// RenameDevice renames a light
+func (h *Handlers) RenameDevice(w http.ResponseWriter, r *http.Request) {
+	h.handleRename(w, r, h.hueClient.RenameLight)
+}
+
+// RenameGroup renames a room or zone
+func (h *Handlers) RenameGroup(w http.ResponseWriter, r *http.Request) {
+	h.handleRename(w, r, h.hueClient.RenameGroup)
+}
+
+func (h *Handlers) handleRename(w http.ResponseWriter, r *http.Request, rename func(id, name string) error) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req renameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := rename(id, req.Name); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok", "name": req.Name})
+}
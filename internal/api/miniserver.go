@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/history"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// SetMiniserver connects the Miniserver WebSocket client to the command
+// path, nil disconnects it. The previous client is not closed.
+func (s *Server) SetMiniserver(client *loxone.MiniserverClient) {
+	s.miniserverMu.Lock()
+	s.miniserver = client
+	s.miniserverMu.Unlock()
+
+	if client != nil {
+		client.SetHandler(func(cmd *models.LoxoneCommand) {
+			s.executeMiniserverCommand(client, cmd)
+		})
+	}
+}
+
+// currentMiniserver returns the Miniserver client, nil if disabled
+func (s *Server) currentMiniserver() *loxone.MiniserverClient {
+	s.miniserverMu.RLock()
+	defer s.miniserverMu.RUnlock()
+	return s.miniserver
+}
+
+// executeMiniserverCommand runs a command for a Miniserver state change
+func (s *Server) executeMiniserverCommand(client *loxone.MiniserverClient, cmd *models.LoxoneCommand) {
+	src := &commandSource{source: history.SourceMiniserver, client: client.Status().Host}
+	if _, err := s.wsHub.executeCommand(cmd, src); err != nil {
+		log.Warn().Err(err).Str("target", cmd.Target).Str("action", cmd.Action).Msg("Failed to execute Miniserver command")
+	}
+}
+
+// MiniserverStatus returns the state of the Miniserver WebSocket connection
+func (s *Server) MiniserverStatus(w http.ResponseWriter, r *http.Request) {
+	client := s.currentMiniserver()
+	if client == nil {
+		jsonResponse(w, http.StatusOK, loxone.MiniserverStatus{})
+		return
+	}
+	jsonResponse(w, http.StatusOK, client.Status())
+}
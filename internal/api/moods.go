@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// executeMoodTable runs a MOOD command through the mood table of its target
+func (h *WebSocketHub) executeMoodTable(table *models.MoodTable, moodNum int, result *commandResult) error {
+	target := commandTarget{HueID: table.HueID, HueType: table.HueType, Bridge: table.Bridge}
+	result.HueID, result.HueType = target.HueID, target.HueType
+
+	switch {
+	case models.IsMoodOff(moodNum):
+		if target.HueID == "" {
+			return &commandError{status: http.StatusBadRequest, message: "mood table has no hue_id to switch off"}
+		}
+		off := false
+		cmd := models.DeviceCommand{On: &off}
+		if table.OffTransition > 0 {
+			cmd.Transition = &table.OffTransition
+		}
+		h.echo.Record(table.Target, &off, nil)
+		return h.applyState(target, cmd)
+
+	case moodNum == models.MoodAllOn:
+		if target.HueID == "" {
+			return &commandError{status: http.StatusBadRequest, message: "mood table has no hue_id to switch on"}
+		}
+		on, full := true, 100.0
+		h.echo.Record(table.Target, &on, &full)
+		return h.applyState(target, models.DeviceCommand{On: &on, Brightness: &full})
+	}
+
+	entry := loxone.MoodEntry(table, moodNum)
+	if entry == nil {
+		return &commandError{
+			status:  http.StatusNotFound,
+			message: "mood not found in mood table",
+			details: map[string]string{
+				"target":      table.Target,
+				"mood_number": strconv.Itoa(moodNum),
+			},
+		}
+	}
+
+	on := true
+	h.echo.Record(table.Target, &on, entry.Brightness)
+
+	var transition *int
+	if entry.Transition > 0 {
+		transition = &entry.Transition
+	}
+
+	// A mood without scene only sets the brightness of the target
+	if entry.SceneID == "" {
+		return h.applyState(target, models.DeviceCommand{On: &on, Brightness: entry.Brightness, Transition: transition})
+	}
+
+	client, err := h.bridges.Get(table.Bridge)
+	if err != nil {
+		return err
+	}
+	result.HueID, result.HueType = entry.SceneID, "scene"
+	return client.RecallScene(entry.SceneID, entry.Brightness, entry.Transition)
+}
+
+// validateMoodTable checks a mood table against the bridge
+func validateMoodTable(v *mappingValidator, table models.MoodTable) []FieldError {
+	errs := make([]FieldError, 0)
+	add := func(field, code, message string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if table.Target == "" {
+		add("target", "required", "target is required")
+	}
+	if table.OffTransition < 0 {
+		add("off_transition", "invalid", "off_transition must not be negative")
+	}
+	if table.HueID != "" || table.HueType != "" {
+		if table.HueType != "group" && table.HueType != "light" {
+			add("hue_type", "invalid", "hue_type must be group or light")
+		} else {
+			v.checkResource(add, "", table.Bridge, table.HueType, table.HueID)
+		}
+	} else if _, err := v.bridges.Get(table.Bridge); err != nil {
+		add("bridge", "unknown_bridge", err.Error())
+	}
+
+	numbers := make(map[int]bool)
+	for i, entry := range table.Moods {
+		field := fmt.Sprintf("moods[%d]", i)
+		switch {
+		case entry.Number <= 0 || entry.Number == models.MoodAllOn || entry.Number == models.MoodAllOff:
+			add(field+".number", "invalid", "mood number must be positive and not 777 or 778")
+		case numbers[entry.Number]:
+			add(field+".number", "duplicate", "mood "+strconv.Itoa(entry.Number)+" is defined twice")
+		}
+		numbers[entry.Number] = true
+
+		if entry.Brightness != nil && (*entry.Brightness < 0 || *entry.Brightness > 100) {
+			add(field+".brightness", "invalid", "brightness must be between 0 and 100")
+		}
+		if entry.Transition < 0 {
+			add(field+".transition", "invalid", "transition must not be negative")
+		}
+		if entry.SceneID == "" {
+			if entry.Brightness == nil {
+				add(field+".scene_id", "required", "scene_id or brightness is required")
+			} else if table.HueID == "" {
+				add(field+".scene_id", "required", "a mood without scene needs the table hue_id")
+			}
+			continue
+		}
+		if res := v.lookup(table.Bridge); res != nil && !res.Has("scene", entry.SceneID) {
+			add(field+".scene_id", "not_found", "scene "+entry.SceneID+" does not exist on the bridge")
+		}
+	}
+	return errs
+}
+
+// saveMoodTables stores the mood tables and reloads them
+func (h *Handlers) saveMoodTables(tables []models.MoodTable) error {
+	config.UpdateMoodTables(tables)
+	h.mappingManager.LoadMoodTables(tables)
+	return config.Save()
+}
+
+// GetMoodTables returns all mood tables
+func (h *Handlers) GetMoodTables(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, h.mappingManager.MoodTables())
+}
+
+// GetMoodTable returns the mood table of a Loxone target
+func (h *Handlers) GetMoodTable(w http.ResponseWriter, r *http.Request) {
+	table := h.mappingManager.MoodTable(mux.Vars(r)["target"])
+	if table == nil {
+		errorResponse(w, http.StatusNotFound, "mood table not found")
+		return
+	}
+	jsonResponse(w, http.StatusOK, table)
+}
+
+// PutMoodTable creates or replaces the mood table of a Loxone target
+func (h *Handlers) PutMoodTable(w http.ResponseWriter, r *http.Request) {
+	var table models.MoodTable
+	if err := json.NewDecoder(r.Body).Decode(&table); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	table.Target = mux.Vars(r)["target"]
+	if table.Moods == nil {
+		table.Moods = []models.MoodEntry{}
+	}
+
+	if errs := validateMoodTable(newMappingValidator(h.bridges, false), table); len(errs) > 0 {
+		jsonResponse(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  "mood table validation failed",
+			"fields": errs,
+		})
+		return
+	}
+
+	tables := config.GetMoodTables()
+	status := http.StatusCreated
+	replaced := false
+	for i := range tables {
+		if tables[i].Target == table.Target {
+			tables[i] = table
+			replaced = true
+			status = http.StatusOK
+		}
+	}
+	if !replaced {
+		tables = append(tables, table)
+	}
+
+	if err := h.saveMoodTables(tables); err != nil {
+		log.Error().Err(err).Msg("Failed to save config")
+		errorResponse(w, http.StatusInternalServerError, "failed to save config")
+		return
+	}
+	jsonResponse(w, status, table)
+}
+
+// DeleteMoodTable removes the mood table of a Loxone target
+func (h *Handlers) DeleteMoodTable(w http.ResponseWriter, r *http.Request) {
+	target := mux.Vars(r)["target"]
+
+	tables := config.GetMoodTables()
+	result := make([]models.MoodTable, 0, len(tables))
+	for _, table := range tables {
+		if table.Target != target {
+			result = append(result, table)
+		}
+	}
+	if len(result) == len(tables) {
+		errorResponse(w, http.StatusNotFound, "mood table not found")
+		return
+	}
+
+	if err := h.saveMoodTables(result); err != nil {
+		log.Error().Err(err).Msg("Failed to save config")
+	}
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// MigrateMoodMappings converts <target>_mood_<n> scene mappings into mood
+// tables and removes the migrated mappings unless dry_run is set
+func (h *Handlers) MigrateMoodMappings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	mappings := config.GetMappings()
+	tables, remaining, migrated := loxone.MigrateMoodMappings(mappings, config.GetMoodTables())
+
+	if !req.DryRun && migrated > 0 {
+		config.UpdateMappings(remaining)
+		h.mappingManager.Load(remaining)
+		if err := h.saveMoodTables(tables); err != nil {
+			log.Error().Err(err).Msg("Failed to save config after migrating moods")
+			errorResponse(w, http.StatusInternalServerError, "failed to save config")
+			return
+		}
+		log.Info().Int("moods", migrated).Msg("Migrated mood mappings to mood tables")
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"dry_run":     req.DryRun,
+		"migrated":    migrated,
+		"mood_tables": tables,
+	})
+}
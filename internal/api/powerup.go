@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// PowerUpResult is the outcome of a power-up change for a single light
+type PowerUpResult struct {
+	LightID string `json:"light_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// GetPowerUp returns the power-up behaviour of all lights
+func (h *Handlers) GetPowerUp(w http.ResponseWriter, r *http.Request) {
+	lights, err := h.hueClient.GetLights()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(lights))
+	for _, light := range lights {
+		result = append(result, map[string]interface{}{
+			"id":      light.ID,
+			"name":    light.Name,
+			"powerup": light.PowerUp,
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"presets": hue.PowerUpPresets,
+		"lights":  result,
+	})
+}
+
+// SetDevicePowerUp sets the power-up behaviour of a single light
+func (h *Handlers) SetDevicePowerUp(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var powerUp models.PowerUp
+	if err := json.NewDecoder(r.Body).Decode(&powerUp); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := hue.ValidatePowerUp(powerUp); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.hueClient.SetPowerUp(id, powerUp); err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// SetGroupPowerUp sets the power-up behaviour of all lights in a room or zone
+func (h *Handlers) SetGroupPowerUp(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var powerUp models.PowerUp
+	if err := json.NewDecoder(r.Body).Decode(&powerUp); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := hue.ValidatePowerUp(powerUp); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lightIDs, err := h.groupLights([]string{id})
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, h.applyPowerUp(lightIDs, powerUp))
+}
+
+// BulkPowerUp sets the power-up behaviour of many lights, rooms and zones at once
+func (h *Handlers) BulkPowerUp(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		All     bool           `json:"all"`
+		Lights  []string       `json:"lights"`
+		Groups  []string       `json:"groups"`
+		PowerUp models.PowerUp `json:"powerup"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := hue.ValidatePowerUp(req.PowerUp); err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var lightIDs []string
+	if req.All {
+		lights, err := h.hueClient.GetLights()
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, light := range lights {
+			lightIDs = append(lightIDs, light.ID)
+		}
+	} else {
+		lightIDs = append(lightIDs, req.Lights...)
+		if len(req.Groups) > 0 {
+			groupLightIDs, err := h.groupLights(req.Groups)
+			if err != nil {
+				errorResponse(w, http.StatusNotFound, err.Error())
+				return
+			}
+			lightIDs = append(lightIDs, groupLightIDs...)
+		}
+	}
+
+	if len(lightIDs) == 0 {
+		errorResponse(w, http.StatusBadRequest, "no lights selected")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, h.applyPowerUp(lightIDs, req.PowerUp))
+}
+
+// groupLights returns the light IDs of the given rooms and zones
+func (h *Handlers) groupLights(groupIDs []string) ([]string, error) {
+	groups, err := h.hueClient.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Group, len(groups))
+	for _, g := range groups {
+		byID[g.ID] = g
+	}
+
+	var lightIDs []string
+	for _, id := range groupIDs {
+		group, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("group not found: %s", id)
+		}
+		lightIDs = append(lightIDs, group.Lights...)
+	}
+	return lightIDs, nil
+}
+
+// applyPowerUp configures each light once and collects the per-light results
+func (h *Handlers) applyPowerUp(lightIDs []string, powerUp models.PowerUp) map[string]interface{} {
+	seen := make(map[string]bool, len(lightIDs))
+	results := make([]PowerUpResult, 0, len(lightIDs))
+	failed := 0
+
+	for _, id := range lightIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		result := PowerUpResult{LightID: id, Success: true}
+		if err := h.hueClient.SetPowerUp(id, powerUp); err != nil {
+			log.Warn().Err(err).Str("light_id", id).Msg("Failed to set power-up behaviour")
+			result.Success = false
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	return map[string]interface{}{
+		"status":  "ok",
+		"updated": len(results) - failed,
+		"failed":  failed,
+		"results": results,
+	}
+}
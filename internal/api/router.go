@@ -2,36 +2,53 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/history"
 	"github.com/sbeyeler/loxone2hue/internal/hue"
 	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/metrics"
+	"github.com/sbeyeler/loxone2hue/internal/stats"
 )
 
 // Server represents the HTTP/WebSocket server
 type Server struct {
 	router         *mux.Router
 	httpServer     *http.Server
+	plainServer    *http.Server
+	loxoneServer   *http.Server
 	wsHub          *WebSocketHub
 	handlers       *Handlers
 	hueClient      *hue.Client
+	bridges        *hue.Registry
 	mappingManager *loxone.MappingManager
+	miniserver     *loxone.MiniserverClient
+	miniserverMu   sync.RWMutex
+	history        *history.Store
+	stats          *stats.Store
+	health         *healthChecks
 }
 
 // NewServer creates a new API server
-func NewServer(hueClient *hue.Client, mappingManager *loxone.MappingManager) *Server {
+func NewServer(bridges *hue.Registry, mappingManager *loxone.MappingManager) *Server {
 	s := &Server{
 		router:         mux.NewRouter(),
-		hueClient:      hueClient,
+		hueClient:      bridges.Primary(),
+		bridges:        bridges,
 		mappingManager: mappingManager,
+		health:         newHealthChecks(),
 	}
 
-	s.wsHub = NewWebSocketHub(hueClient, mappingManager)
-	s.handlers = NewHandlers(hueClient, mappingManager)
+	s.wsHub = NewWebSocketHub(bridges, mappingManager)
+	s.handlers = NewHandlers(bridges, mappingManager)
 
 	s.setupRoutes()
 	return s
@@ -42,6 +59,7 @@ func (s *Server) setupRoutes() {
 	// Middleware
 	s.router.Use(corsMiddleware)
 	s.router.Use(loggingMiddleware)
+	s.router.Use(authMiddleware)
 
 	// API routes
 	api := s.router.PathPrefix("/api").Subrouter()
@@ -56,30 +74,91 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/devices", s.handlers.GetDevices).Methods("GET")
 	api.HandleFunc("/devices/{id}", s.handlers.GetDevice).Methods("GET")
 	api.HandleFunc("/devices/{id}", s.handlers.SetDevice).Methods("PUT", "POST")
+	api.HandleFunc("/devices/{id}/powerup", s.handlers.SetDevicePowerUp).Methods("PUT")
+	api.HandleFunc("/devices/{id}/name", s.handlers.RenameDevice).Methods("PUT")
 
 	// Group endpoints
 	api.HandleFunc("/groups", s.handlers.GetGroups).Methods("GET")
+	api.HandleFunc("/groups", s.handlers.CreateZone).Methods("POST")
 	api.HandleFunc("/groups/{id}", s.handlers.GetGroup).Methods("GET")
 	api.HandleFunc("/groups/{id}", s.handlers.SetGroup).Methods("PUT", "POST")
+	api.HandleFunc("/groups/{id}", s.handlers.DeleteGroup).Methods("DELETE")
+	api.HandleFunc("/groups/{id}/lights/{lightId}", s.handlers.AddGroupLight).Methods("PUT")
+	api.HandleFunc("/groups/{id}/lights/{lightId}", s.handlers.RemoveGroupLight).Methods("DELETE")
+	api.HandleFunc("/groups/{id}/powerup", s.handlers.SetGroupPowerUp).Methods("PUT")
+	api.HandleFunc("/groups/{id}/name", s.handlers.RenameGroup).Methods("PUT")
+
+	// Inventory endpoints
+	api.HandleFunc("/inventory", s.handlers.GetInventory).Methods("GET")
+	api.HandleFunc("/inventory/{id}/name", s.handlers.RenameInventoryDevice).Methods("PUT")
+
+	// Power-up behaviour endpoints
+	api.HandleFunc("/powerup", s.handlers.GetPowerUp).Methods("GET")
+	api.HandleFunc("/powerup", s.handlers.BulkPowerUp).Methods("PUT")
 
 	// Scene endpoints
 	api.HandleFunc("/scenes", s.handlers.GetScenes).Methods("GET")
 	api.HandleFunc("/scenes/{id}/activate", s.handlers.ActivateScene).Methods("POST")
 
+	// Entertainment endpoints
+	api.HandleFunc("/entertainment", s.handlers.GetEntertainment).Methods("GET")
+	api.HandleFunc("/entertainment", s.handlers.CreateEntertainment).Methods("POST")
+	api.HandleFunc("/entertainment/stream", s.handlers.GetStream).Methods("GET")
+	api.HandleFunc("/entertainment/stream", s.handlers.StopStream).Methods("DELETE")
+	api.HandleFunc("/entertainment/{id}/stream", s.handlers.StartStream).Methods("POST")
+
 	// Mapping endpoints
 	api.HandleFunc("/mappings", s.handlers.GetMappings).Methods("GET")
 	api.HandleFunc("/mappings", s.handlers.CreateMapping).Methods("POST")
+	api.HandleFunc("/mappings/validate", s.handlers.ValidateMappings).Methods("GET")
+	api.HandleFunc("/mappings/generate", s.handlers.GenerateMappings).Methods("POST")
 	api.HandleFunc("/mappings/{id}", s.handlers.UpdateMapping).Methods("PUT")
 	api.HandleFunc("/mappings/{id}", s.handlers.DeleteMapping).Methods("DELETE")
 	api.HandleFunc("/mappings/export", s.handlers.ExportMappings).Methods("GET")
 	api.HandleFunc("/mappings/import", s.handlers.ImportMappings).Methods("POST")
 
+	// Mood table endpoints
+	api.HandleFunc("/moods", s.handlers.GetMoodTables).Methods("GET")
+	api.HandleFunc("/moods/migrate", s.handlers.MigrateMoodMappings).Methods("POST")
+	api.HandleFunc("/moods/{target}", s.handlers.GetMoodTable).Methods("GET")
+	api.HandleFunc("/moods/{target}", s.handlers.PutMoodTable).Methods("PUT")
+	api.HandleFunc("/moods/{target}", s.handlers.DeleteMoodTable).Methods("DELETE")
+
+	// Loxone Config templates
+	api.HandleFunc("/loxone/templates/outputs.xml", s.LoxoneOutputTemplate).Methods("GET")
+	api.HandleFunc("/loxone/templates/inputs.xml", s.LoxoneInputTemplate).Methods("GET")
+	api.HandleFunc("/loxone/status", s.LoxoneStatus).Methods("GET")
+	api.HandleFunc("/loxone/miniserver", s.MiniserverStatus).Methods("GET")
+
+	// Loxone structure file
+	api.HandleFunc("/loxone/structure", s.handlers.GetStructure).Methods("GET")
+	api.HandleFunc("/loxone/structure", s.handlers.UploadStructure).Methods("POST")
+	api.HandleFunc("/loxone/structure/fetch", s.handlers.FetchStructure).Methods("POST")
+	api.HandleFunc("/loxone/structure/match", s.handlers.MatchStructure).Methods("POST")
+
+	// Audit log
+	api.HandleFunc("/history", s.GetHistory).Methods("GET")
+	api.HandleFunc("/history/live", s.HistoryLive).Methods("GET")
+
+	// Usage statistics
+	api.HandleFunc("/stats", s.GetStats).Methods("GET")
+
+	// Authentication endpoints
+	api.HandleFunc("/auth", s.handlers.GetAuthStatus).Methods("GET")
+	api.HandleFunc("/auth/tokens", s.handlers.GetTokens).Methods("GET")
+	api.HandleFunc("/auth/tokens", s.handlers.CreateToken).Methods("POST")
+	api.HandleFunc("/auth/tokens/{id}", s.handlers.DeleteToken).Methods("DELETE")
+
 	// Config endpoints
 	api.HandleFunc("/config", s.handlers.GetConfig).Methods("GET")
 	api.HandleFunc("/config", s.handlers.UpdateConfig).Methods("PUT")
+	api.HandleFunc("/config/versions", s.handlers.GetConfigVersions).Methods("GET")
+	api.HandleFunc("/config/versions/{id}/restore", s.handlers.RestoreConfigVersion).Methods("POST")
 
 	// Health check
 	api.HandleFunc("/health", s.handlers.Health).Methods("GET")
+	api.HandleFunc("/health/live", s.HealthLive).Methods("GET")
+	api.HandleFunc("/health/ready", s.HealthReady).Methods("GET")
 
 	// Swagger documentation
 	api.HandleFunc("/swagger.json", s.SwaggerSpec).Methods("GET")
@@ -89,34 +168,93 @@ func (s *Server) setupRoutes() {
 	// WebSocket endpoint
 	s.router.HandleFunc("/ws", s.wsHub.HandleWebSocket)
 
+	// Prometheus metrics
+	s.router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
 	// Serve static files (frontend)
-	s.router.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/dist")))
+	s.router.PathPrefix("/").Handler(frontendHandler("./web/dist"))
 }
 
-// Start starts the HTTP server
-func (s *Server) Start(ctx context.Context, host string, port int) error {
-	addr := fmt.Sprintf("%s:%d", host, port)
-
-	s.httpServer = &http.Server{
+// newHTTPServer creates an http.Server with the gateway timeouts
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
 		Addr:         addr,
-		Handler:      s.router,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+}
+
+// Start starts the HTTP server, with TLS enabled it serves HTTPS on the
+// configured port and optionally plain HTTP on tls.http_port
+func (s *Server) Start(ctx context.Context, cfg config.ServerConfig) error {
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	handler := ingressHandler(s.router)
+	s.httpServer = newHTTPServer(addr, handler)
+
+	if cfg.TLS.Enabled {
+		cert, err := loadCertificate(cfg.TLS)
+		if err != nil {
+			return err
+		}
+		s.httpServer.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+
+		if cfg.TLS.HTTPPort > 0 {
+			plain := handler
+			if cfg.TLS.RedirectHTTP {
+				plain = httpsRedirect(handler, cfg.Port)
+			}
+			s.plainServer = newHTTPServer(fmt.Sprintf("%s:%d", cfg.Host, cfg.TLS.HTTPPort), plain)
+		}
+	}
+
+	if cfg.Ingress.LoxonePort > 0 {
+		s.loxoneServer = newHTTPServer(fmt.Sprintf("%s:%d", cfg.Host, cfg.Ingress.LoxonePort), loxoneHandler(s.router))
+	}
 
 	// Start WebSocket hub
 	go s.wsHub.Run(ctx)
 
-	log.Info().Str("addr", addr).Msg("Starting HTTP server")
-
-	errChan := make(chan error, 1)
+	errChan := make(chan error, 3)
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.httpServer.TLSConfig != nil {
+			log.Info().Str("addr", addr).Msg("Starting HTTPS server")
+			err = s.httpServer.ListenAndServeTLS("", "")
+		} else {
+			log.Info().Str("addr", addr).Msg("Starting HTTP server")
+			err = s.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errChan <- err
 		}
 	}()
 
+	if s.loxoneServer != nil {
+		if len(config.GetAuth().Tokens) == 0 {
+			log.Warn().Msg("Loxone listener is unprotected until an API token is created")
+		}
+		go func() {
+			log.Info().Str("addr", s.loxoneServer.Addr).Msg("Starting Loxone HTTP server")
+			if err := s.loxoneServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errChan <- err
+			}
+		}()
+	}
+
+	if s.plainServer != nil {
+		go func() {
+			log.Info().Str("addr", s.plainServer.Addr).Bool("redirect", cfg.TLS.RedirectHTTP).Msg("Starting plain HTTP server")
+			if err := s.plainServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errChan <- err
+			}
+		}()
+	}
+
 	select {
 	case <-ctx.Done():
 		return s.Shutdown()
@@ -131,15 +269,27 @@ func (s *Server) Shutdown() error {
 	defer cancel()
 
 	log.Info().Msg("Shutting down HTTP server")
+	for _, server := range []*http.Server{s.plainServer, s.loxoneServer} {
+		if server == nil {
+			continue
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			log.Warn().Err(err).Str("addr", server.Addr).Msg("Failed to shut down HTTP server")
+		}
+	}
 	return s.httpServer.Shutdown(ctx)
 }
 
-// corsMiddleware adds CORS headers
+// corsMiddleware adds CORS headers for the origins in auth.allowed_origins
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		origin := r.Header.Get("Origin")
+		if origin != "" && originAllowed(origin, r.Host, config.GetAuth().AllowedOrigins) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Add("Vary", "Origin")
+		}
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -165,6 +315,16 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(wrapped, r)
 
+		// The route template keeps the label set small, e.g. /api/devices/{id}
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(wrapped.statusCode))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+
 		log.Debug().
 			Str("method", r.Method).
 			Str("path", r.URL.Path).
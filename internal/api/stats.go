@@ -0,0 +1,246 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+	"github.com/sbeyeler/loxone2hue/internal/stats"
+)
+
+// Limits of /api/stats
+const (
+	defaultStatsDays = 7
+	maxStatsDays     = 366
+)
+
+// lightStats is the usage of one light in /api/stats
+type lightStats struct {
+	ID      string      `json:"id"`
+	Bridge  string      `json:"bridge,omitempty"`
+	Name    string      `json:"name,omitempty"`
+	ModelID string      `json:"model_id,omitempty"`
+	Room    string      `json:"room,omitempty"`
+	Watts   float64     `json:"watts,omitempty"`
+	Total   stats.Day   `json:"total"`
+	Days    []stats.Day `json:"days"`
+}
+
+// roomStats is the usage of one room in /api/stats
+type roomStats struct {
+	ID     string      `json:"id"`
+	Bridge string      `json:"bridge,omitempty"`
+	Name   string      `json:"name"`
+	Lights []string    `json:"lights"`
+	Total  stats.Day   `json:"total"`
+	Days   []stats.Day `json:"days"`
+}
+
+// SetStats enables the usage statistics
+func (s *Server) SetStats(store *stats.Store) {
+	s.stats = store
+	s.wsHub.stats = store
+}
+
+// recordLightState stores the state of a light after a HUE event
+func (h *WebSocketHub) recordLightState(bridge string, client *hue.Client, event hue.Event) {
+	if h.stats == nil || event.Type != "light" {
+		return
+	}
+
+	light, err := client.GetLight(event.ID)
+	if err != nil {
+		return
+	}
+	at := event.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	h.stats.Record(stats.Key(bridge, event.ID), stats.State{On: light.State.On, Brightness: light.State.Brightness}, at)
+}
+
+// seedLightStats records the current state of every light of a bridge
+// when its event stream connects. Lights that do not change afterwards are
+// counted from then on, changes made while the stream was down end there.
+func (h *WebSocketHub) seedLightStats(bridge string, client *hue.Client) {
+	if h.stats == nil {
+		return
+	}
+
+	lights, err := client.GetLights()
+	if err != nil {
+		log.Warn().Err(err).Str("bridge", bridge).Msg("Failed to read light states for statistics")
+		return
+	}
+	now := time.Now()
+	for _, light := range lights {
+		h.stats.Record(stats.Key(bridge, light.ID), stats.State{On: light.State.On, Brightness: light.State.Brightness}, now)
+	}
+}
+
+// parseStatsRange reads days or from/to (YYYY-MM-DD, inclusive) and returns
+// the local day boundaries
+func parseStatsRange(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	if q.Get("from") != "" || q.Get("to") != "" {
+		from, to := today, today
+		var err error
+		if v := q.Get("from"); v != "" {
+			if from, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+				return from, to, fmt.Errorf("invalid from date %q, use YYYY-MM-DD", v)
+			}
+		}
+		if v := q.Get("to"); v != "" {
+			if to, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+				return from, to, fmt.Errorf("invalid to date %q, use YYYY-MM-DD", v)
+			}
+		}
+		to = to.AddDate(0, 0, 1)
+		if !to.After(from) {
+			return from, to, fmt.Errorf("from must not be after to")
+		}
+		if to.Sub(from) > maxStatsDays*24*time.Hour {
+			return from, to, fmt.Errorf("range must not exceed %d days", maxStatsDays)
+		}
+		return from, to, nil
+	}
+
+	days := defaultStatsDays
+	if v := q.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return today, today, fmt.Errorf("days must be a positive number")
+		}
+		days = min(n, maxStatsDays)
+	}
+	return today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1), nil
+}
+
+// GetStats returns the daily on-time, average brightness and estimated
+// energy per light and room
+func (s *Server) GetStats(w http.ResponseWriter, r *http.Request) {
+	if s.stats == nil {
+		errorResponse(w, http.StatusServiceUnavailable, "statistics not available")
+		return
+	}
+	from, to, err := parseStatsRange(r)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	wattage := config.GetStats().Wattage
+	keys := s.stats.Keys()
+	recorded := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		recorded[key] = true
+	}
+
+	lights := make([]lightStats, 0)
+	rooms := make([]roomStats, 0)
+	lightDays := make(map[string][]stats.Day)
+
+	addLight := func(bridge string, light *models.Light, room string) error {
+		key := stats.Key(bridge, light.ID)
+		days, err := s.stats.Daily([]string{key}, from, to)
+		if err != nil {
+			return err
+		}
+		entry := lightStats{ID: light.ID, Bridge: bridge, Name: light.Name, ModelID: light.ModelID, Room: room, Watts: wattage[light.ModelID]}
+		if entry.Watts > 0 {
+			for i := range days {
+				days[i].Energy(entry.Watts)
+			}
+		}
+		entry.Days, entry.Total = days, stats.Total(days)
+		lights = append(lights, entry)
+		lightDays[key] = days
+		delete(recorded, key)
+		return nil
+	}
+
+	bridgeIDs := append([]string{""}, s.bridges.IDs()...)
+	for _, bridge := range bridgeIDs {
+		client, err := s.bridges.Get(bridge)
+		if err != nil || !client.IsConfigured() {
+			continue
+		}
+		bridgeLights, err := client.GetLights()
+		if err != nil {
+			continue
+		}
+		groups, _ := client.GetGroups()
+
+		roomOf := make(map[string]string)
+		for _, group := range groups {
+			if group.Type != "room" {
+				continue
+			}
+			for _, id := range group.Lights {
+				roomOf[id] = group.Name
+			}
+		}
+
+		for _, light := range bridgeLights {
+			if err := addLight(bridge, light, roomOf[light.ID]); err != nil {
+				errorResponse(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		for _, group := range groups {
+			if group.Type != "room" || len(group.Lights) == 0 {
+				continue
+			}
+			keys := make([]string, 0, len(group.Lights))
+			for _, id := range group.Lights {
+				keys = append(keys, stats.Key(bridge, id))
+			}
+			days, err := s.stats.Daily(keys, from, to)
+			if err != nil {
+				errorResponse(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			// The room consumes what its lights consume
+			for _, key := range keys {
+				for i, day := range lightDays[key] {
+					days[i].AddEnergy(day.EnergyWh)
+				}
+			}
+			rooms = append(rooms, roomStats{
+				ID: group.ID, Bridge: bridge, Name: group.Name, Lights: group.Lights,
+				Days: days, Total: stats.Total(days),
+			})
+		}
+	}
+
+	// Lights with recorded history that no bridge reports anymore
+	for _, key := range keys {
+		if !recorded[key] {
+			continue
+		}
+		bridge, id, found := strings.Cut(key, "/")
+		if !found {
+			bridge, id = "", key
+		}
+		if err := addLight(bridge, &models.Light{ID: id}, ""); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"from":   from.Format("2006-01-02"),
+		"to":     to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"lights": lights,
+		"rooms":  rooms,
+	})
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// maxStructureSize limits uploaded structure files
+const maxStructureSize = 32 << 20
+
+// GetStructure returns the imported Loxone structure
+func (h *Handlers) GetStructure(w http.ResponseWriter, r *http.Request) {
+	h.structureMu.RLock()
+	structure := h.structure
+	h.structureMu.RUnlock()
+
+	if structure == nil {
+		errorResponse(w, http.StatusNotFound, "no structure file imported")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, structure)
+}
+
+// UploadStructure imports an uploaded LoxAPP3.json
+func (h *Handlers) UploadStructure(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStructureSize))
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "failed to read structure file: "+err.Error())
+		return
+	}
+
+	h.setStructure(w, data)
+}
+
+// FetchStructure downloads LoxAPP3.json from the configured Miniserver
+func (h *Handlers) FetchStructure(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get().Loxone
+
+	data, err := loxone.FetchStructure(cfg.MiniserverIP, cfg.Username, cfg.Password)
+	if err != nil {
+		errorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	h.setStructure(w, data)
+}
+
+func (h *Handlers) setStructure(w http.ResponseWriter, data []byte) {
+	structure, err := loxone.ParseStructure(data)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.structureMu.Lock()
+	h.structure = structure
+	h.structureMu.Unlock()
+
+	log.Info().
+		Str("miniserver", structure.MiniserverName).
+		Int("rooms", len(structure.Rooms)).
+		Int("controls", len(structure.Controls)).
+		Msg("Loxone structure file imported")
+
+	jsonResponse(w, http.StatusOK, structure)
+}
+
+// MatchStructure proposes mappings for the imported structure by fuzzy name
+// matching and merges them into the existing mappings unless dry_run is set
+func (h *Handlers) MatchStructure(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		DryRun   bool    `json:"dry_run"`
+		MinScore float64 `json:"min_score"`
+		Bridge   string  `json:"bridge,omitempty"`
+	}{MinScore: 0.6}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	h.structureMu.RLock()
+	structure := h.structure
+	h.structureMu.RUnlock()
+
+	if structure == nil {
+		errorResponse(w, http.StatusNotFound, "no structure file imported")
+		return
+	}
+
+	client, err := h.bridges.Get(req.Bridge)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groups, err := client.GetGroups()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	lights, err := client.GetLights()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	existing := config.GetMappings()
+	matches := loxone.MatchStructure(structure, groups, lights, existing, req.MinScore, req.Bridge)
+
+	if req.DryRun {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"dry_run": true,
+			"matches": matches,
+			"total":   len(matches),
+		})
+		return
+	}
+
+	proposed := make([]models.Mapping, 0, len(matches))
+	for _, match := range matches {
+		proposed = append(proposed, match.Mapping)
+	}
+
+	// Same merge as ImportMappings
+	resultMappings, imported, updated := mergeMappings(existing, proposed)
+
+	config.UpdateMappings(resultMappings)
+	conflicts := h.mappingManager.Load(resultMappings)
+
+	if err := config.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save config after structure matching")
+		errorResponse(w, http.StatusInternalServerError, "failed to save config")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"dry_run":   false,
+		"matches":   matches,
+		"imported":  imported,
+		"updated":   updated,
+		"total":     len(resultMappings),
+		"conflicts": conflicts,
+	})
+}
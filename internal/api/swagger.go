@@ -4,6 +4,7 @@ import (
 	"embed"
 	"html/template"
 	"net/http"
+	"strings"
 )
 
 //go:embed swagger-ui.html
@@ -12,13 +13,18 @@ var swaggerUIHTML embed.FS
 // SwaggerUI serves the Swagger UI page
 func (s *Server) SwaggerUI(w http.ResponseWriter, r *http.Request) {
 	tmpl := template.Must(template.ParseFS(swaggerUIHTML, "swagger-ui.html"))
-	tmpl.Execute(w, nil)
+	tmpl.Execute(w, map[string]string{"BasePath": ingressPath(r)})
 }
 
-// SwaggerSpec serves the OpenAPI specification
+// SwaggerSpec serves the OpenAPI specification, behind ingress with the
+// ingress prefix in the server URL
 func (s *Server) SwaggerSpec(w http.ResponseWriter, r *http.Request) {
+	spec := openAPISpec
+	if prefix := ingressPath(r); prefix != "" {
+		spec = strings.Replace(spec, `"url": "/api"`, `"url": "`+prefix+`/api"`, 1)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(openAPISpec))
+	w.Write([]byte(spec))
 }
 
 const openAPISpec = `{
@@ -69,6 +75,34 @@ const openAPISpec = `{
     {
       "name": "Config",
       "description": "Gateway Konfiguration"
+    },
+    {
+      "name": "Entertainment",
+      "description": "HUE Entertainment Streaming (DTLS/HueStream v2) für Party- und Musikmodi"
+    },
+    {
+      "name": "PowerUp",
+      "description": "Einschaltverhalten der Lampen nach Stromausfall"
+    },
+    {
+      "name": "Inventory",
+      "description": "Inventar aller HUE Geräte mit Modell, Firmware und Verbindungsstatus"
+    },
+    {
+      "name": "Moods",
+      "description": "Stimmungstabellen für Loxone Lichtsteuerungen"
+    },
+    {
+      "name": "Auth",
+      "description": "API-Tokens mit Rollen (read, control, admin)"
+    },
+    {
+      "name": "History",
+      "description": "Audit-Log der Befehle und HUE Zustandsänderungen"
+    },
+    {
+      "name": "Stats",
+      "description": "Nutzungsstatistik der Lichter und Räume"
     }
   ],
   "paths": {
@@ -91,11 +125,69 @@ const openAPISpec = `{
         }
       }
     },
+    "/health/live": {
+      "get": {
+        "tags": ["Health"],
+        "summary": "Liveness",
+        "description": "Prüft nur, ob der Prozess läuft und der WebSocket-Hub arbeitet. Geeignet für Watchdogs, die den Dienst neu starten.",
+        "responses": {
+          "200": {
+            "description": "Gateway läuft",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LivenessResponse"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "WebSocket-Hub gestoppt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LivenessResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/health/ready": {
+      "get": {
+        "tags": ["Health"],
+        "summary": "Readiness",
+        "description": "Prüft alle Komponenten: Erreichbarkeit der Bridges (Ergebnis 30 s zwischengespeichert), Verbindung und letztes Event des Event-Streams, Schreibrechte im Konfigurationsverzeichnis, Gültigkeit der Mappings, Verbindung zum Miniserver und WebSocket-Hub. Fällt eine kritische Komponente aus, antwortet der Endpoint mit 503. Probleme unkritischer Komponenten ergeben den Status degraded.",
+        "responses": {
+          "200": {
+            "description": "Bereit (ok oder degraded)",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ReadinessResponse"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "Nicht bereit",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ReadinessResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
     "/../ws": {
       "get": {
         "tags": ["Loxone"],
         "summary": "Loxone Befehl ausführen",
-        "description": "Führt einen Loxone-Befehl aus. Dieser Endpoint ist für Loxone Virtual Outputs gedacht.\n\n## Verfügbare Befehle\n\n| Befehl | Beschreibung | Beispiel |\n|--------|--------------|----------|\n| SET id ON | Licht/Gruppe einschalten | SET wz_decke ON |\n| SET id OFF | Licht/Gruppe ausschalten | SET wz_decke OFF |\n| SET id BRI n | Helligkeit setzen (0-100%) | SET wz_decke BRI 75 |\n| SET id CT n | Farbtemperatur (2000-6500K) | SET wz_decke CT 4000 |\n| SET id COLOR hex | Farbe setzen | SET wz_decke COLOR #FF5500 |\n| SCENE id | Szene aktivieren | SCENE sz_relax |\n| MOOD id n | Stimmung aktivieren (Lichtsteuerung) | MOOD wohnzimmer 1 |\n| GET id STATUS | Status abfragen | GET wz_decke STATUS |\n\n## MOOD-Befehl für Lichtsteuerungs-Baustein\n\nDer MOOD-Befehl ist für den Loxone Lichtsteuerungs-Baustein konzipiert:\n- MOOD 0: Schaltet die zugehörige Gruppe/Licht aus\n- MOOD 1-9: Aktiviert die entsprechende Szene\n\nBenötigte Mappings für MOOD:\n- target -> Gruppe (für Mood 0 = Aus)\n- target_mood_1 -> Szene (für Mood 1)\n- target_mood_2 -> Szene (für Mood 2)\n- etc.",
+        "description": "Führt einen Loxone-Befehl aus. Dieser Endpoint ist für Loxone Virtual Outputs gedacht.\n\n## Verfügbare Befehle\n\n| Befehl | Beschreibung | Beispiel |\n|--------|--------------|----------|\n| SET id ON | Licht/Gruppe einschalten | SET wz_decke ON |\n| SET id OFF | Licht/Gruppe ausschalten | SET wz_decke OFF |\n| SET id BRI n | Helligkeit setzen (0-100%) | SET wz_decke BRI 75 |\n| SET id CT n | Farbtemperatur (2000-6500K) | SET wz_decke CT 4000 |\n| SET id COLOR hex | Farbe setzen | SET wz_decke COLOR #FF5500 |\n| SET id COLOR rgb:wert | Farbe als Loxone RGB-Wert (BBBGGGRRR, je 0-100) | SET wz_decke COLOR rgb:100050000 |\n| SET id GRADIENT hex,hex[,...] [mode] | Farbverlauf setzen (Gradient-Lampen) | SET wz_strip GRADIENT #FF0000,#0000FF |\n| SCENE id | Szene aktivieren | SCENE sz_relax |\n| MOOD id n | Stimmung aktivieren (Lichtsteuerung) | MOOD wohnzimmer 1 |\n| STREAM id muster [farbe] | Entertainment-Stream starten (static, rainbow, pulse, strobe, party) | STREAM party rainbow |\n| STREAM id OFF | Entertainment-Stream stoppen | STREAM party OFF |\n| GET id STATUS | Status abfragen | GET wz_decke STATUS |\n\n## MOOD-Befehl für Lichtsteuerungs-Baustein\n\nDer MOOD-Befehl ist für den Loxone Lichtsteuerungs-Baustein konzipiert:\n- MOOD 0 und 778: Schaltet die zugehörige Gruppe/Licht aus\n- MOOD 777: Schaltet die Gruppe/Licht mit voller Helligkeit ein\n- MOOD 1-9: Aktiviert die entsprechende Szene\n\nBenötigte Mappings für MOOD:\n- target -> Gruppe (für Mood 0, 777 und 778)\n- target_mood_1 -> Szene (für Mood 1)\n- target_mood_2 -> Szene (für Mood 2)\n- etc.",
         "parameters": [
           {
             "name": "cmd",
@@ -354,6 +446,43 @@ const openAPISpec = `{
             }
           }
         }
+      },
+      "post": {
+        "tags": ["Groups"],
+        "summary": "Zone erstellen",
+        "description": "Erstellt eine neue Zone mit den angegebenen Lampen. Räume können nicht über den Gateway erstellt werden.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ZoneCreate"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Zone erstellt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Group"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Fehler",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
       }
     },
     "/groups/{id}": {
@@ -432,32 +561,11 @@ const openAPISpec = `{
             }
           }
         }
-      }
-    },
-    "/scenes": {
-      "get": {
-        "tags": ["Scenes"],
-        "summary": "Alle Szenen abrufen",
-        "description": "Gibt eine Liste aller HUE Szenen zurück. Jede Szene ist einem Raum oder einer Zone zugeordnet (group_id). Die Szenen werden im Frontend mit dem Format 'Raum - Szenenname' angezeigt.",
-        "responses": {
-          "200": {
-            "description": "Liste der Szenen",
-            "content": {
-              "application/json": {
-                "schema": {
-                  "$ref": "#/components/schemas/ScenesResponse"
-                }
-              }
-            }
-          }
-        }
-      }
-    },
-    "/scenes/{id}/activate": {
-      "post": {
-        "tags": ["Scenes"],
-        "summary": "Szene aktivieren",
-        "description": "Aktiviert eine HUE Szene.",
+      },
+      "delete": {
+        "tags": ["Groups"],
+        "summary": "Zone löschen",
+        "description": "Löscht eine Zone. Räume können nicht gelöscht werden. Verweisen Mappings (auch als Mitglied einer virtuellen Gruppe) oder Stimmungstabellen auf die Zone oder ihre Szenen, wird das Löschen mit 409 abgelehnt, ausser force=true ist gesetzt.",
         "parameters": [
           {
             "name": "id",
@@ -466,16 +574,45 @@ const openAPISpec = `{
             "schema": {
               "type": "string"
             },
-            "description": "ID der Szene"
+            "description": "ID der Zone"
+          },
+          {
+            "name": "force",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "boolean"
+            },
+            "description": "Auch löschen, wenn Mappings betroffen sind"
           }
         ],
         "responses": {
           "200": {
-            "description": "Szene aktiviert",
+            "description": "Zone gelöscht",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/StatusResponse"
+                  "$ref": "#/components/schemas/GroupDeleteResult"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Fehler",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "409": {
+            "description": "Zone wird von Mappings verwendet",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GroupDeleteResult"
                 }
               }
             }
@@ -483,45 +620,55 @@ const openAPISpec = `{
         }
       }
     },
-    "/mappings": {
+    "/powerup": {
       "get": {
-        "tags": ["Mappings"],
-        "summary": "Alle Mappings abrufen",
-        "description": "Gibt eine Liste aller Loxone zu HUE Mappings zurück.",
+        "tags": ["PowerUp"],
+        "summary": "Einschaltverhalten abrufen",
+        "description": "Gibt das Einschaltverhalten (powerup) aller Lampen zurück.",
         "responses": {
           "200": {
-            "description": "Liste der Mappings",
+            "description": "Einschaltverhalten",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/MappingsResponse"
+                  "$ref": "#/components/schemas/PowerUpListResponse"
                 }
               }
             }
           }
         }
       },
-      "post": {
-        "tags": ["Mappings"],
-        "summary": "Mapping erstellen",
-        "description": "Erstellt ein neues Loxone zu HUE Mapping.",
+      "put": {
+        "tags": ["PowerUp"],
+        "summary": "Einschaltverhalten gesammelt setzen",
+        "description": "Setzt das Einschaltverhalten für mehrere Lampen, Räume und Zonen oder mit **all** für alle Lampen. Jede Lampe wird nur einmal konfiguriert, das Ergebnis wird pro Lampe zurückgegeben.",
         "requestBody": {
           "required": true,
           "content": {
             "application/json": {
               "schema": {
-                "$ref": "#/components/schemas/MappingCreate"
+                "$ref": "#/components/schemas/PowerUpBulkRequest"
               }
             }
           }
         },
         "responses": {
-          "201": {
-            "description": "Mapping erstellt",
+          "200": {
+            "description": "Ergebnis pro Lampe",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/Mapping"
+                  "$ref": "#/components/schemas/PowerUpBulkResult"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültige Einstellung",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
                 }
               }
             }
@@ -529,11 +676,11 @@ const openAPISpec = `{
         }
       }
     },
-    "/mappings/{id}": {
+    "/devices/{id}/powerup": {
       "put": {
-        "tags": ["Mappings"],
-        "summary": "Mapping aktualisieren",
-        "description": "Aktualisiert ein bestehendes Mapping.",
+        "tags": ["PowerUp"],
+        "summary": "Einschaltverhalten einer Lampe setzen",
+        "description": "Setzt das Einschaltverhalten einer einzelnen Lampe.",
         "parameters": [
           {
             "name": "id",
@@ -542,7 +689,7 @@ const openAPISpec = `{
             "schema": {
               "type": "string"
             },
-            "description": "ID des Mappings"
+            "description": "ID der Lampe"
           }
         ],
         "requestBody": {
@@ -550,24 +697,24 @@ const openAPISpec = `{
           "content": {
             "application/json": {
               "schema": {
-                "$ref": "#/components/schemas/Mapping"
+                "$ref": "#/components/schemas/PowerUp"
               }
             }
           }
         },
         "responses": {
           "200": {
-            "description": "Mapping aktualisiert",
+            "description": "Erfolgreich",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/Mapping"
+                  "$ref": "#/components/schemas/StatusResponse"
                 }
               }
             }
           },
-          "404": {
-            "description": "Mapping nicht gefunden",
+          "400": {
+            "description": "Ungültige Einstellung",
             "content": {
               "application/json": {
                 "schema": {
@@ -577,11 +724,13 @@ const openAPISpec = `{
             }
           }
         }
-      },
-      "delete": {
-        "tags": ["Mappings"],
-        "summary": "Mapping löschen",
-        "description": "Löscht ein Mapping.",
+      }
+    },
+    "/groups/{id}/powerup": {
+      "put": {
+        "tags": ["PowerUp"],
+        "summary": "Einschaltverhalten eines Raums setzen",
+        "description": "Setzt das Einschaltverhalten aller Lampen eines Raums oder einer Zone.",
         "parameters": [
           {
             "name": "id",
@@ -590,22 +739,42 @@ const openAPISpec = `{
             "schema": {
               "type": "string"
             },
-            "description": "ID des Mappings"
+            "description": "ID des Raums oder der Zone"
           }
         ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/PowerUp"
+              }
+            }
+          }
+        },
         "responses": {
           "200": {
-            "description": "Mapping gelöscht",
+            "description": "Ergebnis pro Lampe",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/StatusResponse"
+                  "$ref": "#/components/schemas/PowerUpBulkResult"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültige Einstellung",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
                 }
               }
             }
           },
           "404": {
-            "description": "Mapping nicht gefunden",
+            "description": "Gruppe nicht gefunden",
             "content": {
               "application/json": {
                 "schema": {
@@ -617,18 +786,18 @@ const openAPISpec = `{
         }
       }
     },
-    "/mappings/export": {
+    "/inventory": {
       "get": {
-        "tags": ["Mappings"],
-        "summary": "Mappings exportieren",
-        "description": "Exportiert alle Mappings als JSON-Backup. Die Datei enthält Versionsinformationen, einen Zeitstempel und alle Mappings.",
+        "tags": ["Inventory"],
+        "summary": "Geräte-Inventar",
+        "description": "Gibt alle HUE Geräte zurück, verknüpft mit Lampen, Raum, Zigbee-Verbindung und Firmware-Update-Status.",
         "responses": {
           "200": {
-            "description": "Backup der Mappings",
+            "description": "Inventar",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/MappingsBackup"
+                  "$ref": "#/components/schemas/InventoryResponse"
                 }
               }
             }
@@ -636,34 +805,45 @@ const openAPISpec = `{
         }
       }
     },
-    "/mappings/import": {
-      "post": {
-        "tags": ["Mappings"],
-        "summary": "Mappings importieren",
-        "description": "Importiert Mappings aus einem zuvor exportierten Backup. Unterstützt zwei Modi:\n\n- **replace**: Alle bestehenden Mappings werden gelöscht und durch die importierten ersetzt.\n- **merge**: Bestehende Mappings bleiben erhalten. Mappings mit gleicher Loxone ID werden aktualisiert, neue werden hinzugefügt.",
+    "/inventory/{id}/name": {
+      "put": {
+        "tags": ["Inventory"],
+        "summary": "Gerät umbenennen",
+        "description": "Ändert den Namen eines HUE Geräts (max. 32 Zeichen).",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID des Geräts"
+          }
+        ],
         "requestBody": {
           "required": true,
           "content": {
             "application/json": {
               "schema": {
-                "$ref": "#/components/schemas/ImportRequest"
+                "$ref": "#/components/schemas/RenameRequest"
               }
             }
           }
         },
         "responses": {
           "200": {
-            "description": "Import erfolgreich",
+            "description": "Umbenannt",
             "content": {
               "application/json": {
                 "schema": {
-                  "$ref": "#/components/schemas/ImportResult"
+                  "$ref": "#/components/schemas/StatusResponse"
                 }
               }
             }
           },
           "400": {
-            "description": "Ungültiges Backup-Format",
+            "description": "Ungültiger Name",
             "content": {
               "application/json": {
                 "schema": {
@@ -675,41 +855,35 @@ const openAPISpec = `{
         }
       }
     },
-    "/config": {
-      "get": {
-        "tags": ["Config"],
-        "summary": "Konfiguration abrufen",
-        "description": "Gibt die aktuelle Gateway-Konfiguration zurück (ohne sensible Daten).",
-        "responses": {
-          "200": {
-            "description": "Konfiguration",
-            "content": {
-              "application/json": {
-                "schema": {
-                  "$ref": "#/components/schemas/ConfigResponse"
-                }
-              }
-            }
-          }
-        }
-      },
+    "/devices/{id}/name": {
       "put": {
-        "tags": ["Config"],
-        "summary": "Konfiguration aktualisieren",
-        "description": "Aktualisiert die Gateway-Konfiguration.",
+        "tags": ["Devices"],
+        "summary": "Lampe umbenennen",
+        "description": "Ändert den Namen einer Lampe (max. 32 Zeichen).",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID der Lampe"
+          }
+        ],
         "requestBody": {
           "required": true,
           "content": {
             "application/json": {
               "schema": {
-                "$ref": "#/components/schemas/ConfigUpdate"
+                "$ref": "#/components/schemas/RenameRequest"
               }
             }
           }
         },
         "responses": {
           "200": {
-            "description": "Konfiguration aktualisiert",
+            "description": "Umbenannt",
             "content": {
               "application/json": {
                 "schema": {
@@ -717,430 +891,3374 @@ const openAPISpec = `{
                 }
               }
             }
+          },
+          "400": {
+            "description": "Ungültiger Name",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
           }
         }
       }
-    }
-  },
-  "components": {
-    "schemas": {
-      "HealthResponse": {
+    },
+    "/groups/{id}/name": {
+      "put": {
+        "tags": ["Groups"],
+        "summary": "Raum/Zone umbenennen",
+        "description": "Ändert den Namen eines Raums oder einer Zone (max. 32 Zeichen).",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID des Raums oder der Zone"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/RenameRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Umbenannt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatusResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültiger Name",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/groups/{id}/lights/{lightId}": {
+      "put": {
+        "tags": ["Groups"],
+        "summary": "Lampe hinzufügen",
+        "description": "Fügt eine Lampe zu einem Raum oder einer Zone hinzu. Bei Räumen wird das zugehörige Gerät aus seinem bisherigen Raum entfernt.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID des Raums oder der Zone"
+          },
+          {
+            "name": "lightId",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID der Lampe"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Aktualisierte Gruppe",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Group"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Fehler",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "delete": {
+        "tags": ["Groups"],
+        "summary": "Lampe entfernen",
+        "description": "Entfernt eine Lampe aus einem Raum oder einer Zone.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID des Raums oder der Zone"
+          },
+          {
+            "name": "lightId",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID der Lampe"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Aktualisierte Gruppe",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Group"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Fehler",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/scenes": {
+      "get": {
+        "tags": ["Scenes"],
+        "summary": "Alle Szenen abrufen",
+        "description": "Gibt eine Liste aller HUE Szenen zurück. Jede Szene ist einem Raum oder einer Zone zugeordnet (group_id). Die Szenen werden im Frontend mit dem Format 'Raum - Szenenname' angezeigt.",
+        "responses": {
+          "200": {
+            "description": "Liste der Szenen",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ScenesResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/scenes/{id}/activate": {
+      "post": {
+        "tags": ["Scenes"],
+        "summary": "Szene aktivieren",
+        "description": "Aktiviert eine HUE Szene.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID der Szene"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Szene aktiviert",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatusResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/entertainment": {
+      "get": {
+        "tags": ["Entertainment"],
+        "summary": "Entertainment-Bereiche abrufen",
+        "description": "Gibt alle Entertainment-Konfigurationen der Bridge sowie den Status des laufenden Streams zurück.",
+        "responses": {
+          "200": {
+            "description": "Entertainment-Bereiche",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/EntertainmentResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "post": {
+        "tags": ["Entertainment"],
+        "summary": "Entertainment-Bereich erstellen",
+        "description": "Erstellt einen neuen Entertainment-Bereich mit den angegebenen Lampen. Die Lampen werden von links nach rechts angeordnet.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/EntertainmentCreate"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Bereich erstellt"
+          },
+          "400": {
+            "description": "Fehler",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/entertainment/stream": {
+      "get": {
+        "tags": ["Entertainment"],
+        "summary": "Stream-Status",
+        "description": "Gibt den Status des laufenden Entertainment-Streams zurück.",
+        "responses": {
+          "200": {
+            "description": "Stream-Status",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StreamStatus"
+                }
+              }
+            }
+          }
+        }
+      },
+      "delete": {
+        "tags": ["Entertainment"],
+        "summary": "Stream stoppen",
+        "description": "Stoppt den laufenden Entertainment-Stream.",
+        "responses": {
+          "200": {
+            "description": "Stream gestoppt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatusResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/entertainment/{id}/stream": {
+      "post": {
+        "tags": ["Entertainment"],
+        "summary": "Stream starten",
+        "description": "Startet das Streaming eines Musters auf einen Entertainment-Bereich. Läuft bereits ein Stream auf demselben Bereich, wird nur das Muster gewechselt.\n\nBenötigt den Client Key aus dem Pairing.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID des Entertainment-Bereichs"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/StreamOptions"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Stream gestartet",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StreamStatus"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Fehler",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/mappings": {
+      "get": {
+        "tags": ["Mappings"],
+        "summary": "Alle Mappings abrufen",
+        "description": "Gibt eine Liste aller Loxone zu HUE Mappings zurück.",
+        "responses": {
+          "200": {
+            "description": "Liste der Mappings",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/MappingsResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "post": {
+        "tags": ["Mappings"],
+        "summary": "Mapping erstellen",
+        "description": "Erstellt ein neues Loxone zu HUE Mapping.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/MappingCreate"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Mapping erstellt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Mapping"
+                }
+              }
+            }
+          },
+          "422": {
+            "description": "Validierung fehlgeschlagen",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ValidationError"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/mappings/{id}": {
+      "put": {
+        "tags": ["Mappings"],
+        "summary": "Mapping aktualisieren",
+        "description": "Aktualisiert ein bestehendes Mapping.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID des Mappings"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/Mapping"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Mapping aktualisiert",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Mapping"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Mapping nicht gefunden",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "422": {
+            "description": "Validierung fehlgeschlagen",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ValidationError"
+                }
+              }
+            }
+          }
+        }
+      },
+      "delete": {
+        "tags": ["Mappings"],
+        "summary": "Mapping löschen",
+        "description": "Löscht ein Mapping.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "ID des Mappings"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Mapping gelöscht",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatusResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Mapping nicht gefunden",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/mappings/validate": {
+      "get": {
+        "tags": ["Mappings"],
+        "summary": "Mappings validieren",
+        "description": "Prüft alle Mappings gegen die aktuellen Ressourcen der Bridges. Liefert verwaiste Mappings (HUE Ressource existiert nicht mehr), Duplikate aus dem Mapping-Index und ungültige Mappings. Bridges, die nicht erreichbar sind, werden unter unverified aufgeführt.",
+        "responses": {
+          "200": {
+            "description": "Validierungsbericht",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ValidationReport"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/mappings/generate": {
+      "post": {
+        "tags": ["Mappings"],
+        "summary": "Mappings generieren",
+        "description": "Schlägt Mappings aus den Räumen, Zonen, Lichtern und Szenen der Bridge vor: ein Mapping pro Raum/Zone und Licht mit Loxone IDs aus dem Namen (z.B. kueche_decke) sowie <raum>_mood_<n> Mappings für Szenen, pro Raum nach Namen nummeriert. Bereits gemappte HUE Ressourcen und belegte Loxone IDs werden nicht erneut verwendet. Mit dry_run wird nur die Vorschau geliefert, sonst werden die Vorschläge wie beim Import (merge) übernommen.",
+        "requestBody": {
+          "required": false,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GenerateRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Vorgeschlagene bzw. übernommene Mappings",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GenerateResult"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültige Anfrage oder unbekannte Bridge",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/mappings/export": {
+      "get": {
+        "tags": ["Mappings"],
+        "summary": "Mappings exportieren",
+        "description": "Exportiert alle Mappings als JSON-Backup. Die Datei enthält Versionsinformationen, einen Zeitstempel und alle Mappings.",
+        "responses": {
+          "200": {
+            "description": "Backup der Mappings",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/MappingsBackup"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/mappings/import": {
+      "post": {
+        "tags": ["Mappings"],
+        "summary": "Mappings importieren",
+        "description": "Importiert Mappings aus einem zuvor exportierten Backup. Unterstützt zwei Modi:\n\n- **replace**: Alle bestehenden Mappings werden gelöscht und durch die importierten ersetzt.\n- **merge**: Bestehende Mappings bleiben erhalten. Mappings mit gleicher ID oder gleicher Loxone ID und HUE Ressource werden aktualisiert, neue werden hinzugefügt.\n\nUngültige Mappings werden übersprungen und unter invalid aufgeführt.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ImportRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Import erfolgreich",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ImportResult"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültiges Backup-Format",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/moods": {
+      "get": {
+        "tags": ["Moods"],
+        "summary": "Alle Stimmungstabellen",
+        "description": "Stimmungstabellen ordnen den Stimmungsnummern eines MOOD-Ziels HUE Szenen zu. Sie haben Vorrang vor <ziel>_mood_<n> Mappings.",
+        "responses": {
+          "200": {
+            "description": "Stimmungstabellen",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/MoodTable"
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/moods/migrate": {
+      "post": {
+        "tags": ["Moods"],
+        "summary": "Mood-Mappings migrieren",
+        "description": "Wandelt aktive <ziel>_mood_<n> Szenen-Mappings in Stimmungstabellen um. Das Mapping des Ziels selbst wird zum Aus-Ziel der Tabelle. Ohne dry_run werden die migrierten Mappings entfernt.",
+        "requestBody": {
+          "required": false,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "dry_run": {
+                    "type": "boolean"
+                  }
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ergebnis der Migration",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "dry_run": {
+                      "type": "boolean"
+                    },
+                    "migrated": {
+                      "type": "integer"
+                    },
+                    "mood_tables": {
+                      "type": "array",
+                      "items": {
+                        "$ref": "#/components/schemas/MoodTable"
+                      }
+                    }
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/moods/{target}": {
+      "get": {
+        "tags": ["Moods"],
+        "summary": "Stimmungstabelle eines Ziels",
+        "description": "",
+        "parameters": [
+          {
+            "name": "target",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Loxone ID des MOOD-Ziels"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Stimmungstabelle",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/MoodTable"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Stimmungstabelle nicht gefunden",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "put": {
+        "tags": ["Moods"],
+        "summary": "Stimmungstabelle anlegen oder ersetzen",
+        "description": "Das Ziel kommt aus dem Pfad. Szenen und das Aus-Ziel werden gegen die Bridge geprüft.",
+        "parameters": [
+          {
+            "name": "target",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Loxone ID des MOOD-Ziels"
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/MoodTable"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Ersetzt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/MoodTable"
+                }
+              }
+            }
+          },
+          "201": {
+            "description": "Angelegt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/MoodTable"
+                }
+              }
+            }
+          },
+          "422": {
+            "description": "Validierungsfehler",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ValidationError"
+                }
+              }
+            }
+          }
+        }
+      },
+      "delete": {
+        "tags": ["Moods"],
+        "summary": "Stimmungstabelle löschen",
+        "description": "",
+        "parameters": [
+          {
+            "name": "target",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Loxone ID des MOOD-Ziels"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Gelöscht",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatusResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Stimmungstabelle nicht gefunden",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/loxone/templates/outputs.xml": {
+      "get": {
+        "tags": ["Loxone"],
+        "summary": "Virtual Output Vorlage",
+        "description": "Erzeugt eine in Loxone Config importierbare Virtual Output Vorlage mit Befehlen für alle Mappings: Ein/Aus, Helligkeit, Farbtemperatur und Farbe für Lichter und Gruppen, Stimmung (MOOD) pro Raum, Szenen und Entertainment-Streams. Die Befehle verwenden die /ws?cmd= URL.",
+        "parameters": [
+          {
+            "name": "address",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Basis-URL des Gateways aus Sicht des Miniservers, z.B. http://192.168.1.10:8080 (Standard: Host der Anfrage)"
+          },
+          {
+            "name": "loxone_token",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "API-Token, das als ?token= an alle URLs der Vorlage angehängt wird (bei aktiver Authentifizierung, Rolle control)"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Loxone Config Vorlage",
+            "content": {
+              "application/xml": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/loxone/templates/inputs.xml": {
+      "get": {
+        "tags": ["Loxone"],
+        "summary": "Virtual HTTP Input Vorlage",
+        "description": "Erzeugt eine in Loxone Config importierbare Virtual HTTP Input Vorlage, die Status und Helligkeit aller Licht- und Gruppen-Mappings von /api/loxone/status abfragt.",
+        "parameters": [
+          {
+            "name": "address",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Basis-URL des Gateways aus Sicht des Miniservers, z.B. http://192.168.1.10:8080 (Standard: Host der Anfrage)"
+          },
+          {
+            "name": "loxone_token",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "API-Token, das als ?token= an alle URLs der Vorlage angehängt wird (bei aktiver Authentifizierung, Rolle control)"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Loxone Config Vorlage",
+            "content": {
+              "application/xml": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/loxone/status": {
+      "get": {
+        "tags": ["Loxone"],
+        "summary": "Status für Virtual HTTP Input",
+        "description": "Liefert den Status aller Licht- und Gruppen-Mappings als Text, eine Zeile pro Wert: [loxone_id].on=0|1 und [loxone_id].brightness=n. Die Helligkeit wird mit der Wertumrechnung des Mappings zurückgerechnet.",
+        "responses": {
+          "200": {
+            "description": "Status als Text",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string",
+                  "example": "[kueche].on=1\n[kueche].brightness=80.0\n"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/loxone/structure": {
+      "get": {
+        "tags": ["Loxone"],
+        "summary": "Importierte Loxone-Struktur",
+        "description": "Liefert Räume, Kategorien und die Licht-Bausteine (LightControllerV2, Dimmer, ColorPickerV2) der zuletzt importierten LoxAPP3.json.",
+        "responses": {
+          "200": {
+            "description": "Loxone-Struktur",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LoxoneStructure"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Keine Strukturdatei importiert",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "post": {
+        "tags": ["Loxone"],
+        "summary": "Strukturdatei hochladen",
+        "description": "Importiert eine hochgeladene LoxAPP3.json des Miniservers. Die Struktur wird nur im Speicher gehalten.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "description": "Inhalt der LoxAPP3.json"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Importierte Struktur",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LoxoneStructure"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültige Strukturdatei",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/loxone/structure/fetch": {
+      "post": {
+        "tags": ["Loxone"],
+        "summary": "Strukturdatei vom Miniserver laden",
+        "description": "Lädt /data/LoxAPP3.json vom konfigurierten Miniserver (loxone.miniserver_ip, loxone.username, loxone.password).",
+        "responses": {
+          "200": {
+            "description": "Importierte Struktur",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LoxoneStructure"
+                }
+              }
+            }
+          },
+          "502": {
+            "description": "Miniserver nicht erreichbar",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/loxone/structure/match": {
+      "post": {
+        "tags": ["Loxone"],
+        "summary": "Mappings aus Struktur vorschlagen",
+        "description": "Vergleicht die Namen der Loxone-Bausteine unscharf mit den HUE Namen: LightControllerV2 mit Räumen/Zonen, Dimmer und ColorPickerV2 mit Lichtern. Die Loxone UUID wird als Loxone ID verwendet. Mit dry_run wird nur die Vorschau geliefert, sonst werden die Vorschläge wie beim Import (merge) übernommen.",
+        "requestBody": {
+          "required": false,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/StructureMatchRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Vorschläge bzw. übernommene Mappings",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StructureMatchResult"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Keine Strukturdatei importiert",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/loxone/miniserver": {
+      "get": {
+        "tags": ["Loxone"],
+        "summary": "Status der Miniserver-Verbindung",
+        "description": "Status der direkten WebSocket-Verbindung zum Miniserver (loxone.websocket). Der Gateway folgt den Zuständen gemappter Dimmer, ColorPickerV2 und LightControllerV2 und setzt Änderungen in HUE Befehle um.",
+        "responses": {
+          "200": {
+            "description": "Verbindungsstatus",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/MiniserverStatus"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/history": {
+      "get": {
+        "tags": ["History"],
+        "summary": "Audit-Log abfragen",
+        "description": "Liefert ausgeführte Befehle (Quelle, Client, Adresse, Rohtext, HUE Ziel, Ergebnis, Latenz) und Zustandsänderungen von Lichtern, Gruppen und Szenen, neueste zuerst.",
+        "parameters": [
+          {
+            "name": "target",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Loxone ID oder HUE ID"
+          },
+          {
+            "name": "source",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string",
+              "enum": ["ws", "http", "miniserver", "hue"]
+            },
+            "description": "Quelle"
+          },
+          {
+            "name": "kind",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string",
+              "enum": ["command", "state"]
+            },
+            "description": "Art des Eintrags"
+          },
+          {
+            "name": "since",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Ab Zeitpunkt (RFC 3339) oder Dauer zurück ab jetzt, z.B. 2h"
+          },
+          {
+            "name": "until",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Bis Zeitpunkt (RFC 3339) oder Dauer zurück ab jetzt"
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            },
+            "description": "Maximale Anzahl (Standard 100, höchstens 1000)"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Einträge",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/HistoryResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültiger Filter",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "Audit-Log nicht verfügbar",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/history/live": {
+      "get": {
+        "tags": ["History"],
+        "summary": "Audit-Log live verfolgen",
+        "description": "WebSocket, der jeden neuen Eintrag als JSON-Nachricht (HistoryEntry) sendet. Unterstützt dieselben Filter wie /history.",
+        "parameters": [
+          {
+            "name": "target",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Loxone ID oder HUE ID"
+          },
+          {
+            "name": "source",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string",
+              "enum": ["ws", "http", "miniserver", "hue"]
+            },
+            "description": "Quelle"
+          },
+          {
+            "name": "kind",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string",
+              "enum": ["command", "state"]
+            },
+            "description": "Art des Eintrags"
+          },
+          {
+            "name": "since",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Ab Zeitpunkt (RFC 3339) oder Dauer zurück ab jetzt, z.B. 2h"
+          },
+          {
+            "name": "until",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Bis Zeitpunkt (RFC 3339) oder Dauer zurück ab jetzt"
+          }
+        ],
+        "responses": {
+          "101": {
+            "description": "WebSocket-Verbindung aufgebaut"
+          },
+          "400": {
+            "description": "Ungültiger Filter",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/stats": {
+      "get": {
+        "tags": ["Stats"],
+        "summary": "Nutzungsstatistik",
+        "description": "Tägliche Einschaltdauer, durchschnittliche Helligkeit und geschätzter Energieverbrauch pro Licht und Raum. Grundlage sind die Zustandswechsel aus dem Event-Stream der Bridges. Ein Raum gilt als eingeschaltet, solange eines seiner Lichter brennt. Der Verbrauch wird aus stats.wattage (Watt bei voller Helligkeit pro Model-ID) proportional zur Helligkeit geschätzt.",
+        "parameters": [
+          {
+            "name": "days",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "integer"
+            },
+            "description": "Anzahl Tage bis und mit heute (Standard 7, höchstens 366)"
+          },
+          {
+            "name": "from",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string",
+              "format": "date"
+            },
+            "description": "Erster Tag (YYYY-MM-DD), statt days"
+          },
+          {
+            "name": "to",
+            "in": "query",
+            "required": false,
+            "schema": {
+              "type": "string",
+              "format": "date"
+            },
+            "description": "Letzter Tag (YYYY-MM-DD), Standard heute"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Statistik",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatsResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültiger Zeitraum",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "Statistik nicht verfügbar",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/auth": {
+      "get": {
+        "tags": ["Auth"],
+        "summary": "Authentifizierungsstatus",
+        "description": "Zeigt, ob die Authentifizierung aktiv ist und welche Rolle das mitgesendete Token hat. Ohne Token erreichbar.",
+        "responses": {
+          "200": {
+            "description": "Status",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AuthStatus"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/auth/tokens": {
+      "get": {
+        "tags": ["Auth"],
+        "summary": "API-Tokens auflisten",
+        "description": "Listet alle Tokens ohne Geheimnis. Erfordert die Rolle admin.",
+        "responses": {
+          "200": {
+            "description": "Tokens",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/APIToken"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Token fehlt oder ungültig",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "403": {
+            "description": "Rolle admin erforderlich",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "post": {
+        "tags": ["Auth"],
+        "summary": "API-Token erstellen",
+        "description": "Erstellt ein Token. Das Token wird nur in dieser Antwort angezeigt. Das erste Token muss die Rolle admin haben, danach ist die Authentifizierung aktiv (außer mit auth.enabled: false).",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateTokenRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Token erstellt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/CreatedToken"
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "Ungültige Anfrage",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "401": {
+            "description": "Token fehlt oder ungültig",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "403": {
+            "description": "Rolle admin erforderlich",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/auth/tokens/{id}": {
+      "delete": {
+        "tags": ["Auth"],
+        "summary": "API-Token widerrufen",
+        "description": "Widerruft ein Token. Das letzte admin-Token kann nicht widerrufen werden, solange andere Tokens existieren.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Token ID"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Token widerrufen",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatusResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Token nicht gefunden",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "409": {
+            "description": "Letztes admin-Token",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/config": {
+      "get": {
+        "tags": ["Config"],
+        "summary": "Konfiguration abrufen",
+        "description": "Gibt die aktuelle Gateway-Konfiguration zurück (ohne sensible Daten).",
+        "responses": {
+          "200": {
+            "description": "Konfiguration",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ConfigResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "put": {
+        "tags": ["Config"],
+        "summary": "Konfiguration aktualisieren",
+        "description": "Aktualisiert die Gateway-Konfiguration.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ConfigUpdate"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Konfiguration aktualisiert",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/StatusResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/config/versions": {
+      "get": {
+        "tags": ["Config"],
+        "summary": "Frühere Konfigurationen",
+        "description": "Listet die gespeicherten Vorgängerversionen der config.yaml, neueste zuerst. Vor jedem Speichern wird die bisherige Datei in config-versions/ abgelegt, die letzten 20 Versionen bleiben erhalten.",
+        "responses": {
+          "200": {
+            "description": "Versionen",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ConfigVersionsResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/config/versions/{id}/restore": {
+      "post": {
+        "tags": ["Config"],
+        "summary": "Konfiguration wiederherstellen",
+        "description": "Ersetzt die Konfiguration durch die gewählte Version und übernimmt sie wie eine von Hand geänderte Datei (Mappings, Stimmungstabellen, Logging, Miniserver). API-Tokens und Bridge-Schlüssel bleiben auf dem aktuellen Stand, die Optionen --ingress und --loxone-port gelten weiter. Die bisherige Konfiguration wird dabei selbst als Version gespeichert, die Wiederherstellung lässt sich also rückgängig machen. Server-Einstellungen greifen nach einem Neustart.",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            },
+            "description": "Versions-ID, z.B. config-20261018T163343.678Z"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Wiederhergestellt",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ConfigRestoreResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Version nicht gefunden",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "500": {
+            "description": "Version ungültig oder Speichern fehlgeschlagen",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  },
+  "security": [
+    {"bearerAuth": []}
+  ],
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "API-Token (l2h_...). Alternativ als Query-Parameter ?token= für Loxone und WebSockets."
+      }
+    },
+    "schemas": {
+      "HealthResponse": {
+        "type": "object",
+        "properties": {
+          "status": {
+            "type": "string",
+            "example": "healthy"
+          },
+          "timestamp": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "hue_configured": {
+            "type": "boolean"
+          }
+        }
+      },
+      "BridgeResponse": {
+        "type": "object",
+        "properties": {
+          "configured": {
+            "type": "boolean"
+          },
+          "info": {
+            "type": "object",
+            "properties": {
+              "id": {
+                "type": "string"
+              },
+              "name": {
+                "type": "string"
+              },
+              "model": {
+                "type": "string"
+              },
+              "software_version": {
+                "type": "string"
+              }
+            }
+          }
+        }
+      },
+      "DiscoverResponse": {
+        "type": "object",
+        "properties": {
+          "bridges": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "id": {
+                  "type": "string"
+                },
+                "ip": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      },
+      "PairRequest": {
+        "type": "object",
+        "required": ["bridge_ip"],
+        "properties": {
+          "bridge_ip": {
+            "type": "string",
+            "example": "192.168.1.100",
+            "description": "IP-Adresse der HUE Bridge"
+          }
+        }
+      },
+      "PairResponse": {
+        "type": "object",
+        "properties": {
+          "success": {
+            "type": "boolean"
+          },
+          "application_key": {
+            "type": "string",
+            "description": "Der generierte Application Key für die API"
+          },
+          "has_client_key": {
+            "type": "boolean",
+            "description": "Die Bridge hat einen Client Key für Entertainment Streaming geliefert"
+          }
+        }
+      },
+      "Light": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string"
+          },
+          "model": {
+            "type": "string"
+          },
+          "manufacturer": {
+            "type": "string"
+          },
+          "state": {
+            "$ref": "#/components/schemas/LightState"
+          }
+        }
+      },
+      "LightState": {
+        "type": "object",
+        "properties": {
+          "on": {
+            "type": "boolean"
+          },
+          "brightness": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 100
+          },
+          "color_temp": {
+            "type": "integer",
+            "description": "Farbtemperatur in Kelvin (2000-6500)"
+          },
+          "gradient": {
+            "$ref": "#/components/schemas/Gradient"
+          },
+          "reachable": {
+            "type": "boolean"
+          }
+        }
+      },
+      "DevicesResponse": {
+        "type": "object",
+        "properties": {
+          "devices": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Light"
+            }
+          }
+        }
+      },
+      "DeviceCommand": {
+        "type": "object",
+        "properties": {
+          "on": {
+            "type": "boolean",
+            "description": "Ein/Aus"
+          },
+          "brightness": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 100,
+            "description": "Helligkeit in Prozent"
+          },
+          "color_temp": {
+            "type": "integer",
+            "description": "Farbtemperatur in Kelvin (2000-6500)"
+          },
+          "color": {
+            "type": "string",
+            "description": "Farbe als Hex-Wert (z.B. #FF5500)"
+          },
+          "gradient": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Color"
+            },
+            "description": "Farbpunkte für Gradient-Lampen (mindestens 2, maximal gradient_points_capable)"
+          },
+          "gradient_mode": {
+            "type": "string",
+            "enum": ["interpolated_palette", "interpolated_palette_mirrored", "random_pixelated"],
+            "description": "Optionaler Gradient-Modus"
+          }
+        }
+      },
+      "Color": {
+        "type": "object",
+        "properties": {
+          "xy": {
+            "type": "array",
+            "items": {
+              "type": "number"
+            },
+            "minItems": 2,
+            "maxItems": 2,
+            "description": "Farbe im CIE XY Farbraum"
+          }
+        }
+      },
+      "Gradient": {
+        "type": "object",
+        "properties": {
+          "points": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Color"
+            }
+          },
+          "mode": {
+            "type": "string"
+          }
+        }
+      },
+      "Group": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Eindeutige ID der Gruppe (UUID)"
+          },
+          "name": {
+            "type": "string",
+            "description": "Name des Raums oder der Zone"
+          },
+          "type": {
+            "type": "string",
+            "enum": ["room", "zone"],
+            "description": "room = physischer Raum, zone = virtuelle Gruppe"
+          },
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "Liste der Light-IDs (nicht Device-IDs!) die zu dieser Gruppe gehören"
+          },
+          "state": {
+            "$ref": "#/components/schemas/GroupState"
+          }
+        }
+      },
+      "GroupState": {
+        "type": "object",
+        "properties": {
+          "any_on": {
+            "type": "boolean",
+            "description": "Mindestens eine Lampe ist an"
+          },
+          "all_on": {
+            "type": "boolean",
+            "description": "Alle Lampen sind an"
+          }
+        }
+      },
+      "GroupsResponse": {
+        "type": "object",
+        "properties": {
+          "groups": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Group"
+            }
+          }
+        }
+      },
+      "Scene": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Eindeutige ID der Szene (UUID)"
+          },
+          "name": {
+            "type": "string",
+            "description": "Name der Szene (z.B. 'Entspannen', 'Konzentrieren')"
+          },
+          "group_id": {
+            "type": "string",
+            "description": "ID des zugehörigen Raums oder der Zone. Im Frontend als 'Raum - Szene' dargestellt."
+          }
+        }
+      },
+      "ScenesResponse": {
+        "type": "object",
+        "properties": {
+          "scenes": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Scene"
+            }
+          }
+        }
+      },
+      "Mapping": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "loxone_id": {
+            "type": "string",
+            "description": "Loxone Identifikator für dieses Mapping"
+          },
+          "hue_id": {
+            "type": "string",
+            "description": "ID der HUE Ressource"
+          },
+          "hue_type": {
+            "type": "string",
+            "enum": ["light", "group", "scene", "entertainment", "virtual_group"],
+            "description": "Typ der HUE Ressource"
+          },
+          "bridge": {
+            "type": "string",
+            "description": "ID einer zusätzlichen Bridge aus hue.bridges (leer = primäre Bridge)"
+          },
+          "members": {
+            "type": "array",
+            "description": "Mitglieder einer virtuellen Gruppe (nur bei hue_type virtual_group)",
+            "items": {
+              "$ref": "#/components/schemas/MappingMember"
+            }
+          },
+          "overrides": {
+            "$ref": "#/components/schemas/MappingOverrides"
+          },
+          "transform": {
+            "$ref": "#/components/schemas/MappingTransform"
+          },
+          "direction": {
+            "type": "string",
+            "enum": ["to_hue", "to_loxone", "both"],
+            "default": "both",
+            "description": "to_hue: nur Befehle von Loxone, to_loxone: nur Status an Loxone, both: beide Richtungen"
+          },
+          "enabled": {
+            "type": "boolean"
+          },
+          "description": {
+            "type": "string"
+          }
+        }
+      },
+      "MappingCreate": {
+        "type": "object",
+        "required": ["name", "loxone_id", "hue_id", "hue_type"],
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "loxone_id": {
+            "type": "string"
+          },
+          "hue_id": {
+            "type": "string"
+          },
+          "hue_type": {
+            "type": "string",
+            "enum": ["light", "group", "scene", "entertainment", "virtual_group"]
+          },
+          "bridge": {
+            "type": "string"
+          },
+          "members": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MappingMember"
+            }
+          },
+          "overrides": {
+            "$ref": "#/components/schemas/MappingOverrides"
+          },
+          "transform": {
+            "$ref": "#/components/schemas/MappingTransform"
+          },
+          "description": {
+            "type": "string"
+          }
+        }
+      },
+      "MappingMember": {
+        "type": "object",
+        "description": "Licht oder Gruppe einer virtuellen Gruppe. Befehle werden parallel an alle Mitglieder gesendet, der Status wird zusammengefasst (any_on, all_on, mittlere Helligkeit).",
+        "required": ["hue_id", "hue_type"],
+        "properties": {
+          "hue_id": {
+            "type": "string"
+          },
+          "hue_type": {
+            "type": "string",
+            "enum": ["light", "group"]
+          },
+          "bridge": {
+            "type": "string",
+            "description": "ID der Bridge (leer = primäre Bridge)"
+          }
+        }
+      },
+      "MappingOverrides": {
+        "type": "object",
+        "description": "Parameter, die auf Befehle dieses Mappings angewendet werden. Reine Aus-Befehle bleiben unverändert.",
+        "properties": {
+          "brightness_scale": {
+            "type": "number",
+            "description": "Faktor für die angeforderte Helligkeit, z.B. 0.5",
+            "example": 0.5
+          },
+          "brightness": {
+            "type": "number",
+            "minimum": 0,
+            "maximum": 100,
+            "description": "Feste Helligkeit in Prozent, hat Vorrang vor brightness_scale"
+          },
+          "color_temp": {
+            "type": "integer",
+            "description": "Feste Farbtemperatur in Kelvin",
+            "example": 2700
+          }
+        }
+      },
+      "MappingTransform": {
+        "type": "object",
+        "description": "Wertumrechnung zwischen Loxone und HUE. Wird vor dem Senden an die Bridge angewendet und für Status-Updates an Loxone umgekehrt. Nicht gesetzte Werte verwenden die Standardwerte (Bereiche 0-100, lineare Kurve).",
+        "properties": {
+          "input_min": {
+            "type": "number",
+            "description": "Untere Grenze des Loxone-Wertebereichs",
+            "example": 0
+          },
+          "input_max": {
+            "type": "number",
+            "description": "Obere Grenze des Loxone-Wertebereichs, z.B. 10 oder 255",
+            "example": 10
+          },
+          "output_min": {
+            "type": "number",
+            "description": "Untere Grenze der HUE-Helligkeit in Prozent"
+          },
+          "output_max": {
+            "type": "number",
+            "description": "Obere Grenze der HUE-Helligkeit in Prozent"
+          },
+          "invert": {
+            "type": "boolean",
+            "description": "Wertebereich umkehren"
+          },
+          "gamma": {
+            "type": "number",
+            "description": "Exponent der Dimmkurve, 1 = linear, grösser 1 für wahrgenommene Helligkeit",
+            "example": 2.2
+          },
+          "min_on_brightness": {
+            "type": "number",
+            "description": "Minimale Helligkeit in Prozent im eingeschalteten Zustand"
+          },
+          "color_temp_min": {
+            "type": "integer",
+            "description": "Minimale Farbtemperatur in Kelvin"
+          },
+          "color_temp_max": {
+            "type": "integer",
+            "description": "Maximale Farbtemperatur in Kelvin"
+          }
+        }
+      },
+      "MappingConflict": {
+        "type": "object",
+        "description": "Mapping, das beim Laden übersprungen wurde. Konflikte werden in Listenreihenfolge aufgelöst, das erste Mapping gewinnt.",
+        "properties": {
+          "mapping_id": {
+            "type": "string"
+          },
+          "loxone_id": {
+            "type": "string"
+          },
+          "conflicts_with": {
+            "type": "string",
+            "description": "ID des Mappings, das behalten wurde"
+          },
+          "reason": {
+            "type": "string",
+            "example": "duplicate target"
+          }
+        }
+      },
+      "MappingsResponse": {
+        "type": "object",
+        "properties": {
+          "mappings": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Mapping"
+            }
+          },
+          "conflicts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MappingConflict"
+            }
+          }
+        }
+      },
+      "MappingsBackup": {
+        "type": "object",
+        "description": "Backup-Datei für Mappings mit Versionsinformationen",
+        "properties": {
+          "version": {
+            "type": "string",
+            "description": "Version des Backup-Formats",
+            "example": "1.0"
+          },
+          "created_at": {
+            "type": "string",
+            "format": "date-time",
+            "description": "Zeitpunkt der Backup-Erstellung"
+          },
+          "mappings": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Mapping"
+            },
+            "description": "Liste aller exportierten Mappings"
+          }
+        }
+      },
+      "ImportRequest": {
+        "type": "object",
+        "required": ["mode", "backup"],
+        "properties": {
+          "mode": {
+            "type": "string",
+            "enum": ["replace", "merge"],
+            "description": "Import-Modus: 'replace' = alle ersetzen, 'merge' = zusammenführen"
+          },
+          "backup": {
+            "$ref": "#/components/schemas/MappingsBackup",
+            "description": "Das zu importierende Backup"
+          }
+        }
+      },
+      "ImportResult": {
+        "type": "object",
+        "description": "Ergebnis des Import-Vorgangs",
+        "properties": {
+          "status": {
+            "type": "string",
+            "example": "ok"
+          },
+          "imported": {
+            "type": "integer",
+            "description": "Anzahl neu importierter Mappings"
+          },
+          "updated": {
+            "type": "integer",
+            "description": "Anzahl aktualisierter bestehender Mappings"
+          },
+          "skipped": {
+            "type": "integer",
+            "description": "Anzahl übersprungener Mappings"
+          },
+          "total": {
+            "type": "integer",
+            "description": "Gesamtanzahl verarbeiteter Mappings"
+          },
+          "conflicts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MappingConflict"
+            }
+          },
+          "invalid": {
+            "type": "array",
+            "description": "Übersprungene ungültige Mappings",
+            "items": {
+              "$ref": "#/components/schemas/MappingIssue"
+            }
+          }
+        }
+      },
+      "ConfigResponse": {
+        "type": "object",
+        "properties": {
+          "server": {
+            "type": "object",
+            "properties": {
+              "host": {
+                "type": "string"
+              },
+              "port": {
+                "type": "integer"
+              }
+            }
+          },
+          "hue": {
+            "type": "object",
+            "properties": {
+              "bridge_ip": {
+                "type": "string"
+              },
+              "configured": {
+                "type": "boolean"
+              },
+              "has_client_key": {
+                "type": "boolean",
+                "description": "Client Key für Entertainment Streaming gespeichert"
+              }
+            }
+          },
+          "loxone": {
+            "type": "object",
+            "properties": {
+              "enabled": {
+                "type": "boolean"
+              },
+              "miniserver_ip": {
+                "type": "string"
+              }
+            }
+          }
+        }
+      },
+      "ConfigUpdate": {
+        "type": "object",
+        "properties": {
+          "loxone": {
+            "type": "object",
+            "properties": {
+              "enabled": {
+                "type": "boolean"
+              },
+              "miniserver_ip": {
+                "type": "string"
+              }
+            }
+          }
+        }
+      },
+      "EntertainmentConfiguration": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string",
+            "enum": ["screen", "music", "3dspace", "other"]
+          },
+          "status": {
+            "type": "string",
+            "enum": ["active", "inactive"]
+          },
+          "channels": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "id": {
+                  "type": "integer"
+                },
+                "position": {
+                  "type": "array",
+                  "items": {
+                    "type": "number"
+                  }
+                }
+              }
+            }
+          },
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "EntertainmentResponse": {
+        "type": "object",
+        "properties": {
+          "configurations": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/EntertainmentConfiguration"
+            }
+          },
+          "stream": {
+            "$ref": "#/components/schemas/StreamStatus"
+          },
+          "client_key": {
+            "type": "boolean",
+            "description": "Client Key für das Streaming vorhanden"
+          }
+        }
+      },
+      "EntertainmentCreate": {
+        "type": "object",
+        "required": ["name", "lights"],
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string",
+            "enum": ["screen", "music", "3dspace", "other"],
+            "default": "music"
+          },
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "Light-IDs in der Reihenfolge von links nach rechts"
+          }
+        }
+      },
+      "StreamOptions": {
+        "type": "object",
+        "required": ["pattern"],
+        "properties": {
+          "pattern": {
+            "type": "string",
+            "enum": ["static", "rainbow", "pulse", "strobe", "party"]
+          },
+          "color": {
+            "type": "string",
+            "description": "Grundfarbe als Hex-Wert (z.B. #FF5500)"
+          },
+          "speed": {
+            "type": "number",
+            "description": "Zyklen pro Sekunde"
+          },
+          "brightness": {
+            "type": "number",
+            "minimum": 0,
+            "maximum": 100
+          }
+        }
+      },
+      "StreamStatus": {
+        "type": "object",
+        "properties": {
+          "active": {
+            "type": "boolean"
+          },
+          "config_id": {
+            "type": "string"
+          },
+          "options": {
+            "$ref": "#/components/schemas/StreamOptions"
+          },
+          "started_at": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "frames": {
+            "type": "integer",
+            "description": "Anzahl gesendeter Frames"
+          }
+        }
+      },
+      "PowerUp": {
+        "type": "object",
+        "required": ["preset"],
+        "properties": {
+          "preset": {
+            "type": "string",
+            "enum": ["safety", "powerfail", "last_on_state", "custom"],
+            "description": "safety = volles Weiss, powerfail = Zustand vor Stromausfall, last_on_state = letzter eingeschalteter Zustand, custom = eigene Werte"
+          },
+          "configured": {
+            "type": "boolean",
+            "readOnly": true
+          },
+          "on": {
+            "type": "boolean",
+            "description": "Nur bei custom: Zustand nach dem Einschalten (leer = vorheriger Zustand)"
+          },
+          "brightness": {
+            "type": "number",
+            "minimum": 0,
+            "maximum": 100,
+            "description": "Nur bei custom"
+          },
+          "color_temp": {
+            "type": "integer",
+            "minimum": 153,
+            "maximum": 500,
+            "description": "Nur bei custom: Farbtemperatur in Mirek"
+          }
+        }
+      },
+      "PowerUpListResponse": {
+        "type": "object",
+        "properties": {
+          "presets": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "id": {
+                  "type": "string"
+                },
+                "name": {
+                  "type": "string"
+                },
+                "powerup": {
+                  "$ref": "#/components/schemas/PowerUp"
+                }
+              }
+            }
+          }
+        }
+      },
+      "PowerUpBulkRequest": {
+        "type": "object",
+        "required": ["powerup"],
+        "properties": {
+          "all": {
+            "type": "boolean",
+            "description": "Alle Lampen konfigurieren"
+          },
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "groups": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "IDs von Räumen oder Zonen"
+          },
+          "powerup": {
+            "$ref": "#/components/schemas/PowerUp"
+          }
+        }
+      },
+      "PowerUpBulkResult": {
+        "type": "object",
+        "properties": {
+          "status": {
+            "type": "string",
+            "example": "ok"
+          },
+          "updated": {
+            "type": "integer"
+          },
+          "failed": {
+            "type": "integer"
+          },
+          "results": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "light_id": {
+                  "type": "string"
+                },
+                "success": {
+                  "type": "boolean"
+                },
+                "error": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      },
+      "RenameRequest": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {
+          "name": {
+            "type": "string",
+            "maxLength": 32
+          }
+        }
+      },
+      "InventoryDevice": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "archetype": {
+            "type": "string"
+          },
+          "model_id": {
+            "type": "string"
+          },
+          "manufacturer": {
+            "type": "string"
+          },
+          "product_name": {
+            "type": "string"
+          },
+          "certified": {
+            "type": "boolean"
+          },
+          "software_version": {
+            "type": "string"
+          },
+          "room": {
+            "type": "string",
+            "description": "Name des Raums, dem das Gerät zugeordnet ist"
+          },
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "description": "Light-IDs des Geräts"
+          },
+          "connectivity": {
+            "type": "string",
+            "enum": ["connected", "disconnected", "connectivity_issue", "unidirectional_incoming"]
+          },
+          "mac_address": {
+            "type": "string"
+          },
+          "update_state": {
+            "type": "string",
+            "enum": ["no_update", "update_pending", "ready_to_install", "installing"]
+          },
+          "update_problems": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "InventoryResponse": {
+        "type": "object",
+        "properties": {
+          "devices": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/InventoryDevice"
+            }
+          },
+          "total": {
+            "type": "integer"
+          },
+          "updates_available": {
+            "type": "integer",
+            "description": "Geräte mit ausstehendem Firmware-Update"
+          },
+          "unreachable": {
+            "type": "integer",
+            "description": "Geräte ohne Zigbee-Verbindung"
+          }
+        }
+      },
+      "ZoneCreate": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {
+          "name": {
+            "type": "string",
+            "maxLength": 32
+          },
+          "archetype": {
+            "type": "string",
+            "example": "living_room",
+            "description": "Symbol der Zone (Standard: other)"
+          },
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "GroupDeleteResult": {
+        "type": "object",
+        "properties": {
+          "status": {
+            "type": "string",
+            "example": "deleted"
+          },
+          "error": {
+            "type": "string"
+          },
+          "mappings": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Mapping"
+            },
+            "description": "Betroffene Mappings (bei 409)"
+          },
+          "mood_tables": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MoodTable"
+            },
+            "description": "Betroffene Stimmungstabellen (bei 409)"
+          },
+          "orphaned": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Mapping"
+            },
+            "description": "Mappings, die nach dem Löschen nicht mehr auflösbar sind"
+          },
+          "orphaned_mood_tables": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MoodTable"
+            },
+            "description": "Stimmungstabellen, die nach dem Löschen nicht mehr auflösbar sind"
+          }
+        }
+      },
+      "FieldError": {
         "type": "object",
         "properties": {
-          "status": {
+          "field": {
             "type": "string",
-            "example": "healthy"
+            "example": "hue_id"
           },
-          "timestamp": {
+          "code": {
             "type": "string",
-            "format": "date-time"
+            "enum": ["required", "invalid", "unknown_bridge", "not_found", "duplicate", "not_scene"]
           },
-          "hue_configured": {
-            "type": "boolean"
+          "message": {
+            "type": "string"
           }
         }
       },
-      "BridgeResponse": {
+      "ValidationError": {
         "type": "object",
         "properties": {
-          "configured": {
-            "type": "boolean"
+          "error": {
+            "type": "string",
+            "example": "mapping validation failed"
           },
-          "info": {
-            "type": "object",
-            "properties": {
-              "id": {
-                "type": "string"
-              },
-              "name": {
-                "type": "string"
-              },
-              "model": {
-                "type": "string"
-              },
-              "software_version": {
-                "type": "string"
-              }
+          "fields": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/FieldError"
             }
           }
         }
       },
-      "DiscoverResponse": {
+      "MappingIssue": {
         "type": "object",
         "properties": {
-          "bridges": {
+          "mapping_id": {
+            "type": "string"
+          },
+          "loxone_id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "errors": {
             "type": "array",
             "items": {
-              "type": "object",
-              "properties": {
-                "id": {
-                  "type": "string"
-                },
-                "ip": {
-                  "type": "string"
-                }
-              }
+              "$ref": "#/components/schemas/FieldError"
             }
           }
         }
       },
-      "PairRequest": {
+      "ValidationReport": {
         "type": "object",
-        "required": ["bridge_ip"],
         "properties": {
-          "bridge_ip": {
+          "total": {
+            "type": "integer"
+          },
+          "valid": {
+            "type": "integer"
+          },
+          "orphaned": {
+            "type": "array",
+            "description": "Mappings auf nicht mehr existierende HUE Ressourcen",
+            "items": {
+              "$ref": "#/components/schemas/MappingIssue"
+            }
+          },
+          "duplicates": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MappingConflict"
+            }
+          },
+          "invalid": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MappingIssue"
+            }
+          },
+          "unverified": {
+            "type": "array",
+            "description": "Bridges, die nicht abgefragt werden konnten",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "GenerateRequest": {
+        "type": "object",
+        "properties": {
+          "dry_run": {
+            "type": "boolean",
+            "description": "Nur Vorschau, nichts speichern"
+          },
+          "bridge": {
             "type": "string",
-            "example": "192.168.1.100",
-            "description": "IP-Adresse der HUE Bridge"
+            "description": "ID einer zusätzlichen Bridge (leer = primäre Bridge)"
+          },
+          "groups": {
+            "type": "boolean",
+            "default": true,
+            "description": "Mappings für Räume und Zonen"
+          },
+          "lights": {
+            "type": "boolean",
+            "default": true,
+            "description": "Mappings für Lichter"
+          },
+          "scenes": {
+            "type": "boolean",
+            "default": true,
+            "description": "Mood-Mappings für Szenen"
           }
         }
       },
-      "PairResponse": {
+      "GenerateResult": {
         "type": "object",
         "properties": {
-          "success": {
+          "dry_run": {
             "type": "boolean"
           },
-          "application_key": {
-            "type": "string",
-            "description": "Der generierte Application Key für die API"
+          "mappings": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Mapping"
+            }
+          },
+          "imported": {
+            "type": "integer"
+          },
+          "updated": {
+            "type": "integer"
+          },
+          "total": {
+            "type": "integer",
+            "description": "Anzahl Vorschläge (dry_run) bzw. aller Mappings"
+          },
+          "conflicts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MappingConflict"
+            }
           }
         }
       },
-      "Light": {
+      "LoxoneStructure": {
         "type": "object",
         "properties": {
-          "id": {
+          "miniserver_name": {
             "type": "string"
           },
+          "project_name": {
+            "type": "string"
+          },
+          "last_modified": {
+            "type": "string"
+          },
+          "rooms": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "categories": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "controls": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/LoxoneControl"
+            }
+          }
+        }
+      },
+      "LoxoneControl": {
+        "type": "object",
+        "properties": {
+          "uuid": {
+            "type": "string",
+            "description": "UUID des Bausteins (uuidAction)"
+          },
           "name": {
             "type": "string"
           },
           "type": {
-            "type": "string"
+            "type": "string",
+            "enum": ["LightControllerV2", "Dimmer", "ColorPickerV2"]
           },
-          "model": {
+          "room": {
             "type": "string"
           },
-          "manufacturer": {
+          "category": {
             "type": "string"
           },
-          "state": {
-            "$ref": "#/components/schemas/LightState"
+          "parent": {
+            "type": "string",
+            "description": "UUID der Lichtsteuerung bei Unterbausteinen"
+          },
+          "states": {
+            "type": "object",
+            "additionalProperties": {"type": "string"},
+            "description": "Zustandsname -> Zustands-UUID, z.B. position"
           }
         }
       },
-      "LightState": {
+      "StructureMatchRequest": {
         "type": "object",
         "properties": {
-          "on": {
-            "type": "boolean"
+          "dry_run": {
+            "type": "boolean",
+            "description": "Nur Vorschau, nichts speichern"
           },
-          "brightness": {
-            "type": "integer",
-            "minimum": 0,
-            "maximum": 100
+          "min_score": {
+            "type": "number",
+            "default": 0.6,
+            "description": "Minimale Namensähnlichkeit (0-1)"
           },
-          "color_temp": {
-            "type": "integer",
-            "description": "Farbtemperatur in Kelvin (2000-6500)"
+          "bridge": {
+            "type": "string",
+            "description": "ID einer zusätzlichen Bridge (leer = primäre Bridge)"
+          }
+        }
+      },
+      "StructureMatch": {
+        "type": "object",
+        "properties": {
+          "control": {
+            "$ref": "#/components/schemas/LoxoneControl"
           },
-          "reachable": {
-            "type": "boolean"
+          "mapping": {
+            "$ref": "#/components/schemas/Mapping"
+          },
+          "score": {
+            "type": "number",
+            "description": "Namensähnlichkeit 0-1"
           }
         }
       },
-      "DevicesResponse": {
+      "StructureMatchResult": {
         "type": "object",
         "properties": {
-          "devices": {
+          "dry_run": {
+            "type": "boolean"
+          },
+          "matches": {
             "type": "array",
             "items": {
-              "$ref": "#/components/schemas/Light"
+              "$ref": "#/components/schemas/StructureMatch"
+            }
+          },
+          "imported": {
+            "type": "integer"
+          },
+          "updated": {
+            "type": "integer"
+          },
+          "total": {
+            "type": "integer"
+          },
+          "conflicts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MappingConflict"
             }
           }
         }
       },
-      "DeviceCommand": {
+      "MiniserverStatus": {
         "type": "object",
         "properties": {
-          "on": {
+          "enabled": {
             "type": "boolean",
-            "description": "Ein/Aus"
+            "description": "loxone.websocket aktiv"
           },
-          "brightness": {
+          "host": {
+            "type": "string"
+          },
+          "connected": {
+            "type": "boolean"
+          },
+          "connected_at": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "miniserver": {
+            "type": "string",
+            "description": "Name des Miniservers"
+          },
+          "controls": {
             "type": "integer",
-            "minimum": 0,
-            "maximum": 100,
-            "description": "Helligkeit in Prozent"
+            "description": "Licht-Bausteine der Strukturdatei"
           },
-          "color_temp": {
+          "last_error": {
+            "type": "string"
+          }
+        }
+      },
+      "MoodTable": {
+        "type": "object",
+        "properties": {
+          "target": {
+            "type": "string",
+            "description": "Loxone ID der MOOD-Befehle"
+          },
+          "name": {
+            "type": "string"
+          },
+          "hue_id": {
+            "type": "string",
+            "description": "Raum, Zone oder Licht für Stimmung 0, 777 und 778"
+          },
+          "hue_type": {
+            "type": "string",
+            "enum": ["group", "light"]
+          },
+          "bridge": {
+            "type": "string"
+          },
+          "off_transition": {
             "type": "integer",
-            "description": "Farbtemperatur in Kelvin (2000-6500)"
+            "description": "Ausblendzeit von Stimmung 0 in Millisekunden"
           },
-          "color": {
+          "moods": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/MoodEntry"
+            }
+          }
+        }
+      },
+      "MoodEntry": {
+        "type": "object",
+        "required": ["number"],
+        "properties": {
+          "number": {
+            "type": "integer",
+            "description": "Stimmungsnummer (nicht 0, 777, 778)"
+          },
+          "name": {
+            "type": "string"
+          },
+          "scene_id": {
             "type": "string",
-            "description": "Farbe als Hex-Wert (z.B. #FF5500)"
+            "description": "HUE Szene, ohne Szene wird nur die Helligkeit gesetzt"
+          },
+          "brightness": {
+            "type": "number",
+            "minimum": 0,
+            "maximum": 100
+          },
+          "transition": {
+            "type": "integer",
+            "description": "Übergangszeit in Millisekunden"
           }
         }
       },
-      "Group": {
+      "APIToken": {
         "type": "object",
         "properties": {
           "id": {
+            "type": "string"
+          },
+          "name": {
             "type": "string",
-            "description": "Eindeutige ID der Gruppe (UUID)"
+            "example": "Loxone Miniserver"
+          },
+          "role": {
+            "type": "string",
+            "enum": ["read", "control", "admin"]
+          },
+          "prefix": {
+            "type": "string",
+            "description": "Anfang des Tokens zur Wiedererkennung",
+            "example": "l2h_3fa9c1"
           },
+          "created_at": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "CreateTokenRequest": {
+        "type": "object",
+        "required": ["name", "role"],
+        "properties": {
           "name": {
             "type": "string",
-            "description": "Name des Raums oder der Zone"
+            "example": "Loxone Miniserver"
           },
-          "type": {
+          "role": {
             "type": "string",
-            "enum": ["room", "zone"],
-            "description": "room = physischer Raum, zone = virtuelle Gruppe"
-          },
-          "lights": {
-            "type": "array",
-            "items": {
-              "type": "string"
-            },
-            "description": "Liste der Light-IDs (nicht Device-IDs!) die zu dieser Gruppe gehören"
-          },
-          "state": {
-            "$ref": "#/components/schemas/GroupState"
+            "enum": ["read", "control", "admin"],
+            "description": "read: nur lesen, control: Lampen schalten und Loxone-Befehle, admin: alles"
           }
         }
       },
-      "GroupState": {
+      "CreatedToken": {
         "type": "object",
         "properties": {
-          "any_on": {
-            "type": "boolean",
-            "description": "Mindestens eine Lampe ist an"
+          "token": {
+            "type": "string",
+            "description": "Geheimes Token, wird nur einmal angezeigt"
           },
-          "all_on": {
-            "type": "boolean",
-            "description": "Alle Lampen sind an"
+          "details": {
+            "$ref": "#/components/schemas/APIToken"
           }
         }
       },
-      "GroupsResponse": {
+      "AuthStatus": {
         "type": "object",
         "properties": {
-          "groups": {
-            "type": "array",
-            "items": {
-              "$ref": "#/components/schemas/Group"
-            }
+          "enabled": {
+            "type": "boolean"
+          },
+          "active": {
+            "type": "boolean",
+            "description": "enabled und mindestens ein Token vorhanden"
+          },
+          "token": {
+            "$ref": "#/components/schemas/APIToken"
           }
         }
       },
-      "Scene": {
+      "HistoryEntry": {
         "type": "object",
         "properties": {
           "id": {
+            "type": "integer"
+          },
+          "time": {
             "type": "string",
-            "description": "Eindeutige ID der Szene (UUID)"
+            "format": "date-time"
           },
-          "name": {
+          "kind": {
             "type": "string",
-            "description": "Name der Szene (z.B. 'Entspannen', 'Konzentrieren')"
+            "enum": ["command", "state"]
           },
-          "group_id": {
+          "source": {
             "type": "string",
-            "description": "ID des zugehörigen Raums oder der Zone. Im Frontend als 'Raum - Szene' dargestellt."
+            "enum": ["ws", "http", "miniserver", "hue"]
+          },
+          "client": {
+            "type": "string",
+            "description": "WebSocket Client-ID bzw. Miniserver"
+          },
+          "remote_addr": {
+            "type": "string",
+            "example": "192.168.1.77"
+          },
+          "raw": {
+            "type": "string",
+            "description": "Empfangener Befehl",
+            "example": "SET wz_decke ON"
+          },
+          "target": {
+            "type": "string",
+            "description": "Loxone ID"
+          },
+          "action": {
+            "type": "string"
+          },
+          "params": {
+            "type": "object",
+            "additionalProperties": true
+          },
+          "bridge": {
+            "type": "string"
+          },
+          "hue_id": {
+            "type": "string"
+          },
+          "hue_type": {
+            "type": "string"
+          },
+          "result": {
+            "type": "string",
+            "enum": ["ok", "error"]
+          },
+          "error": {
+            "type": "string"
+          },
+          "latency_ms": {
+            "type": "number"
+          },
+          "state": {
+            "type": "object",
+            "description": "Event-Daten der Bridge bei Zustandsänderungen"
           }
         }
       },
-      "ScenesResponse": {
+      "HistoryResponse": {
         "type": "object",
         "properties": {
-          "scenes": {
+          "entries": {
             "type": "array",
             "items": {
-              "$ref": "#/components/schemas/Scene"
+              "$ref": "#/components/schemas/HistoryEntry"
             }
+          },
+          "count": {
+            "type": "integer"
           }
         }
       },
-      "Mapping": {
+      "StatsDay": {
+        "type": "object",
+        "properties": {
+          "date": {
+            "type": "string",
+            "format": "date",
+            "example": "2026-10-17",
+            "description": "Tag, fehlt beim Total"
+          },
+          "on_hours": {
+            "type": "number",
+            "example": 5.25,
+            "description": "Eingeschaltete Stunden"
+          },
+          "avg_brightness": {
+            "type": "number",
+            "example": 62.5,
+            "description": "Durchschnittliche Helligkeit in % während der Einschaltzeit"
+          },
+          "energy_wh": {
+            "type": "number",
+            "example": 41.3,
+            "description": "Geschätzter Verbrauch in Wh, nur mit konfigurierter Leistung"
+          }
+        }
+      },
+      "LightStats": {
         "type": "object",
         "properties": {
           "id": {
             "type": "string"
           },
+          "bridge": {
+            "type": "string"
+          },
           "name": {
             "type": "string"
           },
-          "loxone_id": {
+          "model_id": {
             "type": "string",
-            "description": "Loxone Identifikator für dieses Mapping"
+            "example": "LCA001"
           },
-          "hue_id": {
-            "type": "string",
-            "description": "ID der HUE Ressource"
+          "room": {
+            "type": "string"
           },
-          "hue_type": {
-            "type": "string",
-            "enum": ["light", "group", "scene"],
-            "description": "Typ der HUE Ressource"
+          "watts": {
+            "type": "number",
+            "description": "Konfigurierte Leistung bei voller Helligkeit"
           },
-          "enabled": {
-            "type": "boolean"
+          "total": {
+            "$ref": "#/components/schemas/StatsDay"
           },
-          "description": {
-            "type": "string"
+          "days": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/StatsDay"
+            }
           }
         }
       },
-      "MappingCreate": {
+      "RoomStats": {
         "type": "object",
-        "required": ["name", "loxone_id", "hue_id", "hue_type"],
         "properties": {
-          "name": {
+          "id": {
             "type": "string"
           },
-          "loxone_id": {
+          "bridge": {
             "type": "string"
           },
-          "hue_id": {
+          "name": {
             "type": "string"
           },
-          "hue_type": {
-            "type": "string",
-            "enum": ["light", "group", "scene"]
+          "lights": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
           },
-          "description": {
-            "type": "string"
+          "total": {
+            "$ref": "#/components/schemas/StatsDay"
+          },
+          "days": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/StatsDay"
+            }
           }
         }
       },
-      "MappingsResponse": {
+      "StatsResponse": {
         "type": "object",
         "properties": {
-          "mappings": {
+          "from": {
+            "type": "string",
+            "format": "date"
+          },
+          "to": {
+            "type": "string",
+            "format": "date"
+          },
+          "lights": {
             "type": "array",
             "items": {
-              "$ref": "#/components/schemas/Mapping"
+              "$ref": "#/components/schemas/LightStats"
+            }
+          },
+          "rooms": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/RoomStats"
             }
           }
         }
       },
-      "MappingsBackup": {
+      "LivenessResponse": {
         "type": "object",
-        "description": "Backup-Datei für Mappings mit Versionsinformationen",
         "properties": {
-          "version": {
+          "status": {
             "type": "string",
-            "description": "Version des Backup-Formats",
-            "example": "1.0"
+            "enum": ["ok", "unavailable"]
           },
-          "created_at": {
+          "timestamp": {
             "type": "string",
-            "format": "date-time",
-            "description": "Zeitpunkt der Backup-Erstellung"
+            "format": "date-time"
           },
-          "mappings": {
-            "type": "array",
-            "items": {
-              "$ref": "#/components/schemas/Mapping"
-            },
-            "description": "Liste aller exportierten Mappings"
+          "uptime_seconds": {
+            "type": "integer"
           }
         }
       },
-      "ImportRequest": {
+      "ComponentHealth": {
         "type": "object",
-        "required": ["mode", "backup"],
         "properties": {
-          "mode": {
+          "status": {
             "type": "string",
-            "enum": ["replace", "merge"],
-            "description": "Import-Modus: 'replace' = alle ersetzen, 'merge' = zusammenführen"
+            "enum": ["ok", "degraded", "failing", "disabled"]
           },
-          "backup": {
-            "$ref": "#/components/schemas/MappingsBackup",
-            "description": "Das zu importierende Backup"
+          "critical": {
+            "type": "boolean",
+            "description": "Ein Ausfall macht den Gateway nicht bereit"
+          },
+          "message": {
+            "type": "string"
+          },
+          "details": {
+            "type": "object",
+            "additionalProperties": true,
+            "description": "Z.B. latency_ms, last_event_age_seconds, checked_at"
           }
         }
       },
-      "ImportResult": {
+      "ReadinessResponse": {
         "type": "object",
-        "description": "Ergebnis des Import-Vorgangs",
         "properties": {
           "status": {
             "type": "string",
-            "example": "ok"
+            "enum": ["ok", "degraded", "unavailable"]
           },
-          "imported": {
-            "type": "integer",
-            "description": "Anzahl neu importierter Mappings"
+          "ready": {
+            "type": "boolean"
           },
-          "updated": {
-            "type": "integer",
-            "description": "Anzahl aktualisierter bestehender Mappings"
+          "timestamp": {
+            "type": "string",
+            "format": "date-time"
           },
-          "skipped": {
+          "components": {
+            "type": "object",
+            "description": "websocket_hub, config, mappings, miniserver sowie bridge und event_stream (zusätzliche Bridges als bridge:<id>)",
+            "additionalProperties": {
+              "$ref": "#/components/schemas/ComponentHealth"
+            }
+          }
+        }
+      },
+      "ConfigVersion": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string",
+            "example": "config-20261018T163343.678Z"
+          },
+          "created_at": {
+            "type": "string",
+            "format": "date-time",
+            "description": "Zeitpunkt, zu dem diese Version gespeichert wurde"
+          },
+          "size": {
             "type": "integer",
-            "description": "Anzahl übersprungener Mappings"
+            "description": "Grösse in Bytes"
           },
-          "total": {
+          "mappings": {
             "type": "integer",
-            "description": "Gesamtanzahl verarbeiteter Mappings"
+            "description": "Anzahl Mappings"
           }
         }
       },
-      "ConfigResponse": {
+      "ConfigVersionsResponse": {
         "type": "object",
         "properties": {
-          "server": {
-            "type": "object",
-            "properties": {
-              "host": {
-                "type": "string"
-              },
-              "port": {
-                "type": "integer"
-              }
-            }
-          },
-          "hue": {
-            "type": "object",
-            "properties": {
-              "bridge_ip": {
-                "type": "string"
-              },
-              "configured": {
-                "type": "boolean"
-              }
+          "versions": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ConfigVersion"
             }
           },
-          "loxone": {
-            "type": "object",
-            "properties": {
-              "enabled": {
-                "type": "boolean"
-              },
-              "miniserver_ip": {
-                "type": "string"
-              }
-            }
+          "count": {
+            "type": "integer"
           }
         }
       },
-      "ConfigUpdate": {
+      "ConfigRestoreResponse": {
         "type": "object",
         "properties": {
-          "loxone": {
-            "type": "object",
-            "properties": {
-              "enabled": {
-                "type": "boolean"
-              },
-              "miniserver_ip": {
-                "type": "string"
-              }
+          "status": {
+            "type": "string",
+            "example": "restored"
+          },
+          "version": {
+            "type": "string"
+          },
+          "mappings": {
+            "type": "integer"
+          },
+          "conflicts": {
+            "type": "array",
+            "items": {
+              "type": "object"
             }
           }
         }
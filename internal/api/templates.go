@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// loxoneStatusPath is polled by the generated Virtual HTTP Input
+const loxoneStatusPath = "/api/loxone/status"
+
+// gatewayAddress returns the base URL Loxone uses to reach the gateway.
+// It can be overridden with ?address= when the gateway is behind a proxy.
+// Templates requested over HTTPS point to the plain HTTP listener if one is
+// configured, since virtual outputs cannot verify self-signed certificates.
+func gatewayAddress(r *http.Request) string {
+	if address := r.URL.Query().Get("address"); address != "" {
+		return strings.TrimSuffix(address, "/")
+	}
+
+	// Behind ingress Loxone has to use the separate Loxone listener
+	if loxonePort := config.Get().Server.Ingress.LoxonePort; loxonePort > 0 && isIngress(r) {
+		return "http://" + net.JoinHostPort(requestHostname(r), strconv.Itoa(loxonePort))
+	}
+
+	if r.TLS == nil {
+		return "http://" + r.Host
+	}
+	if httpPort := config.Get().Server.TLS.HTTPPort; httpPort > 0 {
+		return "http://" + net.JoinHostPort(requestHostname(r), strconv.Itoa(httpPort))
+	}
+	return "https://" + r.Host
+}
+
+// templateToken returns the API token Loxone should send, passed as
+// ?loxone_token= since the browser's own token must not end up in Loxone
+func templateToken(r *http.Request) string {
+	return r.URL.Query().Get("loxone_token")
+}
+
+// writeTemplate sends a Loxone Config template as XML download
+func writeTemplate(w http.ResponseWriter, filename string, data []byte, err error) {
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// LoxoneOutputTemplate exports a Virtual Output template for all mappings
+func (s *Server) LoxoneOutputTemplate(w http.ResponseWriter, r *http.Request) {
+	data, err := loxone.OutputTemplate(config.GetMappings(), s.mappingManager.MoodTables(), gatewayAddress(r), templateToken(r))
+	writeTemplate(w, "loxone2hue-outputs.xml", data, err)
+}
+
+// LoxoneInputTemplate exports a Virtual HTTP Input template for all mappings
+func (s *Server) LoxoneInputTemplate(w http.ResponseWriter, r *http.Request) {
+	data, err := loxone.InputTemplate(config.GetMappings(), gatewayAddress(r), loxoneStatusPath, templateToken(r))
+	writeTemplate(w, "loxone2hue-inputs.xml", data, err)
+}
+
+// LoxoneStatus returns the state of all light and group mappings as plain
+// text lines "[<loxone_id>].on=<0|1>" and "[<loxone_id>].brightness=<value>",
+// the format parsed by the generated Virtual HTTP Input
+func (s *Server) LoxoneStatus(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	seen := make(map[string]bool)
+
+	for _, m := range s.mappingManager.GetAll() {
+		if seen[m.LoxoneID] || !loxone.IsStatusTarget(m.HueType) || !loxone.SendsToLoxone(m.Direction) {
+			continue
+		}
+		seen[m.LoxoneID] = true
+
+		result, err := s.wsHub.executeCommand(&models.LoxoneCommand{Type: "query", Target: m.LoxoneID, Action: "STATUS"}, nil)
+		if err != nil {
+			log.Debug().Err(err).Str("target", m.LoxoneID).Msg("Failed to get status for Loxone input")
+			continue
+		}
+
+		on, brightness := loxone.StateOnBrightness(result.State)
+		onValue := 0
+		if on {
+			onValue = 1
+		}
+		fmt.Fprintf(&b, "[%s].on=%d\n[%s].brightness=%.1f\n", m.LoxoneID, onValue, m.LoxoneID, brightness)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+)
+
+// Self-signed certificate files, stored next to the config file
+const (
+	selfSignedCertFile = "tls.crt"
+	selfSignedKeyFile  = "tls.key"
+)
+
+// selfSignedValidity is the lifetime of a generated certificate
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+// loadCertificate loads the configured certificate or a self-signed one,
+// which is generated on first start
+func loadCertificate(cfg config.TLSConfig) (tls.Certificate, error) {
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return tls.Certificate{}, fmt.Errorf("tls requires both cert_file and key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		return cert, nil
+	}
+
+	certPath := filepath.Join(config.Dir(), selfSignedCertFile)
+	keyPath := filepath.Join(config.Dir(), selfSignedKeyFile)
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		return cert, nil
+	} else if !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("path", certPath).Msg("Self-signed certificate unreadable, generating a new one")
+	}
+
+	if err := generateSelfSigned(certPath, keyPath); err != nil {
+		return tls.Certificate{}, err
+	}
+	log.Info().Str("path", certPath).Msg("Generated self-signed certificate")
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// generateSelfSigned writes a self-signed certificate for the host name,
+// localhost and all local IP addresses
+func generateSelfSigned(certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Loxone2HUE Gateway", Organization: []string{"Loxone2HUE"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname, hostname+".local")
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	return nil
+}
+
+// requestHostname returns the host of a request without port and brackets
+func requestHostname(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return strings.Trim(r.Host, "[]")
+}
+
+// isLoxoneEndpoint reports whether a path is used by Loxone virtual outputs
+// and inputs, which cannot follow a redirect to HTTPS
+func isLoxoneEndpoint(path string) bool {
+	return path == "/ws" || path == loxoneStatusPath
+}
+
+// httpsRedirect sends browsers to the HTTPS listener and serves Loxone
+// endpoints over plain HTTP
+func httpsRedirect(next http.Handler, httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isLoxoneEndpoint(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host := requestHostname(r)
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+	"github.com/sbeyeler/loxone2hue/internal/hue"
+	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// FieldError describes a single invalid mapping field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // required, invalid, unknown_bridge, not_found, duplicate, not_scene
+	Message string `json:"message"`
+}
+
+// MappingIssue lists the problems of one mapping
+type MappingIssue struct {
+	MappingID string       `json:"mapping_id"`
+	LoxoneID  string       `json:"loxone_id"`
+	Name      string       `json:"name,omitempty"`
+	Errors    []FieldError `json:"errors"`
+}
+
+// ValidationReport is the result of validating all mappings
+type ValidationReport struct {
+	Total      int                      `json:"total"`
+	Valid      int                      `json:"valid"`
+	Orphaned   []MappingIssue           `json:"orphaned"`
+	Duplicates []loxone.MappingConflict `json:"duplicates"`
+	Invalid    []MappingIssue           `json:"invalid"`
+	Unverified []string                 `json:"unverified,omitempty"` // Bridges that could not be queried
+}
+
+// mappingValidator checks mappings against the bridge resource caches
+type mappingValidator struct {
+	bridges    *hue.Registry
+	refresh    bool
+	resources  map[string]*hue.Resources
+	unverified map[string]bool
+}
+
+func newMappingValidator(bridges *hue.Registry, refresh bool) *mappingValidator {
+	return &mappingValidator{
+		bridges:    bridges,
+		refresh:    refresh,
+		resources:  make(map[string]*hue.Resources),
+		unverified: make(map[string]bool),
+	}
+}
+
+// lookup returns the resources of a bridge, nil if they can't be verified
+func (v *mappingValidator) lookup(bridge string) *hue.Resources {
+	if res, ok := v.resources[bridge]; ok || v.unverified[bridge] {
+		return res
+	}
+
+	client, err := v.bridges.Get(bridge)
+	if err != nil || !client.IsConfigured() {
+		v.unverified[bridge] = true
+		return nil
+	}
+
+	res, err := client.Resources(v.refresh)
+	if err != nil {
+		log.Warn().Err(err).Str("bridge", bridge).Msg("Failed to load bridge resources for validation")
+		v.unverified[bridge] = true
+		return nil
+	}
+	v.resources[bridge] = res
+	return res
+}
+
+// validate checks a single mapping. others are the remaining mappings,
+// used to detect duplicates.
+func (v *mappingValidator) validate(m models.Mapping, others []models.Mapping) []FieldError {
+	errs := make([]FieldError, 0)
+	add := func(field, code, message string) {
+		errs = append(errs, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	switch {
+	case m.LoxoneID == "":
+		add("loxone_id", "required", "loxone_id is required")
+	case strings.ContainsAny(m.LoxoneID, " \t\r\n"):
+		add("loxone_id", "invalid", "loxone_id must not contain whitespace")
+	}
+
+	if !models.ValidHueType(m.HueType) {
+		add("hue_type", "invalid", "hue_type must be one of light, group, scene, entertainment, virtual_group")
+		return errs
+	}
+
+	if !loxone.ValidDirection(m.Direction) {
+		add("direction", "invalid", "direction must be one of to_hue, to_loxone, both")
+	}
+
+	if m.HueType == "virtual_group" {
+		if len(m.Members) == 0 {
+			add("members", "required", "virtual group requires at least one member")
+		}
+		for i, member := range m.Members {
+			field := "members[" + strconv.Itoa(i) + "]"
+			if member.HueType != "light" && member.HueType != "group" {
+				add(field+".hue_type", "invalid", "member type must be light or group")
+				continue
+			}
+			v.checkResource(add, field, member.Bridge, member.HueType, member.HueID)
+		}
+	} else {
+		v.checkResource(add, "", m.Bridge, m.HueType, m.HueID)
+	}
+
+	// <target>_mood_<n> with n > 0 is activated as a scene
+	if match := loxone.MoodPattern.FindStringSubmatch(m.LoxoneID); match != nil && match[2] != "0" && m.HueType != "scene" {
+		add("hue_type", "not_scene", "mood mapping "+m.LoxoneID+" must point to a scene")
+	}
+
+	for _, other := range others {
+		if other.ID == m.ID || other.LoxoneID != m.LoxoneID || !other.Enabled {
+			continue
+		}
+		if other.HueType == m.HueType && other.HueID == m.HueID && other.Bridge == m.Bridge {
+			add("loxone_id", "duplicate", "mapping "+other.ID+" already maps "+m.LoxoneID+" to the same resource")
+		} else if loxone.TargetKind(other.HueType) != loxone.TargetKind(m.HueType) {
+			add("loxone_id", "duplicate", "mapping "+other.ID+" already maps "+m.LoxoneID+" to a "+other.HueType)
+		}
+	}
+
+	if t := m.Transform; t != nil {
+		if t.Gamma < 0 {
+			add("transform.gamma", "invalid", "gamma must be positive")
+		}
+		if t.ColorTempMin > 0 && t.ColorTempMax > 0 && t.ColorTempMin > t.ColorTempMax {
+			add("transform.color_temp_min", "invalid", "color_temp_min must not exceed color_temp_max")
+		}
+	}
+	if o := m.Overrides; o != nil {
+		if o.Brightness != nil && (*o.Brightness < 0 || *o.Brightness > 100) {
+			add("overrides.brightness", "invalid", "brightness must be between 0 and 100")
+		}
+		if o.BrightnessScale != nil && *o.BrightnessScale <= 0 {
+			add("overrides.brightness_scale", "invalid", "brightness_scale must be positive")
+		}
+	}
+
+	return errs
+}
+
+// checkResource verifies the bridge and the HUE resource of a mapping or member
+func (v *mappingValidator) checkResource(add func(field, code, message string), prefix, bridge, hueType, hueID string) {
+	if prefix != "" {
+		prefix += "."
+	}
+
+	if hueID == "" {
+		add(prefix+"hue_id", "required", "hue_id is required")
+		return
+	}
+	if _, err := v.bridges.Get(bridge); err != nil {
+		add(prefix+"bridge", "unknown_bridge", err.Error())
+		return
+	}
+
+	if res := v.lookup(bridge); res != nil && res.Known(hueType) && !res.Has(hueType, hueID) {
+		add(prefix+"hue_id", "not_found", hueType+" "+hueID+" does not exist on the bridge")
+	}
+}
+
+// validationErrorResponse writes the field errors of a rejected mapping
+func validationErrorResponse(w http.ResponseWriter, errs []FieldError) {
+	jsonResponse(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":  "mapping validation failed",
+		"fields": errs,
+	})
+}
+
+// ValidateMappings checks all mappings against the bridges and reports
+// orphaned, duplicate and invalid mappings
+func (h *Handlers) ValidateMappings(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, h.validationReport(true))
+}
+
+// validationReport validates all mappings, refresh reloads the bridge
+// resources instead of using the cache
+func (h *Handlers) validationReport(refresh bool) ValidationReport {
+	mappings := config.GetMappings()
+	validator := newMappingValidator(h.bridges, refresh)
+
+	report := ValidationReport{
+		Total:      len(mappings),
+		Orphaned:   make([]MappingIssue, 0),
+		Duplicates: h.mappingManager.Conflicts(),
+		Invalid:    make([]MappingIssue, 0),
+	}
+
+	for _, m := range mappings {
+		// Duplicates are reported through the mapping index conflicts
+		errs := validator.validate(m, nil)
+		orphaned := false
+		for _, err := range errs {
+			if err.Code == "not_found" {
+				orphaned = true
+			}
+		}
+
+		issue := MappingIssue{MappingID: m.ID, LoxoneID: m.LoxoneID, Name: m.Name, Errors: errs}
+		switch {
+		case len(errs) == 0:
+			report.Valid++
+		case orphaned:
+			report.Orphaned = append(report.Orphaned, issue)
+		default:
+			report.Invalid = append(report.Invalid, issue)
+		}
+	}
+
+	for bridge := range validator.unverified {
+		if bridge == "" {
+			bridge = "primary"
+		}
+		report.Unverified = append(report.Unverified, bridge)
+	}
+	sort.Strings(report.Unverified)
+	return report
+}
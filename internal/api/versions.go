@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/config"
+)
+
+// SetConfigWatcher sets the watcher that applies restored configurations
+func (s *Server) SetConfigWatcher(watcher *config.Watcher) {
+	s.handlers.configWatcher = watcher
+}
+
+// GetConfigVersions lists the previous configurations, newest first
+func (h *Handlers) GetConfigVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := config.Versions()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// RestoreConfigVersion replaces the configuration with a previous version
+// and applies it like an edited config file
+func (h *Handlers) RestoreConfigVersion(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if h.configWatcher == nil {
+		errorResponse(w, http.StatusServiceUnavailable, "config reload not available")
+		return
+	}
+
+	versions, err := config.Versions()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	found := false
+	for _, v := range versions {
+		if v.ID == id {
+			found = true
+		}
+	}
+	if !found {
+		errorResponse(w, http.StatusNotFound, "version not found")
+		return
+	}
+
+	if err := h.configWatcher.Restore(id); err != nil {
+		log.Error().Err(err).Str("version", id).Msg("Failed to restore config version")
+		errorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":    "restored",
+		"version":   id,
+		"mappings":  len(config.GetMappings()),
+		"conflicts": h.mappingManager.Conflicts(),
+	})
+}
@@ -3,64 +3,97 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/history"
 	"github.com/sbeyeler/loxone2hue/internal/hue"
 	"github.com/sbeyeler/loxone2hue/internal/loxone"
+	"github.com/sbeyeler/loxone2hue/internal/metrics"
 	"github.com/sbeyeler/loxone2hue/internal/models"
+	"github.com/sbeyeler/loxone2hue/internal/stats"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
+	CheckOrigin:     checkWebSocketOrigin,
 }
 
 // WebSocketHub manages WebSocket connections
 type WebSocketHub struct {
 	clients    map[*WebSocketClient]bool
-	broadcast  chan []byte
+	broadcast  chan hubMessage
 	register   chan *WebSocketClient
 	unregister chan *WebSocketClient
 	mu         sync.RWMutex
 
 	hueClient      *hue.Client
+	bridges        *hue.Registry
 	mappingManager *loxone.MappingManager
 	commandParser  *loxone.CommandParser
+	echo           *loxone.EchoSuppressor
+	history        *history.Store
+	stats          *stats.Store
+	running        atomic.Bool
+}
+
+// hubMessage is a message sent to the connected clients
+type hubMessage struct {
+	data          []byte
+	dashboardOnly bool // Not sent to Loxone clients, e.g. raw HUE events
 }
 
 // WebSocketClient represents a connected WebSocket client
 type WebSocketClient struct {
-	hub      *WebSocketHub
-	conn     *websocket.Conn
-	send     chan []byte
-	clientID string
-	isLoxone bool
+	hub        *WebSocketHub
+	conn       *websocket.Conn
+	send       chan []byte
+	clientID   string
+	remoteAddr string
+	isLoxone   bool
+	canControl bool // Token role allows commands
+}
+
+// kind returns the client kind used as metrics label
+func (c *WebSocketClient) kind() string {
+	if c.isLoxone {
+		return "loxone"
+	}
+	return "browser"
 }
 
 // NewWebSocketHub creates a new WebSocket hub
-func NewWebSocketHub(hueClient *hue.Client, mappingManager *loxone.MappingManager) *WebSocketHub {
+func NewWebSocketHub(bridges *hue.Registry, mappingManager *loxone.MappingManager) *WebSocketHub {
 	return &WebSocketHub{
 		clients:        make(map[*WebSocketClient]bool),
-		broadcast:      make(chan []byte, 256),
+		broadcast:      make(chan hubMessage, 256),
 		register:       make(chan *WebSocketClient),
 		unregister:     make(chan *WebSocketClient),
-		hueClient:      hueClient,
+		hueClient:      bridges.Primary(),
+		bridges:        bridges,
 		mappingManager: mappingManager,
 		commandParser:  loxone.NewCommandParser(),
+		echo:           loxone.NewEchoSuppressor(),
 	}
 }
 
 // Run starts the hub's event loop
 func (h *WebSocketHub) Run(ctx context.Context) {
-	// Forward HUE events to WebSocket clients
-	go h.forwardHueEvents(ctx)
+	h.running.Store(true)
+	defer h.running.Store(false)
+
+	// Forward HUE events of all bridges to WebSocket clients
+	go h.forwardHueEvents(ctx, "", h.hueClient)
+	for _, id := range h.bridges.IDs() {
+		client, _ := h.bridges.Get(id)
+		go h.forwardHueEvents(ctx, id, client)
+	}
 
 	for {
 		select {
@@ -70,6 +103,7 @@ func (h *WebSocketHub) Run(ctx context.Context) {
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
+			metrics.WebSocketClients.Add(1, client.kind())
 			log.Info().Str("client", client.clientID).Bool("loxone", client.isLoxone).Msg("Client connected")
 
 		case client := <-h.unregister:
@@ -77,32 +111,44 @@ func (h *WebSocketHub) Run(ctx context.Context) {
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
+				metrics.WebSocketClients.Add(-1, client.kind())
 			}
 			h.mu.Unlock()
 			log.Info().Str("client", client.clientID).Msg("Client disconnected")
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
+			// Write lock, clients with a full send queue are removed
+			h.mu.Lock()
 			for client := range h.clients {
+				if message.dashboardOnly && client.isLoxone {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
 					close(client.send)
 					delete(h.clients, client)
+					metrics.WebSocketClients.Add(-1, client.kind())
+					metrics.WebSocketSendDropped.Inc()
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
 		}
 	}
 }
 
-// forwardHueEvents forwards HUE events to connected clients
-func (h *WebSocketHub) forwardHueEvents(ctx context.Context) {
+// forwardHueEvents forwards HUE events of a bridge to connected clients
+func (h *WebSocketHub) forwardHueEvents(ctx context.Context, bridge string, client *hue.Client) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case event := <-h.hueClient.Events():
+		case <-client.Connects():
+			go h.seedLightStats(bridge, client)
+
+		case event := <-client.Events():
+			h.recordHueEvent(bridge, event)
+
 			// Convert to status message
 			status := models.LoxoneStatus{
 				Type:   "status",
@@ -115,11 +161,84 @@ func (h *WebSocketHub) forwardHueEvents(ctx context.Context) {
 				continue
 			}
 
-			h.broadcast <- data
+			// Raw HUE IDs are for the dashboard, Loxone clients only get
+			// the states of mapped IDs below
+			h.broadcast <- hubMessage{data: data, dashboardOnly: true}
+
+			if event.Type == "light" {
+				h.recordLightState(bridge, client, event)
+				h.broadcastMappedLight(bridge, client, event.ID)
+				h.broadcastVirtualGroups(bridge, event.ID)
+			}
+		}
+	}
+}
+
+// broadcastMappedLight sends the light state to every Loxone ID the light is mapped to
+func (h *WebSocketHub) broadcastMappedLight(bridge string, client *hue.Client, lightID string) {
+	mappings := h.mappingManager.GetAllByHueID(bridge, lightID)
+	if len(mappings) == 0 {
+		return
+	}
+
+	light, err := client.GetLight(lightID)
+	if err != nil {
+		return
+	}
+
+	for _, mapping := range mappings {
+		if mapping.HueType == "light" && mapping.LoxoneID != lightID {
+			h.broadcastToLoxone(mapping, loxone.ReverseState(light.State, mapping.Transform))
 		}
 	}
 }
 
+// broadcastVirtualGroups sends the aggregated state of every virtual group
+// that contains the changed light
+func (h *WebSocketHub) broadcastVirtualGroups(bridge, lightID string) {
+	for _, vg := range h.mappingManager.VirtualGroups() {
+		lights, err := memberLights(h.bridges, vg.Members)
+		if err != nil {
+			continue
+		}
+
+		for _, light := range lights {
+			if light.ID != lightID || !h.hasMemberOnBridge(vg.Members, bridge) {
+				continue
+			}
+
+			state, err := aggregateState(h.bridges, vg.Members)
+			if err != nil {
+				break
+			}
+			h.broadcastToLoxone(vg, loxone.ReverseState(state, vg.Transform))
+			break
+		}
+	}
+}
+
+// broadcastToLoxone sends the state of a mapping unless the mapping does not
+// report to Loxone or the state is the echo of a Loxone command
+func (h *WebSocketHub) broadcastToLoxone(mapping models.Mapping, state interface{}) {
+	if !loxone.SendsToLoxone(mapping.Direction) {
+		return
+	}
+	if on, brightness := loxone.StateOnBrightness(state); h.echo.IsEcho(mapping.LoxoneID, on, brightness) {
+		log.Debug().Str("target", mapping.LoxoneID).Msg("Suppressed echo of Loxone command")
+		return
+	}
+	h.BroadcastStatus(mapping.LoxoneID, state)
+}
+
+func (h *WebSocketHub) hasMemberOnBridge(members []models.MappingMember, bridge string) bool {
+	for _, m := range members {
+		if m.Bridge == bridge {
+			return true
+		}
+	}
+	return false
+}
+
 // HandleWebSocket handles WebSocket upgrade requests and HTTP command requests
 func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Check if this is an HTTP command request (for testing via browser)
@@ -143,11 +262,13 @@ func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &WebSocketClient{
-		hub:      h,
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		clientID: clientID,
-		isLoxone: isLoxone,
+		hub:        h,
+		conn:       conn,
+		send:       make(chan []byte, 256),
+		clientID:   clientID,
+		remoteAddr: commandRemoteAddr(r),
+		isLoxone:   isLoxone,
+		canControl: canControl(r),
 	}
 
 	h.register <- client
@@ -160,6 +281,12 @@ func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 func (h *WebSocketHub) handleHTTPCommand(w http.ResponseWriter, r *http.Request, cmdStr string) {
 	w.Header().Set("Content-Type", "application/json")
 
+	if !canControl(r) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "API token requires role " + RoleControl})
+		return
+	}
+
 	// Parse the command
 	cmd, err := h.commandParser.ParseText(cmdStr)
 	if err != nil {
@@ -176,113 +303,41 @@ func (h *WebSocketHub) handleHTTPCommand(w http.ResponseWriter, r *http.Request,
 		Interface("params", cmd.Params).
 		Msg("Received HTTP command")
 
-	// Resolve target to HUE resource
-	hueID, hueType, ok := h.mappingManager.ResolveTarget(cmd.Target)
-	if !ok {
-		// Try using target directly as HUE ID
-		hueID = cmd.Target
-		hueType = "light"
-	}
-
-	var execErr error
-
-	switch cmd.Action {
-	case "set":
-		deviceCmd := h.commandParser.ToDeviceCommand(cmd)
-
-		switch hueType {
-		case "light":
-			execErr = h.hueClient.SetLightState(hueID, deviceCmd)
-		case "group":
-			execErr = h.hueClient.SetGroupState(hueID, deviceCmd)
-		}
-
-	case "scene":
-		sceneID, ok := cmd.Params["scene_id"].(string)
-		if !ok {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "scene_id required"})
-			return
-		}
-		// Resolve scene mapping to HUE scene ID
-		resolvedHueID, resolvedHueType, resolved := h.mappingManager.ResolveTarget(sceneID)
-		if resolved && resolvedHueType == "scene" {
-			hueID = resolvedHueID
-			hueType = resolvedHueType
-			execErr = h.hueClient.ActivateScene(resolvedHueID)
-		} else {
-			// Try using sceneID directly as HUE scene ID
-			hueID = sceneID
-			hueType = "scene"
-			execErr = h.hueClient.ActivateScene(sceneID)
-		}
-
-	case "mood":
-		moodNum, ok := cmd.Params["mood_number"].(int)
-		if !ok {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "mood_number required"})
-			return
-		}
-
-		// Resolve mood mapping
-		moodHueID, moodHueType, resolved := h.mappingManager.ResolveMood(cmd.Target, moodNum)
-		if !resolved {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error":       "no mapping found for mood",
-				"target":      cmd.Target,
-				"mood_number": string(rune('0' + moodNum)),
-			})
-			return
-		}
-
-		hueID = moodHueID
-		hueType = moodHueType
-
-		if moodNum == 0 {
-			// Mood 0 = turn off the group/light
-			off := false
-			offCmd := models.DeviceCommand{On: &off}
-			switch moodHueType {
-			case "light":
-				execErr = h.hueClient.SetLightState(moodHueID, offCmd)
-			case "group":
-				execErr = h.hueClient.SetGroupState(moodHueID, offCmd)
+	result, execErr := h.executeCommand(cmd, &commandSource{
+		source:     history.SourceHTTP,
+		remoteAddr: commandRemoteAddr(r),
+		raw:        cmdStr,
+	})
+	if execErr != nil {
+		status := http.StatusInternalServerError
+		body := map[string]string{"error": execErr.Error()}
+
+		var cmdErr *commandError
+		if errors.As(execErr, &cmdErr) {
+			status = cmdErr.status
+			for k, v := range cmdErr.details {
+				body[k] = v
 			}
 		} else {
-			// Mood > 0 = activate scene
-			if moodHueType == "scene" {
-				execErr = h.hueClient.ActivateScene(moodHueID)
-			} else {
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]string{
-					"error": "mood mapping must be a scene",
-				})
-				return
-			}
+			log.Error().Err(execErr).Str("target", cmd.Target).Msg("Failed to execute HTTP command")
 		}
 
-	default:
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "unsupported action: " + cmd.Action})
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
 		return
 	}
 
-	if execErr != nil {
-		log.Error().Err(execErr).Str("target", cmd.Target).Msg("Failed to execute HTTP command")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": execErr.Error()})
-		return
+	response := map[string]interface{}{
+		"status":   "ok",
+		"target":   result.Target,
+		"action":   result.Action,
+		"hue_id":   result.HueID,
+		"hue_type": result.HueType,
 	}
-
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "ok",
-		"target":  cmd.Target,
-		"action":  cmd.Action,
-		"hue_id":  hueID,
-		"hue_type": hueType,
-	})
+	if result.State != nil {
+		response["state"] = result.State
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 // BroadcastStatus sends a status update to all connected clients
@@ -298,7 +353,7 @@ func (h *WebSocketHub) BroadcastStatus(deviceID string, state interface{}) {
 		return
 	}
 
-	h.broadcast <- data
+	h.broadcast <- hubMessage{data: data}
 }
 
 // readPump reads messages from the WebSocket connection
@@ -330,6 +385,11 @@ func (c *WebSocketClient) readPump() {
 
 // handleMessage processes incoming messages
 func (c *WebSocketClient) handleMessage(message []byte) {
+	if !c.canControl {
+		c.sendError("API token requires role " + RoleControl)
+		return
+	}
+
 	// Try to parse as JSON command first
 	cmd, err := c.hub.commandParser.ParseJSON(message)
 	if err != nil {
@@ -348,122 +408,34 @@ func (c *WebSocketClient) handleMessage(message []byte) {
 		Str("action", cmd.Action).
 		Msg("Received command")
 
-	// Resolve target to HUE resource
-	hueID, hueType, ok := c.hub.mappingManager.ResolveTarget(cmd.Target)
-	if !ok {
-		// Try using target directly as HUE ID
-		hueID = cmd.Target
-		hueType = "light"
-	}
-
-	switch cmd.Action {
-	case "set":
-		deviceCmd := c.hub.commandParser.ToDeviceCommand(cmd)
-		var err error
-
-		switch hueType {
-		case "light":
-			err = c.hub.hueClient.SetLightState(hueID, deviceCmd)
-		case "group":
-			err = c.hub.hueClient.SetGroupState(hueID, deviceCmd)
-		}
-
-		if err != nil {
-			log.Error().Err(err).Str("target", cmd.Target).Msg("Failed to execute command")
-			c.sendError(err.Error())
-			return
-		}
-
-		c.sendAck(cmd.Target)
-
-	case "scene":
-		sceneID, ok := cmd.Params["scene_id"].(string)
-		if !ok {
-			c.sendError("scene_id required")
-			return
-		}
-
-		// Resolve scene mapping to HUE scene ID
-		resolvedHueID, resolvedHueType, resolved := c.hub.mappingManager.ResolveTarget(sceneID)
-		if resolved && resolvedHueType == "scene" {
-			if err := c.hub.hueClient.ActivateScene(resolvedHueID); err != nil {
-				log.Error().Err(err).Str("scene", resolvedHueID).Msg("Failed to activate scene")
-				c.sendError(err.Error())
-				return
-			}
-		} else {
-			// Try using sceneID directly as HUE scene ID
-			if err := c.hub.hueClient.ActivateScene(sceneID); err != nil {
-				log.Error().Err(err).Str("scene", sceneID).Msg("Failed to activate scene")
-				c.sendError(err.Error())
-				return
-			}
-		}
-
-		c.sendAck(cmd.Target)
-
-	case "mood":
-		moodNum, ok := cmd.Params["mood_number"].(int)
-		if !ok {
-			c.sendError("mood_number required")
-			return
-		}
-
-		// Resolve mood mapping
-		moodHueID, moodHueType, resolved := c.hub.mappingManager.ResolveMood(cmd.Target, moodNum)
-		if !resolved {
-			c.sendError("no mapping found for mood")
-			return
-		}
-
-		if moodNum == 0 {
-			// Mood 0 = turn off the group/light
-			off := false
-			offCmd := models.DeviceCommand{On: &off}
-			var err error
-			switch moodHueType {
-			case "light":
-				err = c.hub.hueClient.SetLightState(moodHueID, offCmd)
-			case "group":
-				err = c.hub.hueClient.SetGroupState(moodHueID, offCmd)
-			}
-			if err != nil {
-				log.Error().Err(err).Str("target", cmd.Target).Int("mood", moodNum).Msg("Failed to turn off")
-				c.sendError(err.Error())
-				return
-			}
-		} else {
-			// Mood > 0 = activate scene
-			if moodHueType == "scene" {
-				if err := c.hub.hueClient.ActivateScene(moodHueID); err != nil {
-					log.Error().Err(err).Str("scene", moodHueID).Int("mood", moodNum).Msg("Failed to activate mood scene")
-					c.sendError(err.Error())
-					return
-				}
-			} else {
-				c.sendError("mood mapping must be a scene")
-				return
-			}
-		}
-
-		c.sendAck(cmd.Target)
-
-	case "STATUS":
-		light, err := c.hub.hueClient.GetLight(hueID)
-		if err != nil {
-			c.sendError(err.Error())
-			return
+	result, err := c.hub.executeCommand(cmd, &commandSource{
+		source:     history.SourceWebSocket,
+		client:     c.clientID,
+		remoteAddr: c.remoteAddr,
+		raw:        string(message),
+	})
+	if err != nil {
+		var cmdErr *commandError
+		if !errors.As(err, &cmdErr) {
+			log.Error().Err(err).Str("target", cmd.Target).Str("action", cmd.Action).Msg("Failed to execute command")
 		}
+		c.sendError(err.Error())
+		return
+	}
 
+	if result.State != nil {
 		status := models.LoxoneStatus{
 			Type:   "status",
 			Device: cmd.Target,
-			State:  light.State,
+			State:  result.State,
 		}
 
 		data, _ := json.Marshal(status)
 		c.send <- data
+		return
 	}
+
+	c.sendAck(cmd.Target)
 }
 
 func (c *WebSocketClient) sendAck(target string) {
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/sbeyeler/loxone2hue/internal/models"
@@ -11,29 +15,92 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Hue      HueConfig      `yaml:"hue"`
-	Loxone   LoxoneConfig   `yaml:"loxone"`
-	Logging  LoggingConfig  `yaml:"logging"`
-	Mappings []models.Mapping `yaml:"mappings"`
+	Server     ServerConfig       `yaml:"server"`
+	Hue        HueConfig          `yaml:"hue"`
+	Loxone     LoxoneConfig       `yaml:"loxone"`
+	Auth       AuthConfig         `yaml:"auth"`
+	Logging    LoggingConfig      `yaml:"logging"`
+	History    HistoryConfig      `yaml:"history,omitempty"`
+	Stats      StatsConfig        `yaml:"stats,omitempty"`
+	Mappings   []models.Mapping   `yaml:"mappings"`
+	MoodTables []models.MoodTable `yaml:"mood_tables,omitempty"`
 }
 
 // ServerConfig holds HTTP server settings
 type ServerConfig struct {
-	Port int    `yaml:"port"`
-	Host string `yaml:"host"`
+	Port    int           `yaml:"port"`
+	Host    string        `yaml:"host"`
+	TLS     TLSConfig     `yaml:"tls,omitempty"`
+	Ingress IngressConfig `yaml:"ingress,omitempty"`
+}
+
+// IngressConfig holds Home Assistant ingress settings
+type IngressConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	SupervisorIP string `yaml:"supervisor_ip,omitempty" json:"supervisor_ip,omitempty"` // Only requests from this IP are treated as ingress, default 172.30.32.2
+	LoxonePort   int    `yaml:"loxone_port,omitempty" json:"loxone_port,omitempty"`     // Listener serving only the Loxone endpoints, always token protected
+}
+
+// TLSConfig holds HTTPS settings. Without cert and key files a self-signed
+// certificate is generated next to the config file.
+type TLSConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	CertFile     string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
+	KeyFile      string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+	HTTPPort     int    `yaml:"http_port,omitempty" json:"http_port,omitempty"`         // Additional plain HTTP listener, e.g. for Loxone virtual outputs
+	RedirectHTTP bool   `yaml:"redirect_http,omitempty" json:"redirect_http,omitempty"` // Redirect the HTTP listener to HTTPS except Loxone endpoints
 }
 
 // HueConfig holds HUE bridge settings
 type HueConfig struct {
-	BridgeIP       string `yaml:"bridge_ip"`
-	ApplicationKey string `yaml:"application_key"`
+	BridgeIP       string         `yaml:"bridge_ip"`
+	ApplicationKey string         `yaml:"application_key"`
+	ClientKey      string         `yaml:"client_key"`        // PSK for entertainment streaming
+	Bridges        []BridgeConfig `yaml:"bridges,omitempty"` // Additional bridges for virtual groups
+}
+
+// BridgeConfig holds the settings of an additional HUE bridge
+type BridgeConfig struct {
+	ID             string `yaml:"id" json:"id"`
+	BridgeIP       string `yaml:"bridge_ip" json:"bridge_ip"`
+	ApplicationKey string `yaml:"application_key" json:"-"`
 }
 
 // LoxoneConfig holds Loxone integration settings
 type LoxoneConfig struct {
 	Enabled      bool   `yaml:"enabled"`
 	MiniserverIP string `yaml:"miniserver_ip"`
+	Username     string `yaml:"username,omitempty"` // Miniserver user, e.g. to fetch LoxAPP3.json
+	Password     string `yaml:"password,omitempty" json:"-"`
+	WebSocket    bool   `yaml:"websocket,omitempty"` // Follow control states via the Miniserver WebSocket API
+}
+
+// AuthConfig holds API authentication settings
+type AuthConfig struct {
+	Enabled        bool       `yaml:"enabled" json:"enabled"`
+	Tokens         []APIToken `yaml:"tokens,omitempty" json:"-"`
+	AllowedOrigins []string   `yaml:"allowed_origins,omitempty" json:"allowed_origins,omitempty"` // CORS and WebSocket origins, "*" allows all
+}
+
+// APIToken is an API token, only the SHA-256 hash of the token is stored
+type APIToken struct {
+	ID        string    `yaml:"id" json:"id"`
+	Name      string    `yaml:"name" json:"name"`
+	Role      string    `yaml:"role" json:"role"` // read, control or admin
+	Hash      string    `yaml:"hash" json:"-"`
+	Prefix    string    `yaml:"prefix" json:"prefix"` // First characters of the token for identification
+	CreatedAt time.Time `yaml:"created_at" json:"created_at"`
+}
+
+// HistoryConfig holds audit log settings
+type HistoryConfig struct {
+	MaxEntries int `yaml:"max_entries,omitempty" json:"max_entries,omitempty"` // Entries kept in history.jsonl next to the config, default 10000
+}
+
+// StatsConfig holds usage statistics settings
+type StatsConfig struct {
+	RetentionDays int                `yaml:"retention_days,omitempty" json:"retention_days,omitempty"` // Days of light transitions kept in stats.db, default 365
+	Wattage       map[string]float64 `yaml:"wattage,omitempty" json:"wattage,omitempty"`               // Watts at full brightness by HUE model ID
 }
 
 // LoggingConfig holds logging settings
@@ -47,6 +114,7 @@ var (
 	cfgOnce sync.Once
 	cfgPath string
 	mu      sync.RWMutex
+	saveMu  sync.Mutex // Serializes writes of the config file
 )
 
 // DefaultConfig returns a configuration with default values
@@ -59,11 +127,15 @@ func DefaultConfig() *Config {
 		Hue: HueConfig{
 			BridgeIP:       "",
 			ApplicationKey: "",
+			ClientKey:      "",
 		},
 		Loxone: LoxoneConfig{
 			Enabled:      true,
 			MiniserverIP: "",
 		},
+		Auth: AuthConfig{
+			Enabled: true, // Takes effect with the first token
+		},
 		Logging: LoggingConfig{
 			Level:  "info",
 			Format: "json",
@@ -92,53 +164,140 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	loaded := DefaultConfig()
+	if err := yaml.Unmarshal(data, loaded); err != nil {
 		return nil, err
 	}
+	cfg = loaded
+	fileHash = sha256.Sum256(data)
 
 	log.Info().Str("path", path).Msg("Configuration loaded")
 	return cfg, nil
 }
 
-// Get returns the current configuration
+// Dir returns the directory of the config file
+func Dir() string {
+	if cfgPath == "" {
+		return "."
+	}
+	return filepath.Dir(cfgPath)
+}
+
+// Get returns the current configuration. Changes replace the configuration
+// instead of modifying it, so the result is a consistent snapshot that must
+// not be modified.
 func Get() *Config {
 	mu.RLock()
 	defer mu.RUnlock()
 	return cfg
 }
 
-// Save writes the current configuration to the file
+// Save writes the current configuration to the file. The file is replaced
+// atomically and only readable by the owner since it holds the bridge keys,
+// the previous content is kept as a version.
 func Save() error {
-	mu.RLock()
-	defer mu.RUnlock()
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	return save()
+}
 
+// save writes the configuration, the caller holds saveMu
+func save() error {
+	mu.RLock()
 	if cfgPath == "" {
 		cfgPath = "config.yaml"
 	}
-
 	data, err := yaml.Marshal(cfg)
+	mu.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(cfgPath, data, 0644)
+	if err := keepVersion(cfgPath, data); err != nil {
+		log.Warn().Err(err).Msg("Failed to keep previous config version")
+	}
+	if err := writeFileAtomic(cfgPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	fileHash = sha256.Sum256(data)
+	pruneVersions()
+	return nil
 }
 
-// UpdateHue updates the HUE configuration
-func UpdateHue(bridgeIP, applicationKey string) {
+// update replaces the configuration with a changed copy, configurations
+// returned by Get are never modified
+func update(change func(c *Config)) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	cfg.Hue.BridgeIP = bridgeIP
-	cfg.Hue.ApplicationKey = applicationKey
+	next := *cfg
+	change(&next)
+	cfg = &next
+}
+
+// UpdateHue updates the HUE configuration
+func UpdateHue(bridgeIP, applicationKey, clientKey string) {
+	update(func(c *Config) {
+		c.Hue.BridgeIP = bridgeIP
+		c.Hue.ApplicationKey = applicationKey
+		c.Hue.ClientKey = clientKey
+	})
+}
+
+// UpdateLoxone replaces the Loxone settings, the password is only set in
+// the config file and kept
+func UpdateLoxone(loxone LoxoneConfig) {
+	update(func(c *Config) {
+		loxone.Password = c.Loxone.Password
+		c.Loxone = loxone
+	})
+}
+
+// UpdateAuth replaces the authentication settings, the tokens are managed
+// through UpdateTokens and kept
+func UpdateAuth(auth AuthConfig) {
+	update(func(c *Config) {
+		auth.Tokens = c.Auth.Tokens
+		c.Auth = auth
+	})
+}
+
+// UpdateTokens updates the API tokens
+func UpdateTokens(tokens []APIToken) {
+	update(func(c *Config) {
+		c.Auth.Tokens = tokens
+	})
+}
+
+// GetAuth returns a copy of the authentication settings
+func GetAuth() AuthConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	auth := cfg.Auth
+	auth.Tokens = append([]APIToken(nil), cfg.Auth.Tokens...)
+	auth.AllowedOrigins = append([]string(nil), cfg.Auth.AllowedOrigins...)
+	return auth
+}
+
+// GetStats returns a copy of the statistics settings
+func GetStats() StatsConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	stats := cfg.Stats
+	stats.Wattage = make(map[string]float64, len(cfg.Stats.Wattage))
+	for model, watts := range cfg.Stats.Wattage {
+		stats.Wattage[model] = watts
+	}
+	return stats
 }
 
 // UpdateMappings updates the mappings configuration
 func UpdateMappings(mappings []models.Mapping) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	cfg.Mappings = mappings
+	update(func(c *Config) {
+		c.Mappings = mappings
+	})
 }
 
 // GetMappings returns a copy of current mappings
@@ -150,3 +309,20 @@ func GetMappings() []models.Mapping {
 	copy(result, cfg.Mappings)
 	return result
 }
+
+// UpdateMoodTables updates the mood tables in the configuration
+func UpdateMoodTables(tables []models.MoodTable) {
+	update(func(c *Config) {
+		c.MoodTables = tables
+	})
+}
+
+// GetMoodTables returns a copy of the configured mood tables
+func GetMoodTables() []models.MoodTable {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]models.MoodTable, len(cfg.MoodTables))
+	copy(result, cfg.MoodTables)
+	return result
+}
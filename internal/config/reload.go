@@ -0,0 +1,168 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// fileHash is the checksum of the config file as last read or written by
+// the gateway, used to tell external edits from our own saves
+var fileHash [sha256.Size]byte
+
+var (
+	validLogLevels  = map[string]bool{"": true, "debug": true, "info": true, "warn": true, "error": true}
+	validLogFormats = map[string]bool{"": true, "json": true, "console": true, "text": true}
+)
+
+// Validate checks a configuration before it replaces the active one
+func Validate(c *Config) error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port %d is out of range", c.Server.Port)
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		add("server.tls needs both cert_file and key_file")
+	}
+	if !validLogLevels[c.Logging.Level] {
+		add("logging.level %q is unknown", c.Logging.Level)
+	}
+	if !validLogFormats[c.Logging.Format] {
+		add("logging.format %q is unknown", c.Logging.Format)
+	}
+
+	bridgeIDs := make(map[string]bool)
+	for i, b := range c.Hue.Bridges {
+		switch {
+		case b.ID == "":
+			add("hue.bridges[%d].id is required", i)
+		case bridgeIDs[b.ID]:
+			add("hue.bridges[%d].id %q is used twice", i, b.ID)
+		}
+		bridgeIDs[b.ID] = true
+	}
+
+	for i, m := range c.Mappings {
+		if m.LoxoneID == "" {
+			add("mappings[%d].loxone_id is required", i)
+		}
+		if !models.ValidHueType(m.HueType) {
+			add("mappings[%d].hue_type %q is unknown", i, m.HueType)
+		}
+		if m.HueID == "" && m.HueType != "virtual_group" {
+			add("mappings[%d].hue_id is required", i)
+		}
+		if m.Bridge != "" && !bridgeIDs[m.Bridge] {
+			add("mappings[%d].bridge %q is not configured", i, m.Bridge)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Watcher polls the config file and applies changes made outside the
+// gateway, e.g. with an editor. Polling also catches files replaced by
+// rename, which editors and the add-on start script do.
+type Watcher struct {
+	interval time.Duration
+	prepare  func(c *Config)
+	onChange func(old, next *Config)
+	applyMu  sync.Mutex // Serializes onChange of file edits and restores
+}
+
+// NewWatcher creates a watcher. prepare is applied to every loaded file
+// before validation (e.g. command line overrides), onChange is called after
+// a valid file has become the active configuration.
+func NewWatcher(interval time.Duration, prepare func(c *Config), onChange func(old, next *Config)) *Watcher {
+	return &Watcher{interval: interval, prepare: prepare, onChange: onChange}
+}
+
+// Run checks the file until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if old, next := w.check(); next != nil {
+				w.Apply(old, next)
+			}
+		}
+	}
+}
+
+// Apply passes a new active configuration to onChange, also used for
+// changes saved by the gateway itself that the watcher does not see
+func (w *Watcher) Apply(old, next *Config) {
+	if w.onChange == nil {
+		return
+	}
+	w.applyMu.Lock()
+	defer w.applyMu.Unlock()
+	w.onChange(old, next)
+}
+
+// check loads the file if it changed and activates it when it is valid.
+// It returns the previous and the new configuration after a change.
+func (w *Watcher) check() (*Config, *Config) {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	mu.RLock()
+	path := cfgPath
+	mu.RUnlock()
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Missing until the first save, or briefly while replaced
+		return nil, nil
+	}
+	sum := sha256.Sum256(data)
+	if sum == fileHash {
+		return nil, nil
+	}
+	// Remember the content so an invalid file is reported only once
+	fileHash = sum
+
+	next := DefaultConfig()
+	if err := yaml.Unmarshal(data, next); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Config file is not valid YAML, keeping the previous configuration")
+		return nil, nil
+	}
+	if w.prepare != nil {
+		w.prepare(next)
+	}
+	if err := Validate(next); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Invalid config file, keeping the previous configuration")
+		return nil, nil
+	}
+
+	mu.Lock()
+	old := cfg
+	cfg = next
+	mu.Unlock()
+
+	log.Info().Str("path", path).Msg("Config file changed, applying")
+	return old, next
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// MaxVersions is the number of previous configurations kept
+const MaxVersions = 20
+
+// versionsDirName is the directory next to the config file holding the
+// previous versions
+const versionsDirName = "config-versions"
+
+// versionTimeFormat is used in version file names, sortable and without
+// characters that are invalid on Windows
+const versionTimeFormat = "20060102T150405.000Z"
+
+var versionIDPattern = regexp.MustCompile(`^config-\d{8}T\d{6}\.\d{3}Z$`)
+
+// Version describes a previous configuration
+type Version struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"` // When this version was saved
+	Size      int64     `json:"size"`
+	Mappings  int       `json:"mappings"`
+}
+
+func versionsDir() string {
+	return filepath.Join(Dir(), versionsDirName)
+}
+
+// writeFileAtomic writes data to a temporary file in the target directory
+// and renames it over path, so readers never see a partial file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// keepVersion copies the current config file into the versions directory
+// before it is replaced by data. Unchanged files are not versioned.
+func keepVersion(path string, data []byte) error {
+	current, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read current config: %w", err)
+	}
+	if bytes.Equal(current, data) {
+		return nil
+	}
+
+	savedAt := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		savedAt = info.ModTime()
+	}
+
+	dir := versionsDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create versions directory: %w", err)
+	}
+	name := "config-" + savedAt.UTC().Format(versionTimeFormat) + ".yaml"
+	if err := writeFileAtomic(filepath.Join(dir, name), current, 0600); err != nil {
+		return err
+	}
+	return os.Chtimes(filepath.Join(dir, name), savedAt, savedAt)
+}
+
+// pruneVersions deletes the oldest versions beyond MaxVersions
+func pruneVersions() {
+	ids, err := versionIDs()
+	if err != nil || len(ids) <= MaxVersions {
+		return
+	}
+	for _, id := range ids[MaxVersions:] {
+		if err := os.Remove(filepath.Join(versionsDir(), id+".yaml")); err != nil {
+			log.Warn().Err(err).Str("version", id).Msg("Failed to remove old config version")
+		}
+	}
+}
+
+// versionIDs returns the IDs of all versions, newest first
+func versionIDs() ([]string, error) {
+	entries, err := os.ReadDir(versionsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".yaml")
+		if !entry.IsDir() && versionIDPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	// The timestamp format sorts lexically
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// readVersion loads a version, the ID is checked so no other files can be
+// read
+func readVersion(id string) (*Config, []byte, error) {
+	if !versionIDPattern.MatchString(id) {
+		return nil, nil, fmt.Errorf("invalid version: %s", id)
+	}
+	data, err := os.ReadFile(filepath.Join(versionsDir(), id+".yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("version not found: %s", id)
+		}
+		return nil, nil, err
+	}
+
+	version := DefaultConfig()
+	if err := yaml.Unmarshal(data, version); err != nil {
+		return nil, nil, fmt.Errorf("version %s is not a valid config: %w", id, err)
+	}
+	return version, data, nil
+}
+
+// Versions lists the previous configurations, newest first
+func Versions() ([]Version, error) {
+	ids, err := versionIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config versions: %w", err)
+	}
+
+	versions := make([]Version, 0, len(ids))
+	for _, id := range ids {
+		created, err := time.Parse(versionTimeFormat, strings.TrimPrefix(id, "config-"))
+		if err != nil {
+			continue
+		}
+		v := Version{ID: id, CreatedAt: created}
+		if cfg, data, err := readVersion(id); err == nil {
+			v.Size = int64(len(data))
+			v.Mappings = len(cfg.Mappings)
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// Restore replaces the configuration with a previous version and saves it.
+// The configuration active before is kept as a new version, so a restore
+// can be undone as well. API tokens and bridge keys are secrets that may
+// have been revoked or renewed since, they are kept from the active
+// configuration. The restored configuration is prepared and applied like
+// an edited config file.
+func (w *Watcher) Restore(id string) error {
+	old, next, err := w.restore(id)
+	if err != nil {
+		return err
+	}
+	w.Apply(old, next)
+	return nil
+}
+
+// restore activates and saves a version, it returns the previous and the
+// restored configuration
+func (w *Watcher) restore(id string) (*Config, *Config, error) {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	next, _, err := readVersion(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mu.RLock()
+	keepSecrets(next, cfg)
+	mu.RUnlock()
+	if w.prepare != nil {
+		w.prepare(next)
+	}
+	if err := Validate(next); err != nil {
+		return nil, nil, fmt.Errorf("version %s is not a valid config: %w", id, err)
+	}
+
+	mu.Lock()
+	old := cfg
+	cfg = next
+	mu.Unlock()
+
+	if err := save(); err != nil {
+		return nil, nil, err
+	}
+	log.Info().Str("version", id).Msg("Configuration restored")
+	return old, next, nil
+}
+
+// keepSecrets copies the API tokens and the bridge keys of the active
+// configuration to a restored one
+func keepSecrets(next, active *Config) {
+	next.Auth.Tokens = append([]APIToken(nil), active.Auth.Tokens...)
+
+	next.Hue.BridgeIP = active.Hue.BridgeIP
+	next.Hue.ApplicationKey = active.Hue.ApplicationKey
+	next.Hue.ClientKey = active.Hue.ClientKey
+
+	keys := make(map[string]BridgeConfig, len(active.Hue.Bridges))
+	for _, b := range active.Hue.Bridges {
+		keys[b.ID] = b
+	}
+	for i, b := range next.Hue.Bridges {
+		if current, ok := keys[b.ID]; ok {
+			next.Hue.Bridges[i].BridgeIP = current.BridgeIP
+			next.Hue.Bridges[i].ApplicationKey = current.ApplicationKey
+		}
+	}
+}
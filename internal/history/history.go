@@ -0,0 +1,279 @@
+// Package history keeps a bounded on-disk audit log of executed commands and
+// HUE state changes
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Entry kinds
+const (
+	KindCommand = "command"
+	KindState   = "state"
+)
+
+// Entry sources
+const (
+	SourceWebSocket  = "ws"
+	SourceHTTP       = "http"
+	SourceAPI        = "api"
+	SourceMiniserver = "miniserver"
+	SourceHue        = "hue"
+)
+
+// DefaultMaxEntries is used when no limit is configured
+const DefaultMaxEntries = 10000
+
+// Entry is a single audit log record
+type Entry struct {
+	ID         int64                  `json:"id"`
+	Time       time.Time              `json:"time"`
+	Kind       string                 `json:"kind"`
+	Source     string                 `json:"source"`
+	Client     string                 `json:"client,omitempty"`
+	RemoteAddr string                 `json:"remote_addr,omitempty"`
+	Raw        string                 `json:"raw,omitempty"`
+	Target     string                 `json:"target,omitempty"`
+	Action     string                 `json:"action,omitempty"`
+	Params     map[string]interface{} `json:"params,omitempty"`
+	Bridge     string                 `json:"bridge,omitempty"`
+	HueID      string                 `json:"hue_id,omitempty"`
+	HueType    string                 `json:"hue_type,omitempty"`
+	Result     string                 `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	LatencyMs  float64                `json:"latency_ms,omitempty"`
+	State      interface{}            `json:"state,omitempty"`
+}
+
+// Filter selects entries, empty fields match everything
+type Filter struct {
+	Target string
+	Source string
+	Kind   string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Match reports whether an entry passes the filter. The target matches the
+// Loxone ID as well as the HUE ID.
+func (f Filter) Match(e Entry) bool {
+	if f.Target != "" && e.Target != f.Target && e.HueID != f.Target {
+		return false
+	}
+	if f.Source != "" && e.Source != f.Source {
+		return false
+	}
+	if f.Kind != "" && e.Kind != f.Kind {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Store keeps the newest entries in memory and appends every entry to a
+// JSON lines file, which is compacted once it holds twice the limit
+type Store struct {
+	mu          sync.Mutex
+	path        string
+	max         int
+	entries     []Entry // Oldest first
+	file        *os.File
+	lines       int
+	nextID      int64
+	subscribers map[chan Entry]struct{}
+}
+
+// Open loads the log at path, keeping at most max entries
+func Open(path string, max int) (*Store, error) {
+	if max <= 0 {
+		max = DefaultMaxEntries
+	}
+	s := &Store{path: path, max: max, subscribers: make(map[chan Entry]struct{})}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if s.lines > s.max {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	s.file = file
+	return s, nil
+}
+
+func (s *Store) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A crash can leave a partial last line
+			continue
+		}
+		s.lines++
+		s.entries = append(s.entries, e)
+		if len(s.entries) > s.max {
+			s.entries = s.entries[1:]
+		}
+		if e.ID > s.nextID {
+			s.nextID = e.ID
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the file with the entries kept in memory
+func (s *Store) compact() error {
+	tmp := s.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, e := range s.entries {
+		if err := enc.Encode(e); err != nil {
+			file.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to compact history: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to compact history: %w", err)
+	}
+	s.lines = len(s.entries)
+	return nil
+}
+
+// Add records an entry and passes it to the live subscribers
+func (s *Store) Add(e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	e.ID = s.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	s.entries = append(s.entries, e)
+	if len(s.entries) > s.max {
+		// Copy instead of reslicing so the dropped entries can be freed
+		s.entries = append([]Entry(nil), s.entries[len(s.entries)-s.max:]...)
+	}
+
+	if s.file != nil {
+		if data, err := json.Marshal(e); err == nil {
+			if _, err := s.file.Write(append(data, '\n')); err != nil {
+				log.Warn().Err(err).Msg("Failed to write history entry")
+			}
+			s.lines++
+		}
+		if s.lines >= 2*s.max {
+			s.file.Close()
+			if err := s.compact(); err != nil {
+				log.Warn().Err(err).Msg("Failed to compact history")
+			}
+			file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to reopen history, entries are kept in memory only")
+			}
+			s.file = file
+		}
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// Slow subscriber, skip
+		}
+	}
+}
+
+// Query returns the matching entries, newest first
+func (s *Store) Query(f Filter) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Entry, 0)
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+		if f.Match(s.entries[i]) {
+			result = append(result, s.entries[i])
+		}
+	}
+	return result
+}
+
+// Subscribe returns a channel receiving new entries and a function to end
+// the subscription
+func (s *Store) Subscribe() (<-chan Entry, func()) {
+	ch := make(chan Entry, 64)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close closes the log file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
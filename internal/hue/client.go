@@ -2,15 +2,18 @@ package hue
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/metrics"
 	"github.com/sbeyeler/loxone2hue/internal/models"
 )
 
@@ -18,16 +21,28 @@ import (
 type Client struct {
 	bridgeIP       string
 	applicationKey string
-	httpClient     *http.Client
+	clientKey      string
 	baseURL        string
+	connMu         sync.RWMutex // guards bridgeIP, applicationKey, clientKey and baseURL
+	httpClient     *http.Client
 
-	lights   map[string]*models.Light
-	groups   map[string]*models.Group
-	scenes   map[string]*models.Scene
-	mu       sync.RWMutex
+	lights map[string]*models.Light
+	groups map[string]*models.Group
+	scenes map[string]*models.Scene
+	mu     sync.RWMutex
 
 	eventChan chan Event
 	stopChan  chan struct{}
+	connects  chan struct{} // Signaled when the event stream connects
+
+	streamStatus   EventStreamStatus
+	streamStatusMu sync.RWMutex
+	streamCancel   context.CancelFunc // Ends the current event stream connection
+
+	stream     *streamSession
+	streamMu   sync.Mutex // guards stream and dialStream
+	streamOpMu sync.Mutex // serializes starting and stopping the stream
+	dialStream StreamDialer
 }
 
 // Event represents a HUE event from the SSE stream
@@ -53,29 +68,65 @@ func NewClient(bridgeIP, applicationKey string) *Client {
 			Transport: tr,
 			Timeout:   10 * time.Second,
 		},
-		baseURL:   fmt.Sprintf("https://%s", bridgeIP),
-		lights:    make(map[string]*models.Light),
-		groups:    make(map[string]*models.Group),
-		scenes:    make(map[string]*models.Scene),
-		eventChan: make(chan Event, 100),
-		stopChan:  make(chan struct{}),
+		baseURL:    fmt.Sprintf("https://%s", bridgeIP),
+		lights:     make(map[string]*models.Light),
+		groups:     make(map[string]*models.Group),
+		scenes:     make(map[string]*models.Scene),
+		eventChan:  make(chan Event, 100),
+		connects:   make(chan struct{}, 1),
+		stopChan:   make(chan struct{}),
+		dialStream: dialDTLS,
 	}
 }
 
 // SetBridgeIP updates the bridge IP address
 func (c *Client) SetBridgeIP(ip string) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
 	c.bridgeIP = ip
 	c.baseURL = fmt.Sprintf("https://%s", ip)
 }
 
 // SetApplicationKey updates the application key
 func (c *Client) SetApplicationKey(key string) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
 	c.applicationKey = key
 }
 
+// SetClientKey updates the entertainment client key (hex encoded PSK)
+func (c *Client) SetClientKey(key string) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	c.clientKey = key
+}
+
+// ClientKey returns the entertainment client key received during pairing
+func (c *Client) ClientKey() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.clientKey
+}
+
+// BridgeIP returns the address of the bridge
+func (c *Client) BridgeIP() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.bridgeIP
+}
+
+// connection returns the current bridge address, base URL and application
+// key, which change when the config is reloaded
+func (c *Client) connection() (bridgeIP, baseURL, applicationKey string) {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.bridgeIP, c.baseURL, c.applicationKey
+}
+
 // IsConfigured returns true if the client has bridge IP and application key
 func (c *Client) IsConfigured() bool {
-	return c.bridgeIP != "" && c.applicationKey != ""
+	bridgeIP, _, applicationKey := c.connection()
+	return bridgeIP != "" && applicationKey != ""
 }
 
 // request performs an HTTP request to the HUE Bridge API
@@ -89,17 +140,29 @@ func (c *Client) request(method, path string, body interface{}) ([]byte, error)
 		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	url := fmt.Sprintf("%s%s", c.baseURL, path)
+	bridgeIP, baseURL, applicationKey := c.connection()
+	url := fmt.Sprintf("%s%s", baseURL, path)
 	req, err := http.NewRequest(method, url, reqBody)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.applicationKey != "" {
-		req.Header.Set("hue-application-key", c.applicationKey)
+	if applicationKey != "" {
+		req.Header.Set("hue-application-key", applicationKey)
+	}
+
+	endpoint := endpointLabel(path)
+	start := time.Now()
+	respBody, err := c.do(req)
+	metrics.HueRequestDuration.Observe(time.Since(start).Seconds(), bridgeIP, method, endpoint)
+	if err != nil {
+		metrics.HueRequestErrors.Inc(bridgeIP, method, endpoint)
 	}
+	return respBody, err
+}
 
+func (c *Client) do(req *http.Request) ([]byte, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -118,6 +181,16 @@ func (c *Client) request(method, path string, body interface{}) ([]byte, error)
 	return respBody, nil
 }
 
+// endpointLabel replaces the resource ID of a CLIP v2 path so metrics are
+// grouped per endpoint, e.g. /clip/v2/resource/light/{id}
+func endpointLabel(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) > 5 && parts[1] == "clip" && parts[3] == "resource" {
+		parts[5] = "{id}"
+	}
+	return strings.Join(parts, "/")
+}
+
 // Pair attempts to create a new application key by pressing the bridge button
 func (c *Client) Pair(appName, instanceName string) (string, error) {
 	body := map[string]interface{}{
@@ -125,11 +198,12 @@ func (c *Client) Pair(appName, instanceName string) (string, error) {
 		"generateclientkey": true,
 	}
 
-	log.Info().Str("bridge_ip", c.bridgeIP).Msg("Attempting to pair with HUE Bridge")
+	bridgeIP := c.BridgeIP()
+	log.Info().Str("bridge_ip", bridgeIP).Msg("Attempting to pair with HUE Bridge")
 
 	resp, err := c.request("POST", "/api", body)
 	if err != nil {
-		log.Error().Err(err).Str("bridge_ip", c.bridgeIP).Msg("Failed to connect to HUE Bridge")
+		log.Error().Err(err).Str("bridge_ip", bridgeIP).Msg("Failed to connect to HUE Bridge")
 		return "", fmt.Errorf("connection to bridge failed: %v", err)
 	}
 
@@ -159,9 +233,15 @@ func (c *Client) Pair(appName, instanceName string) (string, error) {
 	if success, ok := result[0]["success"]; ok {
 		successMap := success.(map[string]interface{})
 		if username, ok := successMap["username"]; ok {
-			c.applicationKey = username.(string)
+			applicationKey := username.(string)
+			// The client key is the PSK for entertainment streaming and
+			// only valid together with this application key
+			clientKey, _ := successMap["clientkey"].(string)
+			c.connMu.Lock()
+			c.applicationKey, c.clientKey = applicationKey, clientKey
+			c.connMu.Unlock()
 			log.Info().Msg("Successfully paired with HUE Bridge")
-			return c.applicationKey, nil
+			return applicationKey, nil
 		}
 	}
 
@@ -197,16 +277,19 @@ func (c *Client) GetLights() ([]*models.Light, error) {
 		return nil, err
 	}
 
+	// Replace the cache so lights deleted on the bridge are dropped
 	lights := make([]*models.Light, 0, len(result.Data))
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
+	cache := make(map[string]*models.Light, len(result.Data))
 	for _, hl := range result.Data {
 		light := convertHueLight(hl)
-		c.lights[light.ID] = light
+		cache[light.ID] = light
 		lights = append(lights, light)
 	}
 
+	c.mu.Lock()
+	c.lights = cache
+	c.mu.Unlock()
+
 	log.Debug().Int("count", len(lights)).Msg("Fetched lights from bridge")
 	return lights, nil
 }
@@ -265,6 +348,16 @@ func (c *Client) SetLightState(id string, cmd models.DeviceCommand) error {
 			},
 		}
 	}
+	if len(cmd.Gradient) > 0 {
+		gradient, err := c.gradientBody(id, cmd.Gradient, cmd.GradientMode)
+		if err != nil {
+			return err
+		}
+		body["gradient"] = gradient
+	}
+	if cmd.Transition != nil {
+		body["dynamics"] = map[string]int{"duration": *cmd.Transition}
+	}
 
 	_, err := c.request("PUT", fmt.Sprintf("/clip/v2/resource/light/%s", id), body)
 	if err != nil {
@@ -275,6 +368,46 @@ func (c *Client) SetLightState(id string, cmd models.DeviceCommand) error {
 	return nil
 }
 
+// gradientBody builds the gradient part of a light update and checks the
+// number of points against the light's capabilities if it is known
+func (c *Client) gradientBody(id string, points []models.Color, mode string) (map[string]interface{}, error) {
+	if len(points) < 2 {
+		return nil, fmt.Errorf("gradient requires at least 2 points")
+	}
+
+	c.mu.RLock()
+	light, ok := c.lights[id]
+	c.mu.RUnlock()
+	if ok {
+		if !light.Capabilities.SupportsGradient {
+			return nil, fmt.Errorf("light %s does not support gradients", id)
+		}
+		if len(points) > light.Capabilities.GradientPoints {
+			return nil, fmt.Errorf("light %s supports at most %d gradient points", id, light.Capabilities.GradientPoints)
+		}
+	}
+
+	huePoints := make([]map[string]interface{}, 0, len(points))
+	for _, p := range points {
+		huePoints = append(huePoints, map[string]interface{}{
+			"color": map[string]interface{}{
+				"xy": map[string]float64{"x": p.XY[0], "y": p.XY[1]},
+			},
+		})
+	}
+
+	gradient := map[string]interface{}{"points": huePoints}
+	if mode != "" {
+		gradient["mode"] = mode
+	}
+	return gradient, nil
+}
+
+// GetGroup returns a single room or zone, using the cache when possible
+func (c *Client) GetGroup(id string) (*models.Group, error) {
+	return c.lookupGroup(id)
+}
+
 // GetGroups fetches all rooms and zones from the bridge
 func (c *Client) GetGroups() ([]*models.Group, error) {
 	groups := make([]*models.Group, 0)
@@ -336,17 +469,17 @@ func (c *Client) GetGroups() ([]*models.Group, error) {
 		return nil, err
 	}
 
-	c.mu.Lock()
+	cache := make(map[string]*models.Group)
 	for _, hr := range roomsResult.Data {
 		group := convertHueRoom(hr, deviceToLightID)
 		// Apply state from grouped_light
 		if state, ok := groupedLightStates[group.ID]; ok {
 			group.State = state
 		}
-		c.groups[group.ID] = group
+		cache[group.ID] = group
 		groups = append(groups, group)
 	}
-	c.mu.Unlock()
+	zonesFetched := false
 
 	// Fetch zones
 	zonesResp, err := c.request("GET", "/clip/v2/resource/zone", nil)
@@ -357,7 +490,7 @@ func (c *Client) GetGroups() ([]*models.Group, error) {
 			Data []hueRoom `json:"data"`
 		}
 		if err := json.Unmarshal(zonesResp, &zonesResult); err == nil {
-			c.mu.Lock()
+			zonesFetched = true
 			for _, hz := range zonesResult.Data {
 				group := convertHueRoom(hz, deviceToLightID)
 				group.Type = "zone"
@@ -365,13 +498,25 @@ func (c *Client) GetGroups() ([]*models.Group, error) {
 				if state, ok := groupedLightStates[group.ID]; ok {
 					group.State = state
 				}
-				c.groups[group.ID] = group
+				cache[group.ID] = group
 				groups = append(groups, group)
 			}
-			c.mu.Unlock()
 		}
 	}
 
+	// Replace the cache so deleted rooms and zones are dropped, cached zones
+	// are kept if they could not be fetched
+	c.mu.Lock()
+	if !zonesFetched {
+		for id, group := range c.groups {
+			if group.Type == "zone" {
+				cache[id] = group
+			}
+		}
+	}
+	c.groups = cache
+	c.mu.Unlock()
+
 	log.Debug().Int("count", len(groups)).Msg("Fetched groups from bridge")
 	return groups, nil
 }
@@ -423,6 +568,9 @@ func (c *Client) SetGroupState(id string, cmd models.DeviceCommand) error {
 	if cmd.Brightness != nil {
 		body["dimming"] = map[string]float64{"brightness": *cmd.Brightness}
 	}
+	if cmd.Transition != nil {
+		body["dynamics"] = map[string]int{"duration": *cmd.Transition}
+	}
 
 	log.Debug().Str("grouped_light_id", groupedLightID).Interface("body", body).Msg("Sending PUT request")
 
@@ -451,25 +599,40 @@ func (c *Client) GetScenes() ([]*models.Scene, error) {
 	}
 
 	scenes := make([]*models.Scene, 0, len(result.Data))
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
+	cache := make(map[string]*models.Scene, len(result.Data))
 	for _, hs := range result.Data {
 		scene := convertHueScene(hs)
-		c.scenes[scene.ID] = scene
+		cache[scene.ID] = scene
 		scenes = append(scenes, scene)
 	}
 
+	c.mu.Lock()
+	c.scenes = cache
+	c.mu.Unlock()
+
 	log.Debug().Int("count", len(scenes)).Msg("Fetched scenes from bridge")
 	return scenes, nil
 }
 
 // ActivateScene activates a scene
 func (c *Client) ActivateScene(id string) error {
+	return c.RecallScene(id, nil, 0)
+}
+
+// RecallScene activates a scene with an optional brightness and a
+// transition in milliseconds (0 = bridge default)
+func (c *Client) RecallScene(id string, brightness *float64, transition int) error {
+	recall := map[string]interface{}{
+		"action": "active",
+	}
+	if brightness != nil {
+		recall["dimming"] = map[string]float64{"brightness": *brightness}
+	}
+	if transition > 0 {
+		recall["duration"] = transition
+	}
 	body := map[string]interface{}{
-		"recall": map[string]string{
-			"action": "active",
-		},
+		"recall": recall,
 	}
 
 	_, err := c.request("PUT", fmt.Sprintf("/clip/v2/resource/scene/%s", id), body)
@@ -486,15 +649,23 @@ func (c *Client) Events() <-chan Event {
 	return c.eventChan
 }
 
+// Connects signals every connect of the event stream. Changes made while
+// the stream was down are not reported as events, so state kept from
+// events should be refreshed.
+func (c *Client) Connects() <-chan struct{} {
+	return c.connects
+}
+
 // Close stops the client and closes all connections
 func (c *Client) Close() {
+	c.StopStreaming()
 	close(c.stopChan)
 }
 
 // Internal HUE API response types
 type hueLight struct {
-	ID       string `json:"id"`
-	Owner    *struct {
+	ID    string `json:"id"`
+	Owner *struct {
 		RID   string `json:"rid"`
 		RType string `json:"rtype"`
 	} `json:"owner,omitempty"`
@@ -513,8 +684,8 @@ type hueLight struct {
 		Brightness float64 `json:"brightness"`
 	} `json:"dimming,omitempty"`
 	ColorTemperature *struct {
-		Mirek       int  `json:"mirek"`
-		MirekValid  bool `json:"mirek_valid"`
+		Mirek      int  `json:"mirek"`
+		MirekValid bool `json:"mirek_valid"`
 	} `json:"color_temperature,omitempty"`
 	Color *struct {
 		XY struct {
@@ -528,6 +699,22 @@ type hueLight struct {
 		} `json:"gamut"`
 		GamutType string `json:"gamut_type"`
 	} `json:"color,omitempty"`
+	Gradient *hueGradient `json:"gradient,omitempty"`
+	PowerUp  *huePowerUp  `json:"powerup,omitempty"`
+}
+
+type hueGradient struct {
+	Points []struct {
+		Color struct {
+			XY struct {
+				X float64 `json:"x"`
+				Y float64 `json:"y"`
+			} `json:"xy"`
+		} `json:"color"`
+	} `json:"points"`
+	PointsCapable int      `json:"points_capable"`
+	Mode          string   `json:"mode"`
+	ModeValues    []string `json:"mode_values"`
 }
 
 type hueRoom struct {
@@ -589,23 +776,52 @@ func convertHueLight(hl hueLight) *models.Light {
 		light.Capabilities.SupportsColor = true
 	}
 
+	if hl.Gradient != nil && hl.Gradient.PointsCapable > 0 {
+		light.State.Gradient = convertHueGradient(hl.Gradient)
+		light.Capabilities.SupportsGradient = true
+		light.Capabilities.GradientPoints = hl.Gradient.PointsCapable
+		light.Capabilities.GradientModes = hl.Gradient.ModeValues
+	}
+
+	if hl.PowerUp != nil {
+		light.PowerUp = convertHuePowerUp(hl.PowerUp)
+	}
+
 	return light
 }
 
+func convertHueGradient(hg *hueGradient) *models.Gradient {
+	gradient := &models.Gradient{
+		Points: make([]models.Color, 0, len(hg.Points)),
+		Mode:   hg.Mode,
+	}
+	for _, p := range hg.Points {
+		gradient.Points = append(gradient.Points, models.Color{
+			XY: [2]float64{p.Color.XY.X, p.Color.XY.Y},
+		})
+	}
+	return gradient
+}
+
 func convertHueRoom(hr hueRoom, deviceToLightID map[string]string) *models.Group {
 	group := &models.Group{
-		ID:     hr.ID,
-		Name:   hr.Metadata.Name,
-		Type:   "room",
-		Lights: make([]string, 0),
+		ID:        hr.ID,
+		Name:      hr.Metadata.Name,
+		Type:      "room",
+		Archetype: hr.Metadata.Archetype,
+		Lights:    make([]string, 0),
 	}
 
 	for _, child := range hr.Children {
-		if child.RType == "device" {
+		switch child.RType {
+		case "device":
 			// Map device ID to light ID
 			if lightID, ok := deviceToLightID[child.RID]; ok {
 				group.Lights = append(group.Lights, lightID)
 			}
+		case "light":
+			// Zones reference lights directly
+			group.Lights = append(group.Lights, child.RID)
 		}
 	}
 
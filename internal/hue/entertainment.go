@@ -0,0 +1,618 @@
+package hue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+const (
+	// entertainmentPort is the UDP port of the bridge's DTLS stream endpoint
+	entertainmentPort = 2100
+	// frameInterval is the send rate recommended by the HueStream documentation
+	frameInterval = 20 * time.Millisecond
+	// maxStreamChannels is the maximum number of channels per HueStream frame
+	maxStreamChannels = 20
+)
+
+// StreamDialer opens a DTLS-PSK connection to an entertainment endpoint.
+// It is replaceable so the streaming can run against a local stand-in.
+type StreamDialer func(ctx context.Context, addr, identity string, psk []byte) (net.Conn, error)
+
+// streamSession is a running entertainment stream
+type streamSession struct {
+	configID  string
+	options   models.StreamOptions
+	channels  []int
+	startedAt time.Time
+	frames    uint64
+	mu        sync.Mutex // guards options and frames
+
+	conn   net.Conn
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// dialDTLS connects to the bridge using DTLS 1.2 with PSK as required by HueStream
+func dialDTLS(ctx context.Context, addr, identity string, psk []byte) (net.Conn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &dtls.Config{
+		PSK: func(hint []byte) ([]byte, error) {
+			return psk, nil
+		},
+		PSKIdentityHint: []byte(identity),
+		CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	}
+
+	return dtls.DialWithContext(ctx, "udp", udpAddr, config)
+}
+
+// SetStreamDialer replaces the DTLS dialer used for entertainment streaming
+func (c *Client) SetStreamDialer(dialer StreamDialer) {
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+	c.dialStream = dialer
+}
+
+// GetEntertainmentConfigurations fetches all entertainment areas from the bridge
+func (c *Client) GetEntertainmentConfigurations() ([]*models.EntertainmentConfiguration, error) {
+	resp, err := c.request("GET", "/clip/v2/resource/entertainment_configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []hueEntertainmentConfiguration `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+
+	configs := make([]*models.EntertainmentConfiguration, 0, len(result.Data))
+	for _, hc := range result.Data {
+		configs = append(configs, convertHueEntertainmentConfiguration(hc))
+	}
+
+	return configs, nil
+}
+
+// GetEntertainmentConfiguration fetches a single entertainment area by ID
+func (c *Client) GetEntertainmentConfiguration(id string) (*models.EntertainmentConfiguration, error) {
+	resp, err := c.request("GET", fmt.Sprintf("/clip/v2/resource/entertainment_configuration/%s", id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []hueEntertainmentConfiguration `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("entertainment configuration not found: %s", id)
+	}
+
+	return convertHueEntertainmentConfiguration(result.Data[0]), nil
+}
+
+// CreateEntertainmentConfiguration creates an entertainment area containing the
+// given lights, spread evenly from left to right
+func (c *Client) CreateEntertainmentConfiguration(name, configType string, lightIDs []string) (string, error) {
+	if len(lightIDs) == 0 {
+		return "", fmt.Errorf("at least one light is required")
+	}
+	if configType == "" {
+		configType = "music"
+	}
+
+	serviceByLight, err := c.entertainmentServicesByLight()
+	if err != nil {
+		return "", err
+	}
+
+	locations := make([]map[string]interface{}, 0, len(lightIDs))
+	for i, lightID := range lightIDs {
+		serviceID, ok := serviceByLight[lightID]
+		if !ok {
+			return "", fmt.Errorf("light %s does not support entertainment streaming", lightID)
+		}
+
+		x := 0.0
+		if len(lightIDs) > 1 {
+			x = -1 + 2*float64(i)/float64(len(lightIDs)-1)
+		}
+
+		locations = append(locations, map[string]interface{}{
+			"service": map[string]string{"rid": serviceID, "rtype": "entertainment"},
+			"positions": []map[string]float64{
+				{"x": x, "y": 0, "z": 0},
+			},
+		})
+	}
+
+	body := map[string]interface{}{
+		"type":               "entertainment_configuration",
+		"metadata":           map[string]string{"name": name},
+		"configuration_type": configType,
+		"locations": map[string]interface{}{
+			"service_locations": locations,
+		},
+	}
+
+	resp, err := c.request("POST", "/clip/v2/resource/entertainment_configuration", body)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := createdResourceID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info().Str("id", id).Str("name", name).Int("lights", len(lightIDs)).Msg("Entertainment configuration created")
+	return id, nil
+}
+
+// entertainmentServicesByLight maps light IDs to the entertainment service of their device
+func (c *Client) entertainmentServicesByLight() (map[string]string, error) {
+	lightsResp, err := c.request("GET", "/clip/v2/resource/light", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var lightsResult struct {
+		Data []hueLight `json:"data"`
+	}
+	if err := json.Unmarshal(lightsResp, &lightsResult); err != nil {
+		return nil, err
+	}
+
+	entResp, err := c.request("GET", "/clip/v2/resource/entertainment", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entResult struct {
+		Data []struct {
+			ID       string `json:"id"`
+			Renderer bool   `json:"renderer"`
+			Owner    struct {
+				RID string `json:"rid"`
+			} `json:"owner"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(entResp, &entResult); err != nil {
+		return nil, err
+	}
+
+	serviceByDevice := make(map[string]string)
+	for _, e := range entResult.Data {
+		if e.Renderer {
+			serviceByDevice[e.Owner.RID] = e.ID
+		}
+	}
+
+	serviceByLight := make(map[string]string)
+	for _, hl := range lightsResult.Data {
+		if hl.Owner == nil {
+			continue
+		}
+		if serviceID, ok := serviceByDevice[hl.Owner.RID]; ok {
+			serviceByLight[hl.ID] = serviceID
+		}
+	}
+
+	return serviceByLight, nil
+}
+
+// StartStreaming starts an entertainment stream on the given configuration.
+// If a stream is already running on the same configuration only the pattern
+// is replaced, otherwise the running stream is stopped first.
+func (c *Client) StartStreaming(configID string, opts models.StreamOptions) error {
+	bridgeIP, _, applicationKey := c.connection()
+	clientKey := c.ClientKey()
+	if bridgeIP == "" || applicationKey == "" {
+		return fmt.Errorf("client not configured")
+	}
+	if clientKey == "" {
+		return fmt.Errorf("no client key available - please pair the bridge again to enable entertainment streaming")
+	}
+	if _, ok := streamPatterns[opts.Pattern]; !ok {
+		return fmt.Errorf("unknown pattern: %s", opts.Pattern)
+	}
+	if len(configID) != 36 {
+		return fmt.Errorf("invalid entertainment configuration ID: %s", configID)
+	}
+
+	psk, err := hex.DecodeString(clientKey)
+	if err != nil {
+		return fmt.Errorf("invalid client key: %v", err)
+	}
+
+	// streamOpMu serializes starting and stopping, streamMu is only held
+	// briefly so StreamingStatus never waits for the bridge
+	c.streamOpMu.Lock()
+	defer c.streamOpMu.Unlock()
+
+	c.streamMu.Lock()
+	current, dial := c.stream, c.dialStream
+	if current != nil && current.configID == configID {
+		current.mu.Lock()
+		current.options = opts
+		current.mu.Unlock()
+		c.streamMu.Unlock()
+		log.Info().Str("config_id", configID).Str("pattern", opts.Pattern).Msg("Entertainment pattern changed")
+		return nil
+	}
+	c.stream = nil
+	c.streamMu.Unlock()
+
+	if current != nil {
+		c.finishStream(current)
+	}
+
+	ec, err := c.GetEntertainmentConfiguration(configID)
+	if err != nil {
+		return err
+	}
+	if len(ec.Channels) == 0 {
+		return fmt.Errorf("entertainment configuration has no channels: %s", configID)
+	}
+
+	channels := make([]int, 0, len(ec.Channels))
+	for _, ch := range ec.Channels {
+		if len(channels) == maxStreamChannels {
+			break
+		}
+		channels = append(channels, ch.ID)
+	}
+
+	action := map[string]string{"action": "start"}
+	if _, err := c.request("PUT", fmt.Sprintf("/clip/v2/resource/entertainment_configuration/%s", configID), action); err != nil {
+		return fmt.Errorf("failed to start entertainment configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, 5*time.Second)
+	conn, err := dial(dialCtx, fmt.Sprintf("%s:%d", bridgeIP, entertainmentPort), applicationKey, psk)
+	dialCancel()
+	if err != nil {
+		cancel()
+		c.request("PUT", fmt.Sprintf("/clip/v2/resource/entertainment_configuration/%s", configID), map[string]string{"action": "stop"})
+		return fmt.Errorf("failed to open entertainment stream: %v", err)
+	}
+
+	session := &streamSession{
+		configID:  configID,
+		options:   opts,
+		channels:  channels,
+		startedAt: time.Now(),
+		conn:      conn,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	c.streamMu.Lock()
+	c.stream = session
+	c.streamMu.Unlock()
+
+	go c.streamLoop(ctx, session)
+
+	log.Info().
+		Str("config_id", configID).
+		Str("pattern", opts.Pattern).
+		Int("channels", len(channels)).
+		Msg("Entertainment stream started")
+	return nil
+}
+
+// StopStreaming stops the running entertainment stream, if any
+func (c *Client) StopStreaming() {
+	c.streamOpMu.Lock()
+	defer c.streamOpMu.Unlock()
+
+	c.streamMu.Lock()
+	session := c.stream
+	c.stream = nil
+	c.streamMu.Unlock()
+
+	if session != nil {
+		c.finishStream(session)
+	}
+}
+
+// finishStream ends a session that is no longer the current stream and
+// releases the entertainment configuration on the bridge
+func (c *Client) finishStream(session *streamSession) {
+	session.cancel()
+	<-session.done
+
+	action := map[string]string{"action": "stop"}
+	if _, err := c.request("PUT", fmt.Sprintf("/clip/v2/resource/entertainment_configuration/%s", session.configID), action); err != nil {
+		log.Warn().Err(err).Str("config_id", session.configID).Msg("Failed to stop entertainment configuration")
+	}
+
+	session.mu.Lock()
+	frames := session.frames
+	session.mu.Unlock()
+	log.Info().Str("config_id", session.configID).Uint64("frames", frames).Msg("Entertainment stream stopped")
+}
+
+// StreamingStatus returns the state of the entertainment stream
+func (c *Client) StreamingStatus() models.StreamStatus {
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+
+	if c.stream == nil {
+		return models.StreamStatus{Active: false}
+	}
+
+	c.stream.mu.Lock()
+	defer c.stream.mu.Unlock()
+
+	startedAt := c.stream.startedAt
+	return models.StreamStatus{
+		Active:    true,
+		ConfigID:  c.stream.configID,
+		Options:   c.stream.options,
+		StartedAt: &startedAt,
+		Frames:    c.stream.frames,
+	}
+}
+
+// streamLoop renders and sends frames until the session is cancelled
+func (c *Client) streamLoop(ctx context.Context, session *streamSession) {
+	defer close(session.done)
+	defer session.conn.Close()
+
+	ticker := time.NewTicker(frameInterval)
+	defer ticker.Stop()
+
+	var seq uint8
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			session.mu.Lock()
+			opts := session.options
+			session.mu.Unlock()
+
+			elapsed := time.Since(session.startedAt)
+			frame := buildStreamFrame(session.configID, seq, session.channels, func(i int) [3]float64 {
+				return renderPattern(opts, elapsed, i, len(session.channels))
+			})
+
+			if _, err := session.conn.Write(frame); err != nil {
+				log.Error().Err(err).Str("config_id", session.configID).Msg("Entertainment stream write failed")
+				go c.dropStream(session)
+				return
+			}
+			seq++
+
+			session.mu.Lock()
+			session.frames++
+			session.mu.Unlock()
+		}
+	}
+}
+
+// dropStream cleans up a session whose connection failed
+func (c *Client) dropStream(session *streamSession) {
+	c.streamOpMu.Lock()
+	defer c.streamOpMu.Unlock()
+
+	c.streamMu.Lock()
+	current := c.stream == session
+	if current {
+		c.stream = nil
+	}
+	c.streamMu.Unlock()
+
+	if current {
+		c.finishStream(session)
+	}
+}
+
+// buildStreamFrame encodes a HueStream v2 frame in RGB color space
+func buildStreamFrame(configID string, seq uint8, channels []int, color func(i int) [3]float64) []byte {
+	frame := make([]byte, 0, 52+7*len(channels))
+	frame = append(frame, []byte("HueStream")...)
+	frame = append(frame,
+		0x02, 0x00, // API version 2.0
+		seq,        // Sequence number
+		0x00, 0x00, // Reserved
+		0x00, // Color space: RGB
+		0x00, // Reserved
+	)
+	frame = append(frame, []byte(configID)...)
+
+	for i, ch := range channels {
+		rgb := color(i)
+		frame = append(frame, byte(ch))
+		for _, v := range rgb {
+			frame = binary.BigEndian.AppendUint16(frame, uint16(clamp01(v)*math.MaxUint16))
+		}
+	}
+
+	return frame
+}
+
+// streamPatterns lists the built-in patterns and their default speed
+var streamPatterns = map[string]float64{
+	"static":  0,
+	"rainbow": 0.2,
+	"pulse":   0.5,
+	"strobe":  4,
+	"party":   2,
+}
+
+// renderPattern computes the RGB color (0-1) of a channel at the given time
+func renderPattern(opts models.StreamOptions, elapsed time.Duration, channel, total int) [3]float64 {
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = streamPatterns[opts.Pattern]
+	}
+	brightness := opts.Brightness
+	if brightness <= 0 {
+		brightness = 100
+	}
+	scale := brightness / 100
+
+	base, ok := parseHexRGB(opts.Color)
+	if !ok {
+		base = [3]float64{1, 1, 1}
+	}
+
+	t := elapsed.Seconds()
+	var rgb [3]float64
+
+	switch opts.Pattern {
+	case "rainbow":
+		offset := float64(channel) / float64(total)
+		rgb = hsvToRGB(math.Mod(t*speed+offset, 1), 1, 1)
+	case "pulse":
+		level := 0.5 + 0.5*math.Sin(2*math.Pi*speed*t)
+		rgb = [3]float64{base[0] * level, base[1] * level, base[2] * level}
+	case "strobe":
+		if math.Mod(t*speed, 1) < 0.5 {
+			rgb = base
+		}
+	case "party":
+		// A new random hue per channel on every step
+		step := int64(t * speed)
+		r := rand.New(rand.NewSource(step*31 + int64(channel)))
+		rgb = hsvToRGB(r.Float64(), 1, 1)
+	default:
+		rgb = base
+	}
+
+	return [3]float64{rgb[0] * scale, rgb[1] * scale, rgb[2] * scale}
+}
+
+// parseHexRGB parses a "#RRGGBB" color into RGB components in the range 0-1
+func parseHexRGB(s string) ([3]float64, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return [3]float64{}, false
+	}
+
+	var rgb [3]float64
+	for i := 0; i < 3; i++ {
+		v, err := strconv.ParseUint(s[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return [3]float64{}, false
+		}
+		rgb[i] = float64(v) / 255
+	}
+	return rgb, true
+}
+
+// hsvToRGB converts a hue/saturation/value color (all 0-1) to RGB
+func hsvToRGB(h, s, v float64) [3]float64 {
+	i := math.Floor(h * 6)
+	f := h*6 - i
+	p := v * (1 - s)
+	q := v * (1 - f*s)
+	t := v * (1 - (1-f)*s)
+
+	switch int(i) % 6 {
+	case 0:
+		return [3]float64{v, t, p}
+	case 1:
+		return [3]float64{q, v, p}
+	case 2:
+		return [3]float64{p, v, t}
+	case 3:
+		return [3]float64{p, q, v}
+	case 4:
+		return [3]float64{t, p, v}
+	default:
+		return [3]float64{v, p, q}
+	}
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// createdResourceID extracts the ID from a CLIP v2 POST response
+func createdResourceID(resp []byte) (string, error) {
+	var result struct {
+		Data []struct {
+			RID string `json:"rid"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return "", err
+	}
+	if len(result.Data) == 0 {
+		return "", fmt.Errorf("unexpected response: %s", string(resp))
+	}
+	return result.Data[0].RID, nil
+}
+
+type hueEntertainmentConfiguration struct {
+	ID       string `json:"id"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	ConfigurationType string `json:"configuration_type"`
+	Status            string `json:"status"`
+	Channels          []struct {
+		ChannelID int `json:"channel_id"`
+		Position  struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+			Z float64 `json:"z"`
+		} `json:"position"`
+	} `json:"channels"`
+	LightServices []struct {
+		RID   string `json:"rid"`
+		RType string `json:"rtype"`
+	} `json:"light_services"`
+}
+
+func convertHueEntertainmentConfiguration(hc hueEntertainmentConfiguration) *models.EntertainmentConfiguration {
+	ec := &models.EntertainmentConfiguration{
+		ID:       hc.ID,
+		Name:     hc.Metadata.Name,
+		Type:     hc.ConfigurationType,
+		Status:   hc.Status,
+		Channels: make([]models.EntertainmentChannel, 0, len(hc.Channels)),
+		Lights:   make([]string, 0, len(hc.LightServices)),
+	}
+
+	for _, ch := range hc.Channels {
+		ec.Channels = append(ec.Channels, models.EntertainmentChannel{
+			ID:       ch.ChannelID,
+			Position: [3]float64{ch.Position.X, ch.Position.Y, ch.Position.Z},
+		})
+	}
+	for _, ls := range hc.LightServices {
+		if ls.RType == "light" {
+			ec.Lights = append(ec.Lights, ls.RID)
+		}
+	}
+
+	return ec
+}
@@ -0,0 +1,211 @@
+package hue
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+const (
+	testConfigID  = "1a8d99cc-967b-44f2-9202-43f976c0fa6b"
+	testAppKey    = "test-application-key"
+	testClientKey = "0123456789abcdef0123456789abcdef"
+)
+
+// standInBridge serves the CLIP endpoints used by streaming and accepts the
+// DTLS-PSK entertainment connection like a bridge does
+type standInBridge struct {
+	api      *httptest.Server
+	listener net.Listener
+
+	mu      sync.Mutex
+	actions []string
+
+	frames chan []byte
+}
+
+func newStandInBridge(t *testing.T) *standInBridge {
+	t.Helper()
+	b := &standInBridge{frames: make(chan []byte, 100)}
+
+	b.api = httptest.NewTLSServer(http.HandlerFunc(b.serveAPI))
+	t.Cleanup(b.api.Close)
+
+	psk, _ := hex.DecodeString(testClientKey)
+	listener, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &dtls.Config{
+		PSK: func(hint []byte) ([]byte, error) {
+			if string(hint) != testAppKey {
+				t.Errorf("PSK identity = %q, want the application key", hint)
+			}
+			return psk, nil
+		},
+		CipherSuites: []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		t.Fatalf("dtls listen: %v", err)
+	}
+	b.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go b.acceptStreams()
+	return b
+}
+
+func (b *standInBridge) serveAPI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/clip/v2/resource/entertainment_configuration/"+testConfigID {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Write([]byte(`{"errors":[],"data":[{"id":"` + testConfigID + `","metadata":{"name":"TV"},` +
+			`"configuration_type":"screen","status":"inactive",` +
+			`"channels":[{"channel_id":0},{"channel_id":1},{"channel_id":2}]}]}`))
+	case http.MethodPut:
+		var body struct {
+			Action string `json:"action"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		b.mu.Lock()
+		b.actions = append(b.actions, body.Action)
+		b.mu.Unlock()
+		w.Write([]byte(`{"errors":[],"data":[{"rid":"` + testConfigID + `","rtype":"entertainment_configuration"}]}`))
+	}
+}
+
+func (b *standInBridge) acceptStreams() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			buf := make([]byte, 1024)
+			for {
+				n, err := conn.Read(buf)
+				if err != nil {
+					return
+				}
+				select {
+				case b.frames <- append([]byte(nil), buf[:n]...):
+				default:
+				}
+			}
+		}()
+	}
+}
+
+func (b *standInBridge) recordedActions() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.actions...)
+}
+
+// client returns a paired client whose entertainment stream is sent to the
+// stand-in instead of UDP port 2100 of the bridge
+func (b *standInBridge) client() *Client {
+	c := NewClient(strings.TrimPrefix(b.api.URL, "https://"), testAppKey)
+	c.SetClientKey(testClientKey)
+	c.SetStreamDialer(func(ctx context.Context, addr, identity string, psk []byte) (net.Conn, error) {
+		udpAddr := b.listener.Addr().(*net.UDPAddr)
+		return dtls.DialWithContext(ctx, "udp", udpAddr, &dtls.Config{
+			PSK: func(hint []byte) ([]byte, error) {
+				return psk, nil
+			},
+			PSKIdentityHint: []byte(identity),
+			CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+		})
+	})
+	return c
+}
+
+func TestStreamingAgainstStandIn(t *testing.T) {
+	bridge := newStandInBridge(t)
+	client := bridge.client()
+
+	if err := client.StartStreaming(testConfigID, models.StreamOptions{Pattern: "static", Color: "#ff0000"}); err != nil {
+		t.Fatalf("StartStreaming: %v", err)
+	}
+
+	var frame []byte
+	select {
+	case frame = <-bridge.frames:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+	}
+
+	if !bytes.HasPrefix(frame, []byte("HueStream\x02\x00")) {
+		t.Fatalf("frame header = %q", frame[:11])
+	}
+	if got := string(frame[16:52]); got != testConfigID {
+		t.Fatalf("frame config ID = %q", got)
+	}
+	if want := 52 + 3*7; len(frame) != want {
+		t.Fatalf("frame length = %d, want %d", len(frame), want)
+	}
+	// Channel 0 in full red
+	if !bytes.Equal(frame[52:59], []byte{0, 0xff, 0xff, 0, 0, 0, 0}) {
+		t.Fatalf("channel 0 = %x", frame[52:59])
+	}
+
+	status := client.StreamingStatus()
+	if !status.Active || status.ConfigID != testConfigID {
+		t.Fatalf("status = %+v", status)
+	}
+
+	client.StopStreaming()
+	if client.StreamingStatus().Active {
+		t.Fatal("stream still active after stop")
+	}
+	if actions := bridge.recordedActions(); strings.Join(actions, ",") != "start,stop" {
+		t.Fatalf("actions = %v", actions)
+	}
+}
+
+func TestStreamingStatusDuringDial(t *testing.T) {
+	bridge := newStandInBridge(t)
+	client := bridge.client()
+
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	client.SetStreamDialer(func(ctx context.Context, addr, identity string, psk []byte) (net.Conn, error) {
+		close(dialing)
+		<-release
+		return nil, context.Canceled
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.StartStreaming(testConfigID, models.StreamOptions{Pattern: "static"})
+	}()
+	<-dialing
+
+	status := make(chan models.StreamStatus, 1)
+	go func() { status <- client.StreamingStatus() }()
+	select {
+	case s := <-status:
+		if s.Active {
+			t.Fatal("stream reported active while dialing")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StreamingStatus blocked while dialing")
+	}
+
+	close(release)
+	if err := <-done; err == nil {
+		t.Fatal("StartStreaming succeeded with a failing dialer")
+	}
+}
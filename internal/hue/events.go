@@ -11,19 +11,70 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/metrics"
 	"github.com/sbeyeler/loxone2hue/internal/models"
 )
 
+// EventStreamStatus describes the SSE event stream of a bridge
+type EventStreamStatus struct {
+	Started   bool      `json:"started"`
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"` // Last connect or disconnect
+	LastEvent time.Time `json:"last_event,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
 // StartEventStream connects to the HUE Bridge SSE event stream
 func (c *Client) StartEventStream(ctx context.Context) error {
 	if !c.IsConfigured() {
 		return fmt.Errorf("client not configured")
 	}
 
+	c.streamStatusMu.Lock()
+	c.streamStatus.Started = true
+	c.streamStatus.Since = time.Now()
+	c.streamStatusMu.Unlock()
+
 	go c.eventStreamLoop(ctx)
 	return nil
 }
 
+// EventStreamStatus returns the state of the event stream
+func (c *Client) EventStreamStatus() EventStreamStatus {
+	c.streamStatusMu.RLock()
+	defer c.streamStatusMu.RUnlock()
+	return c.streamStatus
+}
+
+// ReconnectEventStream drops the current event stream connection so the
+// stream reconnects, e.g. after the bridge address or key changed
+func (c *Client) ReconnectEventStream() {
+	c.streamStatusMu.RLock()
+	cancel := c.streamCancel
+	c.streamStatusMu.RUnlock()
+
+	if cancel != nil {
+		log.Info().Str("bridge", c.BridgeIP()).Msg("Reconnecting HUE event stream")
+		cancel()
+	}
+}
+
+// setStreamConnected records a connect or disconnect of the event stream
+func (c *Client) setStreamConnected(connected bool, err error) {
+	c.streamStatusMu.Lock()
+	defer c.streamStatusMu.Unlock()
+
+	if c.streamStatus.Connected != connected {
+		c.streamStatus.Connected = connected
+		c.streamStatus.Since = time.Now()
+	}
+	if err != nil {
+		c.streamStatus.LastError = err.Error()
+	} else if connected {
+		c.streamStatus.LastError = ""
+	}
+}
+
 func (c *Client) eventStreamLoop(ctx context.Context) {
 	for {
 		select {
@@ -32,7 +83,9 @@ func (c *Client) eventStreamLoop(ctx context.Context) {
 		case <-c.stopChan:
 			return
 		default:
-			if err := c.connectEventStream(ctx); err != nil {
+			err := c.connectEventStream(ctx)
+			c.setStreamConnected(false, err)
+			if err != nil {
 				log.Error().Err(err).Msg("Event stream error, reconnecting...")
 				time.Sleep(5 * time.Second)
 			}
@@ -40,8 +93,23 @@ func (c *Client) eventStreamLoop(ctx context.Context) {
 	}
 }
 
-func (c *Client) connectEventStream(ctx context.Context) error {
-	url := fmt.Sprintf("%s/eventstream/clip/v2", c.baseURL)
+func (c *Client) connectEventStream(parent context.Context) (err error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	c.streamStatusMu.Lock()
+	c.streamCancel = cancel
+	c.streamStatusMu.Unlock()
+
+	// A connection ended by ReconnectEventStream is no error
+	defer func() {
+		if parent.Err() == nil && ctx.Err() != nil {
+			err = nil
+		}
+	}()
+
+	bridgeIP, baseURL, applicationKey := c.connection()
+	url := fmt.Sprintf("%s/eventstream/clip/v2", baseURL)
 
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -54,7 +122,7 @@ func (c *Client) connectEventStream(ctx context.Context) error {
 	}
 
 	req.Header.Set("Accept", "text/event-stream")
-	req.Header.Set("hue-application-key", c.applicationKey)
+	req.Header.Set("hue-application-key", applicationKey)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -66,7 +134,15 @@ func (c *Client) connectEventStream(ctx context.Context) error {
 		return fmt.Errorf("unexpected status: %s", resp.Status)
 	}
 
-	log.Info().Str("bridge", c.bridgeIP).Msg("Connected to HUE event stream")
+	log.Info().Str("bridge", bridgeIP).Msg("Connected to HUE event stream")
+	metrics.HueEventStreamConnected.Set(1, bridgeIP)
+	c.setStreamConnected(true, nil)
+	defer metrics.HueEventStreamConnected.Set(0, bridgeIP)
+
+	select {
+	case c.connects <- struct{}{}:
+	default:
+	}
 
 	scanner := bufio.NewScanner(resp.Body)
 	var eventData strings.Builder
@@ -94,34 +170,38 @@ func (c *Client) connectEventStream(ctx context.Context) error {
 	return scanner.Err()
 }
 
+// eventData is a single resource update contained in an SSE event
+type eventData struct {
+	ID    string `json:"id"`
+	IDV1  string `json:"id_v1"`
+	Type  string `json:"type"`
+	Owner *struct {
+		RID   string `json:"rid"`
+		RType string `json:"rtype"`
+	} `json:"owner,omitempty"`
+	On *struct {
+		On bool `json:"on"`
+	} `json:"on,omitempty"`
+	Dimming *struct {
+		Brightness float64 `json:"brightness"`
+	} `json:"dimming,omitempty"`
+	ColorTemperature *struct {
+		Mirek int `json:"mirek"`
+	} `json:"color_temperature,omitempty"`
+	Color *struct {
+		XY struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+		} `json:"xy"`
+	} `json:"color,omitempty"`
+	Gradient *hueGradient `json:"gradient,omitempty"`
+}
+
 func (c *Client) processEvent(data string) {
 	var events []struct {
-		CreationTime time.Time `json:"creationtime"`
-		Data         []struct {
-			ID    string                 `json:"id"`
-			IDV1  string                 `json:"id_v1"`
-			Type  string                 `json:"type"`
-			Owner *struct {
-				RID   string `json:"rid"`
-				RType string `json:"rtype"`
-			} `json:"owner,omitempty"`
-			On *struct {
-				On bool `json:"on"`
-			} `json:"on,omitempty"`
-			Dimming *struct {
-				Brightness float64 `json:"brightness"`
-			} `json:"dimming,omitempty"`
-			ColorTemperature *struct {
-				Mirek int `json:"mirek"`
-			} `json:"color_temperature,omitempty"`
-			Color *struct {
-				XY struct {
-					X float64 `json:"x"`
-					Y float64 `json:"y"`
-				} `json:"xy"`
-			} `json:"color,omitempty"`
-		} `json:"data"`
-		Type string `json:"type"`
+		CreationTime time.Time   `json:"creationtime"`
+		Data         []eventData `json:"data"`
+		Type         string      `json:"type"`
 	}
 
 	if err := json.Unmarshal([]byte(data), &events); err != nil {
@@ -129,10 +209,21 @@ func (c *Client) processEvent(data string) {
 		return
 	}
 
+	c.streamStatusMu.Lock()
+	c.streamStatus.LastEvent = time.Now()
+	c.streamStatusMu.Unlock()
+
+	bridgeIP := c.BridgeIP()
+
 	for _, event := range events {
 		for _, item := range event.Data {
 			// Update internal state
-			c.updateFromEvent(item.ID, item.Type, item)
+			if event.Type == "delete" {
+				c.forgetResource(item.Type, item.ID)
+			} else {
+				c.updateFromEvent(item)
+			}
+			metrics.HueEvents.Inc(bridgeIP, item.Type)
 
 			// Send event to channel
 			select {
@@ -145,50 +236,36 @@ func (c *Client) processEvent(data string) {
 			}:
 			default:
 				// Channel full, skip
+				metrics.HueEventsDropped.Inc(bridgeIP)
 			}
 		}
 	}
 }
 
-func (c *Client) updateFromEvent(id, resourceType string, data interface{}) {
-	if resourceType != "light" {
-		return
-	}
-
+// forgetResource removes a resource deleted on the bridge from the cache
+func (c *Client) forgetResource(resourceType, id string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	light, ok := c.lights[id]
-	if !ok {
+	switch resourceType {
+	case "light":
+		delete(c.lights, id)
+	case "room", "zone":
+		delete(c.groups, id)
+	case "scene":
+		delete(c.scenes, id)
+	}
+}
+
+func (c *Client) updateFromEvent(eventData eventData) {
+	if eventData.Type != "light" {
 		return
 	}
 
-	// Type assertion to access fields
-	eventData, ok := data.(struct {
-		ID    string                 `json:"id"`
-		IDV1  string                 `json:"id_v1"`
-		Type  string                 `json:"type"`
-		Owner *struct {
-			RID   string `json:"rid"`
-			RType string `json:"rtype"`
-		} `json:"owner,omitempty"`
-		On *struct {
-			On bool `json:"on"`
-		} `json:"on,omitempty"`
-		Dimming *struct {
-			Brightness float64 `json:"brightness"`
-		} `json:"dimming,omitempty"`
-		ColorTemperature *struct {
-			Mirek int `json:"mirek"`
-		} `json:"color_temperature,omitempty"`
-		Color *struct {
-			XY struct {
-				X float64 `json:"x"`
-				Y float64 `json:"y"`
-			} `json:"xy"`
-		} `json:"color,omitempty"`
-	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	light, ok := c.lights[eventData.ID]
 	if !ok {
 		return
 	}
@@ -208,6 +285,13 @@ func (c *Client) updateFromEvent(id, resourceType string, data interface{}) {
 		}
 		light.State.Color.XY = [2]float64{eventData.Color.XY.X, eventData.Color.XY.Y}
 	}
+	if eventData.Gradient != nil {
+		gradient := convertHueGradient(eventData.Gradient)
+		if gradient.Mode == "" && light.State.Gradient != nil {
+			gradient.Mode = light.State.Gradient.Mode
+		}
+		light.State.Gradient = gradient
+	}
 
-	log.Debug().Str("id", id).Msg("Light state updated from event")
+	log.Debug().Str("id", eventData.ID).Msg("Light state updated from event")
 }
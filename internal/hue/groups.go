@@ -0,0 +1,295 @@
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// lookupGroup returns a room or zone from the cache, refreshing it once if unknown
+func (c *Client) lookupGroup(id string) (*models.Group, error) {
+	c.mu.RLock()
+	group, ok := c.groups[id]
+	c.mu.RUnlock()
+	if ok {
+		return group, nil
+	}
+
+	if _, err := c.GetGroups(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	group, ok = c.groups[id]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("group not found: %s", id)
+	}
+	return group, nil
+}
+
+// groupChild is a child reference of a room or zone
+type groupChild struct {
+	RID   string `json:"rid"`
+	RType string `json:"rtype"`
+}
+
+// groupChildren fetches the current children of a room or zone from the bridge
+func (c *Client) groupChildren(groupType, id string) ([]groupChild, error) {
+	resp, err := c.request("GET", fmt.Sprintf("/clip/v2/resource/%s/%s", groupType, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			Children []groupChild `json:"children"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("group not found: %s", id)
+	}
+	return result.Data[0].Children, nil
+}
+
+// setGroupChildren replaces the children of a room or zone
+func (c *Client) setGroupChildren(groupType, id string, children []groupChild) error {
+	body := map[string]interface{}{
+		"children": children,
+	}
+
+	_, err := c.request("PUT", fmt.Sprintf("/clip/v2/resource/%s/%s", groupType, id), body)
+	return err
+}
+
+// lightOwner returns the device that owns a light. Rooms contain devices,
+// zones contain lights.
+func (c *Client) lightOwner(lightID string) (string, error) {
+	resp, err := c.request("GET", fmt.Sprintf("/clip/v2/resource/light/%s", lightID), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Data []hueLight `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return "", err
+	}
+
+	if len(result.Data) == 0 || result.Data[0].Owner == nil {
+		return "", fmt.Errorf("light not found: %s", lightID)
+	}
+	return result.Data[0].Owner.RID, nil
+}
+
+// childForGroup returns the child reference that represents a light in a group
+func (c *Client) childForGroup(groupType, lightID string) (groupChild, error) {
+	if groupType == "zone" {
+		return groupChild{RID: lightID, RType: "light"}, nil
+	}
+
+	deviceID, err := c.lightOwner(lightID)
+	if err != nil {
+		return groupChild{}, err
+	}
+	return groupChild{RID: deviceID, RType: "device"}, nil
+}
+
+// CreateZone creates a new zone containing the given lights
+func (c *Client) CreateZone(name, archetype string, lightIDs []string) (*models.Group, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if archetype == "" {
+		archetype = "other"
+	}
+
+	children := make([]groupChild, 0, len(lightIDs))
+	for _, lightID := range lightIDs {
+		children = append(children, groupChild{RID: lightID, RType: "light"})
+	}
+
+	body := map[string]interface{}{
+		"metadata": map[string]string{
+			"name":      name,
+			"archetype": archetype,
+		},
+		"children": children,
+	}
+
+	resp, err := c.request("POST", "/clip/v2/resource/zone", body)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := createdResourceID(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("id", id).Str("name", name).Int("lights", len(lightIDs)).Msg("Zone created")
+	return c.refreshGroup(id)
+}
+
+// AddLightToGroup adds a light to a room or zone. As a device can only be
+// in one room, it is removed from its previous room first.
+func (c *Client) AddLightToGroup(groupID, lightID string) (*models.Group, error) {
+	group, err := c.lookupGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := c.childForGroup(group.Type, lightID)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.Type == "room" {
+		if err := c.removeDeviceFromRooms(child.RID, groupID); err != nil {
+			return nil, err
+		}
+	}
+
+	children, err := c.groupChildren(group.Type, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range children {
+		if existing == child {
+			return group, nil
+		}
+	}
+
+	if err := c.setGroupChildren(group.Type, groupID, append(children, child)); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("group_id", groupID).Str("light_id", lightID).Msg("Light added to group")
+	return c.refreshGroup(groupID)
+}
+
+// RemoveLightFromGroup removes a light from a room or zone
+func (c *Client) RemoveLightFromGroup(groupID, lightID string) (*models.Group, error) {
+	group, err := c.lookupGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := c.childForGroup(group.Type, lightID)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := c.groupChildren(group.Type, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make([]groupChild, 0, len(children))
+	for _, existing := range children {
+		if existing != child {
+			remaining = append(remaining, existing)
+		}
+	}
+	if len(remaining) == len(children) {
+		return nil, fmt.Errorf("light %s is not part of group %s", lightID, groupID)
+	}
+
+	if err := c.setGroupChildren(group.Type, groupID, remaining); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("group_id", groupID).Str("light_id", lightID).Msg("Light removed from group")
+	return c.refreshGroup(groupID)
+}
+
+// removeDeviceFromRooms removes a device from every room except the given one
+func (c *Client) removeDeviceFromRooms(deviceID, exceptRoomID string) error {
+	resp, err := c.request("GET", "/clip/v2/resource/room", nil)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Data []struct {
+			ID       string       `json:"id"`
+			Children []groupChild `json:"children"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return err
+	}
+
+	for _, room := range result.Data {
+		if room.ID == exceptRoomID {
+			continue
+		}
+
+		remaining := make([]groupChild, 0, len(room.Children))
+		for _, child := range room.Children {
+			if !(child.RType == "device" && child.RID == deviceID) {
+				remaining = append(remaining, child)
+			}
+		}
+		if len(remaining) == len(room.Children) {
+			continue
+		}
+
+		if err := c.setGroupChildren("room", room.ID, remaining); err != nil {
+			return err
+		}
+		log.Debug().Str("room_id", room.ID).Str("device_id", deviceID).Msg("Device removed from previous room")
+	}
+
+	return nil
+}
+
+// DeleteZone deletes a zone. Rooms cannot be deleted through the gateway.
+func (c *Client) DeleteZone(id string) error {
+	group, err := c.lookupGroup(id)
+	if err != nil {
+		return err
+	}
+	if group.Type != "zone" {
+		return fmt.Errorf("only zones can be deleted: %s is a %s", id, group.Type)
+	}
+
+	if _, err := c.request("DELETE", fmt.Sprintf("/clip/v2/resource/zone/%s", id), nil); err != nil {
+		return err
+	}
+
+	// The bridge deletes the zone's scenes together with the zone
+	c.mu.Lock()
+	delete(c.groups, id)
+	for sceneID, scene := range c.scenes {
+		if scene.GroupID == id {
+			delete(c.scenes, sceneID)
+		}
+	}
+	c.mu.Unlock()
+
+	log.Info().Str("id", id).Str("name", group.Name).Msg("Zone deleted")
+	return nil
+}
+
+// refreshGroup reloads all groups and returns the requested one
+func (c *Client) refreshGroup(id string) (*models.Group, error) {
+	if _, err := c.GetGroups(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	group, ok := c.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("group not found: %s", id)
+	}
+	return group, nil
+}
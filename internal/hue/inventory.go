@@ -0,0 +1,217 @@
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// maxNameLength is the maximum length of a resource name accepted by the bridge
+const maxNameLength = 32
+
+type hueDevice struct {
+	ID          string `json:"id"`
+	ProductData struct {
+		ModelID          string `json:"model_id"`
+		ManufacturerName string `json:"manufacturer_name"`
+		ProductName      string `json:"product_name"`
+		Certified        bool   `json:"certified"`
+		SoftwareVersion  string `json:"software_version"`
+	} `json:"product_data"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Archetype string `json:"archetype"`
+	} `json:"metadata"`
+	Services []struct {
+		RID   string `json:"rid"`
+		RType string `json:"rtype"`
+	} `json:"services"`
+}
+
+type hueDeviceService struct {
+	ID    string `json:"id"`
+	Owner struct {
+		RID string `json:"rid"`
+	} `json:"owner"`
+	Status     string   `json:"status"`
+	MACAddress string   `json:"mac_address"`
+	State      string   `json:"state"`
+	Problems   []string `json:"problems"`
+}
+
+// GetInventory fetches all devices joined with their lights, room,
+// zigbee connectivity and software update state
+func (c *Client) GetInventory() ([]*models.InventoryDevice, error) {
+	resp, err := c.request("GET", "/clip/v2/resource/device", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []hueDevice `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+
+	connectivity := c.deviceServices("zigbee_connectivity")
+	updates := c.deviceServices("device_software_update")
+
+	// Rooms reference devices as children
+	roomByDevice := make(map[string]string)
+	roomsResp, err := c.request("GET", "/clip/v2/resource/room", nil)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch rooms for inventory")
+	} else {
+		var roomsResult struct {
+			Data []hueRoom `json:"data"`
+		}
+		if err := json.Unmarshal(roomsResp, &roomsResult); err == nil {
+			for _, room := range roomsResult.Data {
+				for _, child := range room.Children {
+					if child.RType == "device" {
+						roomByDevice[child.RID] = room.Metadata.Name
+					}
+				}
+			}
+		}
+	}
+
+	devices := make([]*models.InventoryDevice, 0, len(result.Data))
+	for _, hd := range result.Data {
+		device := &models.InventoryDevice{
+			ID:              hd.ID,
+			Name:            hd.Metadata.Name,
+			Archetype:       hd.Metadata.Archetype,
+			ModelID:         hd.ProductData.ModelID,
+			Manufacturer:    hd.ProductData.ManufacturerName,
+			ProductName:     hd.ProductData.ProductName,
+			Certified:       hd.ProductData.Certified,
+			SoftwareVersion: hd.ProductData.SoftwareVersion,
+			Room:            roomByDevice[hd.ID],
+			Lights:          make([]string, 0),
+		}
+
+		for _, service := range hd.Services {
+			if service.RType == "light" {
+				device.Lights = append(device.Lights, service.RID)
+			}
+		}
+		if zc, ok := connectivity[hd.ID]; ok {
+			device.Connectivity = zc.Status
+			device.MACAddress = zc.MACAddress
+		}
+		if su, ok := updates[hd.ID]; ok {
+			device.UpdateState = su.State
+			device.UpdateProblems = su.Problems
+		}
+
+		devices = append(devices, device)
+	}
+
+	log.Debug().Int("count", len(devices)).Msg("Fetched device inventory from bridge")
+	return devices, nil
+}
+
+// deviceServices fetches all resources of a device service type keyed by owner device ID.
+// Errors are logged and result in an empty map, as not every bridge exposes every service.
+func (c *Client) deviceServices(rtype string) map[string]hueDeviceService {
+	services := make(map[string]hueDeviceService)
+
+	resp, err := c.request("GET", fmt.Sprintf("/clip/v2/resource/%s", rtype), nil)
+	if err != nil {
+		log.Warn().Err(err).Str("type", rtype).Msg("Failed to fetch device services")
+		return services
+	}
+
+	var result struct {
+		Data []hueDeviceService `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		log.Warn().Err(err).Str("type", rtype).Msg("Failed to parse device services")
+		return services
+	}
+
+	for _, s := range result.Data {
+		services[s.Owner.RID] = s
+	}
+	return services
+}
+
+// validateName checks a resource name against the bridge limits
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name required")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("name must not be longer than %d characters", maxNameLength)
+	}
+	return nil
+}
+
+// RenameDevice changes the name of a device
+func (c *Client) RenameDevice(id, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	return c.rename("device", id, name)
+}
+
+// RenameLight changes the name of a light
+func (c *Client) RenameLight(id, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	if err := c.rename("light", id, name); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if light, ok := c.lights[id]; ok {
+		light.Name = name
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// RenameGroup changes the name of a room or zone
+func (c *Client) RenameGroup(id, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	group, err := c.lookupGroup(id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.rename(group.Type, id, name); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	group.Name = name
+	c.mu.Unlock()
+
+	return nil
+}
+
+// rename updates metadata.name of a resource
+func (c *Client) rename(rtype, id, name string) error {
+	body := map[string]interface{}{
+		"metadata": map[string]string{"name": name},
+	}
+
+	if _, err := c.request("PUT", fmt.Sprintf("/clip/v2/resource/%s/%s", rtype, id), body); err != nil {
+		return err
+	}
+
+	log.Info().Str("type", rtype).Str("id", id).Str("name", name).Msg("Resource renamed")
+	return nil
+}
@@ -0,0 +1,153 @@
+package hue
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// PowerUpPresets lists the power-up presets supported by the bridge
+var PowerUpPresets = []string{"safety", "powerfail", "last_on_state", "custom"}
+
+type huePowerUp struct {
+	Preset     string `json:"preset"`
+	Configured bool   `json:"configured"`
+	On         *struct {
+		Mode string `json:"mode"`
+		On   *struct {
+			On bool `json:"on"`
+		} `json:"on,omitempty"`
+	} `json:"on,omitempty"`
+	Dimming *struct {
+		Mode    string `json:"mode"`
+		Dimming *struct {
+			Brightness float64 `json:"brightness"`
+		} `json:"dimming,omitempty"`
+	} `json:"dimming,omitempty"`
+	Color *struct {
+		Mode             string `json:"mode"`
+		ColorTemperature *struct {
+			Mirek int `json:"mirek"`
+		} `json:"color_temperature,omitempty"`
+	} `json:"color,omitempty"`
+}
+
+func convertHuePowerUp(hp *huePowerUp) *models.PowerUp {
+	powerUp := &models.PowerUp{
+		Preset:     hp.Preset,
+		Configured: hp.Configured,
+	}
+
+	if hp.Preset != "custom" {
+		return powerUp
+	}
+
+	if hp.On != nil && hp.On.Mode == "on" && hp.On.On != nil {
+		on := hp.On.On.On
+		powerUp.On = &on
+	}
+	if hp.Dimming != nil && hp.Dimming.Mode == "dimming" && hp.Dimming.Dimming != nil {
+		bri := hp.Dimming.Dimming.Brightness
+		powerUp.Brightness = &bri
+	}
+	if hp.Color != nil && hp.Color.Mode == "color_temperature" && hp.Color.ColorTemperature != nil {
+		mirek := hp.Color.ColorTemperature.Mirek
+		powerUp.ColorTemp = &mirek
+	}
+
+	return powerUp
+}
+
+// ValidatePowerUp checks a power-up setting before it is sent to the bridge
+func ValidatePowerUp(powerUp models.PowerUp) error {
+	valid := false
+	for _, preset := range PowerUpPresets {
+		if powerUp.Preset == preset {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid power-up preset: %s", powerUp.Preset)
+	}
+
+	if powerUp.Preset != "custom" && (powerUp.On != nil || powerUp.Brightness != nil || powerUp.ColorTemp != nil) {
+		return fmt.Errorf("on, brightness and color_temp are only allowed with the custom preset")
+	}
+	if powerUp.Brightness != nil && (*powerUp.Brightness < 0 || *powerUp.Brightness > 100) {
+		return fmt.Errorf("brightness must be between 0 and 100")
+	}
+	if powerUp.ColorTemp != nil && (*powerUp.ColorTemp < 153 || *powerUp.ColorTemp > 500) {
+		return fmt.Errorf("color_temp must be between 153 and 500 mirek")
+	}
+
+	return nil
+}
+
+// SetPowerUp configures the power-up behaviour of a light
+func (c *Client) SetPowerUp(id string, powerUp models.PowerUp) error {
+	if err := ValidatePowerUp(powerUp); err != nil {
+		return err
+	}
+
+	light, err := c.GetLight(id)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"preset": powerUp.Preset,
+	}
+
+	if powerUp.Preset == "custom" {
+		if powerUp.On != nil {
+			body["on"] = map[string]interface{}{
+				"mode": "on",
+				"on":   map[string]bool{"on": *powerUp.On},
+			}
+		} else {
+			body["on"] = map[string]string{"mode": "previous"}
+		}
+
+		if light.Capabilities.SupportsDimming {
+			if powerUp.Brightness != nil {
+				body["dimming"] = map[string]interface{}{
+					"mode":    "dimming",
+					"dimming": map[string]float64{"brightness": *powerUp.Brightness},
+				}
+			} else {
+				body["dimming"] = map[string]string{"mode": "previous"}
+			}
+		}
+
+		if light.Capabilities.SupportsColorTemp || light.Capabilities.SupportsColor {
+			if powerUp.ColorTemp != nil && light.Capabilities.SupportsColorTemp {
+				body["color"] = map[string]interface{}{
+					"mode":              "color_temperature",
+					"color_temperature": map[string]int{"mirek": *powerUp.ColorTemp},
+				}
+			} else {
+				body["color"] = map[string]string{"mode": "previous"}
+			}
+		}
+	}
+
+	_, err = c.request("PUT", fmt.Sprintf("/clip/v2/resource/light/%s", id), map[string]interface{}{
+		"powerup": body,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if cached, ok := c.lights[id]; ok {
+		updated := powerUp
+		updated.Configured = true
+		cached.PowerUp = &updated
+	}
+	c.mu.Unlock()
+
+	log.Debug().Str("id", id).Str("preset", powerUp.Preset).Msg("Light power-up behaviour updated")
+	return nil
+}
@@ -0,0 +1,76 @@
+package hue
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry holds the primary bridge client and any additional bridges.
+// Mappings reference additional bridges by their configured ID, an empty
+// ID always refers to the primary bridge.
+type Registry struct {
+	primary *Client
+	bridges map[string]*Client
+	mu      sync.RWMutex
+}
+
+// NewRegistry creates a registry around the primary bridge client
+func NewRegistry(primary *Client) *Registry {
+	return &Registry{
+		primary: primary,
+		bridges: make(map[string]*Client),
+	}
+}
+
+// Primary returns the primary bridge client
+func (r *Registry) Primary() *Client {
+	return r.primary
+}
+
+// Add registers an additional bridge
+func (r *Registry) Add(id string, client *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bridges[id] = client
+}
+
+// Get returns the client for a bridge ID
+func (r *Registry) Get(id string) (*Client, error) {
+	if id == "" {
+		return r.primary, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	client, ok := r.bridges[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown bridge: %s", id)
+	}
+	return client, nil
+}
+
+// IDs returns the IDs of all additional bridges
+func (r *Registry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.bridges))
+	for id := range r.bridges {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Close closes all bridge clients
+func (r *Registry) Close() {
+	r.primary.Close()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, client := range r.bridges {
+		client.Close()
+	}
+}
@@ -0,0 +1,98 @@
+package hue
+
+import "github.com/rs/zerolog/log"
+
+// Resources is a snapshot of the resource IDs known on a bridge
+type Resources struct {
+	Lights        map[string]bool
+	Groups        map[string]bool
+	Scenes        map[string]bool
+	Entertainment map[string]bool // nil if the entertainment areas could not be fetched
+}
+
+// Known reports whether the resources of a mapping type could be fetched
+func (r *Resources) Known(hueType string) bool {
+	return hueType != "entertainment" || r.Entertainment != nil
+}
+
+// Has reports whether a resource of the given mapping type exists
+func (r *Resources) Has(hueType, id string) bool {
+	switch hueType {
+	case "light":
+		return r.Lights[id]
+	case "group":
+		return r.Groups[id]
+	case "scene":
+		return r.Scenes[id]
+	case "entertainment":
+		return r.Entertainment[id]
+	}
+	return false
+}
+
+// Resources returns the IDs of all lights, rooms/zones, scenes and
+// entertainment areas. The resource cache is used unless it is empty or
+// refresh is set, in which case everything is fetched from the bridge.
+func (c *Client) Resources(refresh bool) (*Resources, error) {
+	res := &Resources{
+		Lights:        make(map[string]bool),
+		Groups:        make(map[string]bool),
+		Scenes:        make(map[string]bool),
+		Entertainment: make(map[string]bool),
+	}
+
+	c.mu.RLock()
+	cached := len(c.lights) > 0 && len(c.groups) > 0 && len(c.scenes) > 0
+	c.mu.RUnlock()
+
+	if refresh || !cached {
+		lights, err := c.GetLights()
+		if err != nil {
+			return nil, err
+		}
+		for _, light := range lights {
+			res.Lights[light.ID] = true
+		}
+
+		groups, err := c.GetGroups()
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			res.Groups[group.ID] = true
+		}
+
+		scenes, err := c.GetScenes()
+		if err != nil {
+			return nil, err
+		}
+		for _, scene := range scenes {
+			res.Scenes[scene.ID] = true
+		}
+	} else {
+		c.mu.RLock()
+		for id := range c.lights {
+			res.Lights[id] = true
+		}
+		for id := range c.groups {
+			res.Groups[id] = true
+		}
+		for id := range c.scenes {
+			res.Scenes[id] = true
+		}
+		c.mu.RUnlock()
+	}
+
+	// Bridges without entertainment support must not skip the other checks
+	configs, err := c.GetEntertainmentConfigurations()
+	if err != nil {
+		log.Warn().Err(err).Str("bridge", c.BridgeIP()).Msg("Failed to fetch entertainment configurations")
+		res.Entertainment = nil
+		return res, nil
+	}
+	for _, config := range configs {
+		res.Entertainment[config.ID] = true
+	}
+
+	return res, nil
+}
@@ -33,11 +33,16 @@ func (p *CommandParser) ParseJSON(data []byte) (*models.LoxoneCommand, error) {
 //   - SET light_1 OFF
 //   - SET light_1 BRI 80
 //   - SET light_1 COLOR #FF5500
+//   - SET light_1 COLOR 100050000      (Loxone RGB value BBBGGGRRR, 0-100 each)
+//   - SET light_1 COLOR rgb:100000     (Loxone RGB value, prefix needed for six digits)
 //   - SET light_1 CT 3000
+//   - SET light_1 GRADIENT #FF0000,#00FF00,#0000FF [mode]
 //   - SET group_1 SCENE relax
 //   - GET light_1 STATUS
 //   - SCENE <scene_mapping_id>       - Activate a scene by mapping ID
 //   - MOOD <target> <mood_number>    - Activate scene for mood number (0=off)
+//   - STREAM <target> <pattern> [color] - Start entertainment streaming
+//   - STREAM <target> OFF            - Stop entertainment streaming
 func (p *CommandParser) ParseText(text string) (*models.LoxoneCommand, error) {
 	parts := strings.Fields(text)
 	if len(parts) < 2 {
@@ -81,6 +86,25 @@ func (p *CommandParser) ParseText(text string) (*models.LoxoneCommand, error) {
 		}, nil
 	}
 
+	// Handle STREAM command: STREAM <target> <pattern|OFF> [color]
+	if cmdType == "STREAM" {
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("stream command requires target and pattern")
+		}
+		params := map[string]interface{}{
+			"pattern": strings.ToLower(parts[2]),
+		}
+		if len(parts) > 3 {
+			params["color"] = parts[3]
+		}
+		return &models.LoxoneCommand{
+			Type:   "command",
+			Target: parts[1],
+			Action: "stream",
+			Params: params,
+		}, nil
+	}
+
 	// Other commands need at least 3 parts
 	if len(parts) < 3 {
 		return nil, fmt.Errorf("invalid command format: %s", text)
@@ -117,7 +141,11 @@ func (p *CommandParser) ParseText(text string) (*models.LoxoneCommand, error) {
 			if len(parts) < 4 {
 				return nil, fmt.Errorf("color value required")
 			}
-			cmd.Params["color"] = parts[3]
+			color, err := parseColor(parts[3])
+			if err != nil {
+				return nil, err
+			}
+			cmd.Params["color"] = color
 		case "CT":
 			if len(parts) < 4 {
 				return nil, fmt.Errorf("color temperature value required")
@@ -127,6 +155,23 @@ func (p *CommandParser) ParseText(text string) (*models.LoxoneCommand, error) {
 				return nil, fmt.Errorf("invalid color temperature: %s", parts[3])
 			}
 			cmd.Params["color_temp"] = ct
+		case "GRADIENT":
+			if len(parts) < 4 {
+				return nil, fmt.Errorf("gradient colors required")
+			}
+			colors := strings.Split(parts[3], ",")
+			if len(colors) < 2 {
+				return nil, fmt.Errorf("gradient requires at least 2 colors")
+			}
+			for _, color := range colors {
+				if hexToXY(color) == nil {
+					return nil, fmt.Errorf("invalid gradient color %q", color)
+				}
+			}
+			cmd.Params["gradient"] = colors
+			if len(parts) > 4 {
+				cmd.Params["gradient_mode"] = strings.ToLower(parts[4])
+			}
 		case "SCENE":
 			if len(parts) < 4 {
 				return nil, fmt.Errorf("scene ID required")
@@ -177,9 +222,52 @@ func (p *CommandParser) ToDeviceCommand(cmd *models.LoxoneCommand) models.Device
 		}
 	}
 
+	// Gradient colors come as []string from the text protocol and as
+	// []interface{} from JSON commands
+	var gradient []string
+	switch v := cmd.Params["gradient"].(type) {
+	case []string:
+		gradient = v
+	case []interface{}:
+		for _, c := range v {
+			if s, ok := c.(string); ok {
+				gradient = append(gradient, s)
+			}
+		}
+	}
+	for _, color := range gradient {
+		if xy := hexToXY(color); xy != nil {
+			dc.Gradient = append(dc.Gradient, models.Color{XY: *xy})
+		}
+	}
+	if mode, ok := cmd.Params["gradient_mode"].(string); ok {
+		dc.GradientMode = mode
+	}
+
 	return dc
 }
 
+// ToStreamOptions converts Loxone stream command params to StreamOptions.
+// Returns false if the command stops streaming.
+func (p *CommandParser) ToStreamOptions(cmd *models.LoxoneCommand) (models.StreamOptions, bool) {
+	opts := models.StreamOptions{}
+
+	if pattern, ok := cmd.Params["pattern"].(string); ok {
+		opts.Pattern = strings.ToLower(pattern)
+	}
+	if color, ok := cmd.Params["color"].(string); ok {
+		opts.Color = color
+	}
+	if speed, ok := cmd.Params["speed"].(float64); ok {
+		opts.Speed = speed
+	}
+	if bri, ok := cmd.Params["brightness"].(float64); ok {
+		opts.Brightness = bri
+	}
+
+	return opts, opts.Pattern != "off" && opts.Pattern != "stop"
+}
+
 // hexToXY converts a hex color string to XY color space
 // This is a simplified conversion - real implementation would need
 // proper color space transformation based on gamut
@@ -241,6 +329,59 @@ func hexToXY(hex string) *[2]float64 {
 	return &[2]float64{x, y}
 }
 
+// loxoneRGBPrefix marks a Loxone RGB value explicitly, needed for values
+// that are also valid hex colors such as 100000 (green)
+const loxoneRGBPrefix = "rgb:"
+
+// parseColor converts a COLOR value to a hex color. Values with the rgb:
+// prefix and numbers that are not six hex digits are Loxone RGB values.
+func parseColor(value string) (string, error) {
+	if strings.HasPrefix(strings.ToLower(value), loxoneRGBPrefix) {
+		hex, ok := loxoneRGBToHex(value[len(loxoneRGBPrefix):])
+		if !ok {
+			return "", fmt.Errorf("invalid Loxone RGB value: %s", value)
+		}
+		return hex, nil
+	}
+	if isHexColor(value) {
+		return value, nil
+	}
+	if hex, ok := loxoneRGBToHex(value); ok {
+		return hex, nil
+	}
+	return value, nil
+}
+
+// isHexColor reports whether s is a hex color with or without #
+func isHexColor(s string) bool {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 16, 32)
+	return err == nil
+}
+
+// loxoneRGBToHex converts a Loxone RGB value (blue*1000000 + green*1000 + red,
+// each 0-100 percent) to a hex color
+func loxoneRGBToHex(value string) (string, bool) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 0 {
+		return "", false
+	}
+
+	percent := func(p int) int {
+		if p > 100 {
+			p = 100
+		}
+		return p * 255 / 100
+	}
+	r := percent(v % 1000)
+	g := percent(v / 1000 % 1000)
+	b := percent(v / 1000000 % 1000)
+	return fmt.Sprintf("#%02X%02X%02X", r, g, b), true
+}
+
 func pow(base, exp float64) float64 {
 	result := 1.0
 	for i := 0; i < int(exp); i++ {
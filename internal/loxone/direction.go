@@ -0,0 +1,107 @@
+package loxone
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// Mapping directions, an empty direction means both
+const (
+	DirectionToHue    = "to_hue"
+	DirectionToLoxone = "to_loxone"
+	DirectionBoth     = "both"
+)
+
+// ValidDirection reports whether direction is empty or a known direction
+func ValidDirection(direction string) bool {
+	switch direction {
+	case "", DirectionToHue, DirectionToLoxone, DirectionBoth:
+		return true
+	}
+	return false
+}
+
+// SendsToHue reports whether Loxone commands pass through a mapping
+func SendsToHue(direction string) bool {
+	return direction != DirectionToLoxone
+}
+
+// SendsToLoxone reports whether HUE state changes are reported through a mapping
+func SendsToLoxone(direction string) bool {
+	return direction != DirectionToHue
+}
+
+// StateOnBrightness returns the on state and brightness of a light or group state
+func StateOnBrightness(state interface{}) (bool, float64) {
+	switch s := state.(type) {
+	case models.LightState:
+		return s.On, s.Brightness
+	case models.GroupState:
+		return s.AnyOn, s.Brightness
+	}
+	return false, 0
+}
+
+// echoWindow is how long a HUE event matching a Loxone command counts as its echo
+const echoWindow = 3 * time.Second
+
+// echoTolerance is the brightness difference still treated as the same value,
+// transforms and HUE rounding shift the reported brightness slightly
+const echoTolerance = 1.5
+
+// EchoSuppressor remembers the commands Loxone sent per Loxone ID so the
+// resulting HUE events are not reported back, which would make analog
+// outputs fight with their own feedback
+type EchoSuppressor struct {
+	mu       sync.Mutex
+	commands map[string]echoCommand
+}
+
+type echoCommand struct {
+	on         *bool
+	brightness *float64
+	at         time.Time
+}
+
+// NewEchoSuppressor creates an empty echo suppressor
+func NewEchoSuppressor() *EchoSuppressor {
+	return &EchoSuppressor{commands: make(map[string]echoCommand)}
+}
+
+// Record remembers a command sent from Loxone. on and brightness are the
+// Loxone values, nil when the command did not set them.
+func (e *EchoSuppressor) Record(loxoneID string, on *bool, brightness *float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	for id, cmd := range e.commands {
+		if now.Sub(cmd.at) > echoWindow {
+			delete(e.commands, id)
+		}
+	}
+	e.commands[loxoneID] = echoCommand{on: on, brightness: brightness, at: now}
+}
+
+// IsEcho reports whether a state reported for loxoneID is the result of the
+// last Loxone command. A differing state is a change made elsewhere, e.g. in
+// the HUE app, and is still reported.
+func (e *EchoSuppressor) IsEcho(loxoneID string, on bool, brightness float64) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cmd, ok := e.commands[loxoneID]
+	if !ok || time.Since(cmd.at) > echoWindow {
+		return false
+	}
+	if cmd.on != nil && *cmd.on != on {
+		return false
+	}
+	if on && cmd.brightness != nil && math.Abs(*cmd.brightness-brightness) > echoTolerance {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package loxone
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// GenerateOptions controls which mappings GenerateMappings proposes
+type GenerateOptions struct {
+	Groups bool
+	Lights bool
+	Scenes bool
+	Bridge string // Bridge the resources belong to, empty for the primary bridge
+}
+
+// GenerateMappings proposes mappings for the rooms, zones, lights and scenes
+// of a bridge. Resources that already have a mapping are left out and
+// Loxone IDs already in use are not reused. Scenes become
+// <room>_mood_<n> mappings numbered per room in name order.
+func GenerateMappings(groups []*models.Group, lights []*models.Light, scenes []*models.Scene, existing []models.Mapping, opts GenerateOptions) []models.Mapping {
+	used := make(map[string]bool)
+	mapped := make(map[string]bool)
+	groupIDs := make(map[string]string) // HUE group ID -> existing Loxone ID
+	for _, m := range existing {
+		used[m.LoxoneID] = true
+		mapped[m.HueType+"|"+m.Bridge+"|"+m.HueID] = true
+		if m.HueType == "group" && m.Bridge == opts.Bridge {
+			groupIDs[m.HueID] = m.LoxoneID
+		}
+	}
+
+	groups = append([]*models.Group(nil), groups...)
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	lights = append([]*models.Light(nil), lights...)
+	sort.SliceStable(lights, func(i, j int) bool { return lights[i].Name < lights[j].Name })
+	scenes = append([]*models.Scene(nil), scenes...)
+	sort.SliceStable(scenes, func(i, j int) bool { return scenes[i].Name < scenes[j].Name })
+
+	result := make([]models.Mapping, 0)
+	propose := func(loxoneID, name, hueType, hueID, description string) {
+		if mapped[hueType+"|"+opts.Bridge+"|"+hueID] {
+			return
+		}
+		used[loxoneID] = true
+		result = append(result, models.Mapping{
+			Name:        name,
+			LoxoneID:    loxoneID,
+			HueID:       hueID,
+			HueType:     hueType,
+			Bridge:      opts.Bridge,
+			Enabled:     true,
+			Description: description,
+		})
+	}
+
+	// Room and zone IDs prefix lights and scenes, mapped groups keep their
+	// existing Loxone ID so moods match the MOOD target
+	groupSlugs := make(map[string]string)
+	roomOf := make(map[string]*models.Group)
+	for _, group := range groups {
+		slug, ok := groupIDs[group.ID]
+		if !ok {
+			slug = Slugify(group.Name)
+		}
+		if slug == "" {
+			slug = group.Type
+		}
+		groupSlugs[group.ID] = slug
+
+		if group.Type == "room" {
+			for _, lightID := range group.Lights {
+				roomOf[lightID] = group
+			}
+		}
+	}
+
+	if opts.Groups {
+		for _, group := range groups {
+			if _, ok := groupIDs[group.ID]; ok {
+				continue
+			}
+			loxoneID := uniqueID(groupSlugs[group.ID], used)
+			groupSlugs[group.ID] = loxoneID
+			propose(loxoneID, group.Name, "group", group.ID, "Generated from HUE "+group.Type)
+		}
+	}
+
+	if opts.Lights {
+		for _, light := range lights {
+			slug := Slugify(light.Name)
+			name := light.Name
+			if room, ok := roomOf[light.ID]; ok {
+				slug = groupSlugs[room.ID] + "_" + slug
+				name = room.Name + " " + light.Name
+			}
+			loxoneID := uniqueID(strings.Trim(slug, "_"), used)
+			propose(loxoneID, name, "light", light.ID, "Generated from HUE light")
+		}
+	}
+
+	if opts.Scenes {
+		for _, group := range groups {
+			n := 0
+			for _, scene := range scenes {
+				if scene.GroupID != group.ID || mapped["scene|"+opts.Bridge+"|"+scene.ID] {
+					continue
+				}
+
+				// Next free mood number of the room
+				var loxoneID string
+				for {
+					n++
+					loxoneID = groupSlugs[group.ID] + "_mood_" + itoa(n)
+					if !used[loxoneID] {
+						break
+					}
+				}
+				propose(loxoneID, group.Name+" "+scene.Name, "scene", scene.ID, "Generated from HUE scene")
+			}
+		}
+	}
+
+	return result
+}
+
+// uniqueID returns id, or id with a numeric suffix if it is already in use
+func uniqueID(id string, used map[string]bool) string {
+	if id == "" {
+		id = "hue"
+	}
+	if !used[id] {
+		return id
+	}
+	for n := 2; ; n++ {
+		candidate := id + "_" + itoa(n)
+		if !used[candidate] {
+			return candidate
+		}
+	}
+}
+
+var slugReplacer = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss", "é", "e", "è", "e", "à", "a")
+
+// Slugify turns a HUE name into a Loxone ID, e.g. "Küche Decke" into "kueche_decke"
+func Slugify(name string) string {
+	name = slugReplacer.Replace(strings.ToLower(name))
+
+	var b strings.Builder
+	underscore := false
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+			continue
+		}
+		if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
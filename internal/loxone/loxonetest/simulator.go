@@ -0,0 +1,479 @@
+// Package loxonetest provides a stand-in for a Loxone Miniserver. It speaks
+// the parts of the Miniserver WebSocket protocol the gateway uses (key
+// exchange, token authentication, structure file, binary status updates),
+// for tests and the miniserver-sim command.
+package loxonetest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// Message identifiers of the binary message header
+const (
+	msgText        byte = 0
+	msgBinaryFile  byte = 1
+	msgValueEvents byte = 2
+	msgTextEvents  byte = 3
+	msgKeepalive   byte = 6
+)
+
+// SampleStructure is served when no structure file is given
+const SampleStructure = `{
+  "lastModified": "2024-01-01 12:00:00",
+  "msInfo": {"msName": "Simulator", "projectName": "Loxone2HUE"},
+  "rooms": {"0b5a1c2d-0001-0001-ffff000000000001": {"name": "Wohnzimmer"}},
+  "cats": {"0b5a1c2d-0002-0001-ffff000000000001": {"name": "Beleuchtung"}},
+  "controls": {
+    "0b5a1c2d-0010-0001-ffff000000000001": {
+      "name": "Lichtsteuerung", "type": "LightControllerV2",
+      "uuidAction": "0b5a1c2d-0010-0001-ffff000000000001",
+      "room": "0b5a1c2d-0001-0001-ffff000000000001", "cat": "0b5a1c2d-0002-0001-ffff000000000001",
+      "states": {"activeMoods": "0b5a1c2d-0010-0002-ffff000000000001", "moodList": "0b5a1c2d-0010-0003-ffff000000000001"},
+      "subControls": {
+        "0b5a1c2d-0011-0001-ffff000000000001": {
+          "name": "Decke", "type": "Dimmer", "uuidAction": "0b5a1c2d-0011-0001-ffff000000000001",
+          "states": {"position": "0b5a1c2d-0011-0002-ffff000000000001", "min": "0b5a1c2d-0011-0003-ffff000000000001", "max": "0b5a1c2d-0011-0004-ffff000000000001"}
+        },
+        "0b5a1c2d-0012-0001-ffff000000000001": {
+          "name": "Stehlampe", "type": "ColorPickerV2", "uuidAction": "0b5a1c2d-0012-0001-ffff000000000001",
+          "states": {"color": "0b5a1c2d-0012-0002-ffff000000000001", "sequence": "0b5a1c2d-0012-0003-ffff000000000001"}
+        }
+      }
+    }
+  }
+}`
+
+// sampleValues and sampleTexts are the initial states of the sample structure
+var (
+	sampleValues = map[string]float64{
+		"0b5a1c2d-0011-0002-ffff000000000001": 0,
+		"0b5a1c2d-0011-0003-ffff000000000001": 0,
+		"0b5a1c2d-0011-0004-ffff000000000001": 100,
+	}
+	sampleTexts = map[string]string{
+		"0b5a1c2d-0010-0002-ffff000000000001": "[778]",
+		"0b5a1c2d-0012-0002-ffff000000000001": "hsv(0,0,0)",
+	}
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{"remotecontrol"},
+	CheckOrigin:  func(r *http.Request) bool { return true },
+}
+
+// Simulator holds the Miniserver state shared by all connections
+type Simulator struct {
+	username  string
+	password  string
+	key       *rsa.PrivateKey
+	structure []byte
+
+	mu       sync.Mutex
+	salt     string
+	tokens   map[string]bool
+	issued   int
+	values   map[string]float64
+	texts    map[string]string
+	sessions map[*session]bool
+}
+
+// session is a single WebSocket connection
+type session struct {
+	sim           *Simulator
+	conn          *websocket.Conn
+	writeMu       sync.Mutex
+	aesKey        []byte
+	aesIV         []byte
+	hmacKey       string
+	authenticated bool
+	subscribed    bool
+}
+
+// NewSimulator creates a Miniserver accepting the given user. A nil
+// structure serves SampleStructure with its initial states.
+func NewSimulator(username, password string, structure []byte) (*Simulator, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+	}
+
+	s := &Simulator{
+		username:  username,
+		password:  password,
+		key:       key,
+		structure: structure,
+		salt:      randomHex(16),
+		tokens:    make(map[string]bool),
+		values:    make(map[string]float64),
+		texts:     make(map[string]string),
+		sessions:  make(map[*session]bool),
+	}
+	if structure == nil {
+		s.structure = []byte(SampleStructure)
+		for uuid, value := range sampleValues {
+			s.values[uuid] = value
+		}
+		for uuid, text := range sampleTexts {
+			s.texts[uuid] = text
+		}
+	}
+	return s, nil
+}
+
+// Handler serves the WebSocket API on /ws/rfc6455 and state changes on
+// /sim/value and /sim/text
+func (s *Simulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/rfc6455", s.handleWebSocket)
+	mux.HandleFunc("/sim/value", s.handleValue)
+	mux.HandleFunc("/sim/text", s.handleText)
+	return mux
+}
+
+// handleValue changes a value state: POST /sim/value?uuid=&value=
+func (s *Simulator) handleValue(w http.ResponseWriter, r *http.Request) {
+	uuid := r.URL.Query().Get("uuid")
+	value, err := strconv.ParseFloat(r.URL.Query().Get("value"), 64)
+	if uuid == "" || err != nil {
+		http.Error(w, "uuid and numeric value required", http.StatusBadRequest)
+		return
+	}
+	s.SetValue(uuid, value)
+}
+
+// handleText changes a text state: POST /sim/text?uuid=&text=
+func (s *Simulator) handleText(w http.ResponseWriter, r *http.Request) {
+	uuid := r.URL.Query().Get("uuid")
+	if uuid == "" {
+		http.Error(w, "uuid required", http.StatusBadRequest)
+		return
+	}
+	s.SetText(uuid, r.URL.Query().Get("text"))
+}
+
+// SetValue changes a value state and sends it to subscribed clients
+func (s *Simulator) SetValue(uuid string, value float64) {
+	s.mu.Lock()
+	s.values[uuid] = value
+	s.mu.Unlock()
+
+	s.broadcast(msgValueEvents, EncodeValueEvents(map[string]float64{uuid: value}))
+	log.Info().Str("uuid", uuid).Float64("value", value).Msg("Value changed")
+}
+
+// SetText changes a text state and sends it to subscribed clients
+func (s *Simulator) SetText(uuid, text string) {
+	s.mu.Lock()
+	s.texts[uuid] = text
+	s.mu.Unlock()
+
+	s.broadcast(msgTextEvents, EncodeTextEvents(map[string]string{uuid: text}))
+	log.Info().Str("uuid", uuid).Str("text", text).Msg("Text changed")
+}
+
+// DropConnections closes all client connections, e.g. to test reconnects
+func (s *Simulator) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sess := range s.sessions {
+		sess.conn.Close()
+	}
+}
+
+// TokensIssued returns the number of tokens handed out with getjwt
+func (s *Simulator) TokensIssued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issued
+}
+
+func (s *Simulator) broadcast(identifier byte, payload []byte) {
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		if sess.subscribed {
+			sessions = append(sessions, sess)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.write(identifier, websocket.BinaryMessage, payload)
+	}
+}
+
+func (s *Simulator) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sess := &session{sim: s, conn: conn}
+	s.mu.Lock()
+	s.sessions[sess] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+	}()
+
+	log.Info().Str("remote", r.RemoteAddr).Msg("Client connected")
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			log.Info().Str("remote", r.RemoteAddr).Msg("Client disconnected")
+			return
+		}
+		sess.handle(string(data))
+	}
+}
+
+// write sends a header and, except for keepalives, the payload
+func (sess *session) write(identifier byte, messageType int, payload []byte) {
+	sess.writeMu.Lock()
+	defer sess.writeMu.Unlock()
+
+	header := []byte{0x03, identifier, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	sess.conn.WriteMessage(websocket.BinaryMessage, header)
+	if identifier != msgKeepalive {
+		sess.conn.WriteMessage(messageType, payload)
+	}
+}
+
+func (sess *session) respond(control string, code int, value interface{}) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"LL": map[string]interface{}{"control": control, "value": value, "Code": strconv.Itoa(code)},
+	})
+	sess.write(msgText, websocket.TextMessage, data)
+}
+
+func (sess *session) handle(command string) {
+	s := sess.sim
+	switch {
+	case command == "keepalive":
+		sess.write(msgKeepalive, websocket.BinaryMessage, nil)
+
+	case command == "jdev/sys/getPublicKey":
+		der, _ := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+		sess.respond(command, 200, "-----BEGIN CERTIFICATE-----"+base64.StdEncoding.EncodeToString(der)+"-----END CERTIFICATE-----")
+
+	case strings.HasPrefix(command, "jdev/sys/keyexchange/"):
+		encrypted, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(command, "jdev/sys/keyexchange/"))
+		if err == nil {
+			var plain []byte
+			if plain, err = rsa.DecryptPKCS1v15(rand.Reader, s.key, encrypted); err == nil {
+				parts := strings.Split(string(plain), ":")
+				if len(parts) == 2 {
+					sess.aesKey, _ = hex.DecodeString(parts[0])
+					sess.aesIV, _ = hex.DecodeString(parts[1])
+				}
+			}
+		}
+		if len(sess.aesKey) != 32 || len(sess.aesIV) != aes.BlockSize {
+			sess.respond("jdev/sys/keyexchange", 400, "")
+			return
+		}
+		sess.respond("jdev/sys/keyexchange", 200, "")
+
+	case strings.HasPrefix(command, "jdev/sys/enc/"):
+		plain, err := sess.decrypt(strings.TrimPrefix(command, "jdev/sys/enc/"))
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to decrypt command")
+			sess.respond("jdev/sys/enc", 400, "")
+			return
+		}
+		sess.handle(plain)
+
+	case strings.HasPrefix(command, "jdev/sys/getkey2/"):
+		sess.hmacKey = randomHex(20)
+		sess.respond(command, 200, map[string]string{"key": sess.hmacKey, "salt": s.salt, "hashAlg": "SHA256"})
+
+	case command == "jdev/sys/getkey":
+		sess.hmacKey = randomHex(20)
+		sess.respond(command, 200, sess.hmacKey)
+
+	case strings.HasPrefix(command, "jdev/sys/getjwt/"):
+		parts := strings.Split(command, "/")
+		if len(parts) < 5 {
+			sess.respond("jdev/sys/getjwt", 400, "")
+			return
+		}
+		user, _ := url.PathUnescape(parts[4])
+		h := sha256.Sum256([]byte(s.password + ":" + s.salt))
+		expected := sess.hmac(user + ":" + strings.ToUpper(hex.EncodeToString(h[:])))
+		if user != s.username || parts[3] != expected {
+			log.Warn().Str("user", user).Msg("Invalid credentials")
+			sess.respond("jdev/sys/getjwt", 401, "")
+			return
+		}
+
+		token := randomHex(32)
+		s.mu.Lock()
+		s.tokens[token] = true
+		s.issued++
+		s.mu.Unlock()
+		sess.authenticated = true
+
+		validUntil := time.Now().Add(28*24*time.Hour).Unix() - time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+		sess.respond("jdev/sys/getjwt", 200, map[string]interface{}{"token": token, "validUntil": validUntil, "tokenRights": 4, "unsecurePass": false})
+		log.Info().Str("user", user).Msg("Token issued")
+
+	case strings.HasPrefix(command, "authwithtoken/"):
+		parts := strings.Split(command, "/")
+		valid := false
+		s.mu.Lock()
+		for token := range s.tokens {
+			if len(parts) == 3 && parts[1] == sess.hmac(token) {
+				valid = true
+			}
+		}
+		s.mu.Unlock()
+		if !valid {
+			sess.respond("authwithtoken", 401, "")
+			return
+		}
+		sess.authenticated = true
+		sess.respond("authwithtoken", 200, "")
+		log.Info().Msg("Authenticated with token")
+
+	case command == "data/LoxAPP3.json":
+		if !sess.authenticated {
+			sess.respond(command, 401, "")
+			return
+		}
+		sess.write(msgBinaryFile, websocket.TextMessage, s.structure)
+
+	case command == "jdev/sps/enablebinstatusupdate":
+		if !sess.authenticated {
+			sess.respond(command, 401, "")
+			return
+		}
+		sess.respond(command, 200, "1")
+
+		// Initial state of all values and texts
+		s.mu.Lock()
+		values := EncodeValueEvents(s.values)
+		texts := EncodeTextEvents(s.texts)
+		sess.subscribed = true
+		s.mu.Unlock()
+		sess.write(msgValueEvents, websocket.BinaryMessage, values)
+		sess.write(msgTextEvents, websocket.BinaryMessage, texts)
+
+	default:
+		log.Warn().Str("command", command).Msg("Unsupported command")
+		sess.respond(command, 404, "")
+	}
+}
+
+// decrypt reverses the jdev/sys/enc/ encryption and strips the salt
+func (sess *session) decrypt(escaped string) (string, error) {
+	if sess.aesKey == nil {
+		return "", fmt.Errorf("no key exchange")
+	}
+	unescaped, err := url.QueryUnescape(escaped)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(unescaped)
+	if err != nil {
+		return "", err
+	}
+	if len(encrypted)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("invalid cipher length")
+	}
+
+	block, err := aes.NewCipher(sess.aesKey)
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, sess.aesIV).CryptBlocks(plain, encrypted)
+	plain = bytes.TrimRight(plain, "\x00")
+
+	parts := strings.SplitN(string(plain), "/", 3)
+	if len(parts) != 3 || parts[0] != "salt" {
+		return "", fmt.Errorf("missing salt")
+	}
+	return parts[2], nil
+}
+
+func (sess *session) hmac(message string) string {
+	key, _ := hex.DecodeString(sess.hmacKey)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encodeUUID converts 8-4-4-16 UUIDs to the binary Miniserver format
+func encodeUUID(uuid string) []byte {
+	out := make([]byte, 16)
+	parts := strings.Split(uuid, "-")
+	if len(parts) != 4 {
+		return out
+	}
+	d1, _ := strconv.ParseUint(parts[0], 16, 32)
+	d2, _ := strconv.ParseUint(parts[1], 16, 16)
+	d3, _ := strconv.ParseUint(parts[2], 16, 16)
+	d4, _ := hex.DecodeString(parts[3])
+	binary.LittleEndian.PutUint32(out[0:4], uint32(d1))
+	binary.LittleEndian.PutUint16(out[4:6], uint16(d2))
+	binary.LittleEndian.PutUint16(out[6:8], uint16(d3))
+	copy(out[8:], d4)
+	return out
+}
+
+// EncodeValueEvents encodes value states as a binary value event table
+func EncodeValueEvents(values map[string]float64) []byte {
+	var b bytes.Buffer
+	for uuid, value := range values {
+		b.Write(encodeUUID(uuid))
+		binary.Write(&b, binary.LittleEndian, math.Float64bits(value))
+	}
+	return b.Bytes()
+}
+
+// EncodeTextEvents encodes text states as a binary text event table
+func EncodeTextEvents(texts map[string]string) []byte {
+	var b bytes.Buffer
+	for uuid, text := range texts {
+		b.Write(encodeUUID(uuid))
+		b.Write(make([]byte, 16)) // Icon UUID
+		binary.Write(&b, binary.LittleEndian, uint32(len(text)))
+		b.WriteString(text)
+		if rest := len(text) % 4; rest != 0 {
+			b.Write(make([]byte, 4-rest))
+		}
+	}
+	return b.Bytes()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
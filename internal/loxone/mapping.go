@@ -3,53 +3,182 @@ package loxone
 import (
 	"sync"
 
+	"github.com/rs/zerolog/log"
 	"github.com/sbeyeler/loxone2hue/internal/models"
 )
 
-// MappingManager handles Loxone to HUE resource mappings
+// MappingManager handles Loxone to HUE resource mappings.
+// A Loxone ID may map to several HUE resources and a HUE resource may be
+// mapped to several Loxone IDs.
 type MappingManager struct {
-	mappings map[string]*models.Mapping // keyed by LoxoneID
-	byHueID  map[string]*models.Mapping // keyed by HueID
-	mu       sync.RWMutex
+	all        []*models.Mapping            // all mappings in config order, including disabled ones
+	mappings   []*models.Mapping            // enabled mappings in config order
+	byLoxoneID map[string][]*models.Mapping // keyed by LoxoneID
+	byHueID    map[string][]*models.Mapping // keyed by bridge and HueID
+	conflicts  []MappingConflict
+	moodTables map[string]*models.MoodTable // keyed by Target
+	mu         sync.RWMutex
+}
+
+// MappingConflict describes a mapping that was skipped while indexing
+type MappingConflict struct {
+	MappingID     string `json:"mapping_id"`
+	LoxoneID      string `json:"loxone_id"`
+	ConflictsWith string `json:"conflicts_with,omitempty"` // ID of the mapping that was kept
+	Reason        string `json:"reason"`
 }
 
 // NewMappingManager creates a new mapping manager
 func NewMappingManager() *MappingManager {
 	return &MappingManager{
-		mappings: make(map[string]*models.Mapping),
-		byHueID:  make(map[string]*models.Mapping),
+		all:        make([]*models.Mapping, 0),
+		mappings:   make([]*models.Mapping, 0),
+		byLoxoneID: make(map[string][]*models.Mapping),
+		byHueID:    make(map[string][]*models.Mapping),
+		moodTables: make(map[string]*models.MoodTable),
 	}
 }
 
-// Load initializes mappings from a list
-func (m *MappingManager) Load(mappings []models.Mapping) {
+// Load initializes mappings from a list and returns the conflicts found.
+// Conflicts are resolved in list order, the first mapping wins.
+func (m *MappingManager) Load(mappings []models.Mapping) []MappingConflict {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.mappings = make(map[string]*models.Mapping)
-	m.byHueID = make(map[string]*models.Mapping)
-
+	list := make([]*models.Mapping, 0, len(mappings))
 	for i := range mappings {
-		mapping := &mappings[i]
-		if mapping.Enabled {
-			m.mappings[mapping.LoxoneID] = mapping
-			m.byHueID[mapping.HueID] = mapping
+		list = append(list, &mappings[i])
+	}
+	m.all = list
+	m.index()
+
+	return append([]MappingConflict(nil), m.conflicts...)
+}
+
+// index rebuilds the lookup maps from all mappings, so a mapping skipped
+// for a conflict is indexed once the other mapping is gone. Must be called
+// with the lock held.
+func (m *MappingManager) index() {
+	m.mappings = make([]*models.Mapping, 0, len(m.all))
+	m.byLoxoneID = make(map[string][]*models.Mapping)
+	m.byHueID = make(map[string][]*models.Mapping)
+	m.conflicts = make([]MappingConflict, 0)
+
+	byID := make(map[string]*models.Mapping)
+
+	for _, mapping := range m.all {
+		if !mapping.Enabled {
+			continue
+		}
+
+		conflict := func(with *models.Mapping, reason string) {
+			c := MappingConflict{MappingID: mapping.ID, LoxoneID: mapping.LoxoneID, Reason: reason}
+			if with != nil {
+				c.ConflictsWith = with.ID
+			}
+			m.conflicts = append(m.conflicts, c)
+			log.Warn().Str("mapping", mapping.ID).Str("loxone_id", mapping.LoxoneID).Str("conflicts_with", c.ConflictsWith).Msg("Mapping skipped: " + reason)
+		}
+
+		if mapping.LoxoneID == "" {
+			conflict(nil, "empty loxone_id")
+			continue
+		}
+		if other, exists := byID[mapping.ID]; exists && mapping.ID != "" {
+			conflict(other, "duplicate mapping id")
+			continue
+		}
+
+		skip := false
+		for _, other := range m.byLoxoneID[mapping.LoxoneID] {
+			if other.HueType == mapping.HueType && other.HueID == mapping.HueID && other.Bridge == mapping.Bridge {
+				conflict(other, "duplicate target")
+				skip = true
+				break
+			}
+			if TargetKind(other.HueType) != TargetKind(mapping.HueType) {
+				conflict(other, "mixes "+other.HueType+" and "+mapping.HueType+" targets")
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
 		}
+
+		byID[mapping.ID] = mapping
+		m.mappings = append(m.mappings, mapping)
+		m.byLoxoneID[mapping.LoxoneID] = append(m.byLoxoneID[mapping.LoxoneID], mapping)
+		if mapping.HueID != "" {
+			key := hueKey(mapping.Bridge, mapping.HueID)
+			m.byHueID[key] = append(m.byHueID[key], mapping)
+		}
+	}
+}
+
+// TargetKind groups HUE types that can share a Loxone ID
+func TargetKind(hueType string) string {
+	switch hueType {
+	case "light", "group", "virtual_group":
+		return "control"
 	}
+	return hueType
+}
+
+func hueKey(bridge, hueID string) string {
+	if bridge == "" {
+		return hueID
+	}
+	return bridge + "/" + hueID
+}
+
+// Conflicts returns the conflicts found by the last Load
+func (m *MappingManager) Conflicts() []MappingConflict {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]MappingConflict(nil), m.conflicts...)
 }
 
-// GetByLoxoneID returns a mapping by Loxone ID
+// GetByLoxoneID returns the first mapping for a Loxone ID
 func (m *MappingManager) GetByLoxoneID(loxoneID string) *models.Mapping {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.mappings[loxoneID]
+	if list := m.byLoxoneID[loxoneID]; len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+// GetAllByLoxoneID returns all mappings for a Loxone ID
+func (m *MappingManager) GetAllByLoxoneID(loxoneID string) []models.Mapping {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return copyMappings(m.byLoxoneID[loxoneID])
 }
 
-// GetByHueID returns a mapping by HUE ID
+// GetByHueID returns the first mapping for a HUE ID on the primary bridge
 func (m *MappingManager) GetByHueID(hueID string) *models.Mapping {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.byHueID[hueID]
+	if list := m.byHueID[hueID]; len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+// GetAllByHueID returns all mappings for a HUE ID on a bridge
+func (m *MappingManager) GetAllByHueID(bridge, hueID string) []models.Mapping {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return copyMappings(m.byHueID[hueKey(bridge, hueID)])
+}
+
+func copyMappings(list []*models.Mapping) []models.Mapping {
+	result := make([]models.Mapping, 0, len(list))
+	for _, mapping := range list {
+		result = append(result, *mapping)
+	}
+	return result
 }
 
 // Add adds a new mapping
@@ -57,10 +186,8 @@ func (m *MappingManager) Add(mapping *models.Mapping) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if mapping.Enabled {
-		m.mappings[mapping.LoxoneID] = mapping
-		m.byHueID[mapping.HueID] = mapping
-	}
+	m.all = append(m.all, mapping)
+	m.index()
 }
 
 // Remove removes a mapping by ID
@@ -68,33 +195,26 @@ func (m *MappingManager) Remove(id string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for loxoneID, mapping := range m.mappings {
-		if mapping.ID == id {
-			delete(m.mappings, loxoneID)
-			delete(m.byHueID, mapping.HueID)
-			return
+	list := make([]*models.Mapping, 0, len(m.all))
+	for _, mapping := range m.all {
+		if mapping.ID != id {
+			list = append(list, mapping)
 		}
 	}
+	m.all = list
+	m.index()
 }
 
 // GetAll returns all mappings
 func (m *MappingManager) GetAll() []models.Mapping {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-
-	result := make([]models.Mapping, 0, len(m.mappings))
-	for _, mapping := range m.mappings {
-		result = append(result, *mapping)
-	}
-	return result
+	return copyMappings(m.mappings)
 }
 
 // ResolveTarget resolves a Loxone target ID to HUE resource info
 func (m *MappingManager) ResolveTarget(target string) (hueID, hueType string, ok bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	if mapping, exists := m.mappings[target]; exists && mapping.Enabled {
+	if mapping := m.GetByLoxoneID(target); mapping != nil {
 		return mapping.HueID, mapping.HueType, true
 	}
 	return "", "", false
@@ -102,29 +222,77 @@ func (m *MappingManager) ResolveTarget(target string) (hueID, hueType string, ok
 
 // ResolveMood resolves a mood number for a target to HUE resource info
 // Looks for mapping with LoxoneID pattern: <target>_mood_<number>
-// If mood is 0, returns the group/light mapping for turning off
+// For moods 0, 777 and 778 it returns the group/light mapping to switch
 func (m *MappingManager) ResolveMood(target string, moodNumber int) (hueID, hueType string, ok bool) {
+	if mappings := m.MoodMappings(target, moodNumber); len(mappings) > 0 {
+		return mappings[0].HueID, mappings[0].HueType, true
+	}
+	return "", "", false
+}
+
+// MoodMappings returns the mappings a mood number of a target resolves to
+func (m *MappingManager) MoodMappings(target string, moodNumber int) []models.Mapping {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// For mood 0 (off), look for the base target mapping (group or light)
-	if moodNumber == 0 {
-		if mapping, exists := m.mappings[target]; exists && mapping.Enabled {
-			// Only return if it's a group or light (not a scene)
-			if mapping.HueType == "group" || mapping.HueType == "light" {
-				return mapping.HueID, mapping.HueType, true
-			}
+	// For mood 0, 777 and 778 (off and all on), look for the base target
+	// mappings (group or light)
+	if models.IsMoodOff(moodNumber) || moodNumber == models.MoodAllOn {
+		list := m.byLoxoneID[target]
+		// Only return if they can be switched off (not a scene)
+		if len(list) > 0 && TargetKind(list[0].HueType) == "control" {
+			return copyMappings(list)
 		}
-		return "", "", false
+		return nil
 	}
 
 	// For mood > 0, look for scene mapping: <target>_mood_<number>
-	moodKey := target + "_mood_" + itoa(moodNumber)
-	if mapping, exists := m.mappings[moodKey]; exists && mapping.Enabled {
-		return mapping.HueID, mapping.HueType, true
+	return copyMappings(m.byLoxoneID[target+"_mood_"+itoa(moodNumber)])
+}
+
+// VirtualGroups returns all enabled virtual group mappings
+func (m *MappingManager) VirtualGroups() []models.Mapping {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]models.Mapping, 0)
+	for _, mapping := range m.mappings {
+		if mapping.HueType == "virtual_group" {
+			result = append(result, *mapping)
+		}
 	}
+	return result
+}
 
-	return "", "", false
+// ApplyOverrides applies the per-mapping overrides to a device command.
+// Pure off commands are passed through unchanged.
+func ApplyOverrides(cmd models.DeviceCommand, o *models.MappingOverrides) models.DeviceCommand {
+	if o == nil {
+		return cmd
+	}
+	if cmd.On != nil && !*cmd.On && cmd.Brightness == nil && cmd.ColorTemp == nil && cmd.Color == nil && len(cmd.Gradient) == 0 {
+		return cmd
+	}
+
+	if cmd.Brightness != nil && o.BrightnessScale != nil {
+		brightness := *cmd.Brightness * *o.BrightnessScale
+		if brightness > 100 {
+			brightness = 100
+		}
+		cmd.Brightness = &brightness
+	}
+	if o.Brightness != nil {
+		brightness := *o.Brightness
+		cmd.Brightness = &brightness
+	}
+	if o.ColorTemp != nil && *o.ColorTemp > 0 {
+		// Overrides are in Kelvin, device commands in Mirek
+		mirek := 1000000 / *o.ColorTemp
+		cmd.ColorTemp = &mirek
+		cmd.Color = nil
+		cmd.Gradient = nil
+	}
+	return cmd
 }
 
 // itoa converts int to string (simple implementation to avoid import)
@@ -0,0 +1,590 @@
+package loxone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+const (
+	miniserverKeepalive  = time.Minute
+	miniserverTimeout    = 15 * time.Second
+	miniserverRetryDelay = 5 * time.Second
+)
+
+// MiniserverClient connects to the WebSocket API of a Loxone Miniserver,
+// follows the states of mapped controls and turns changes into commands
+type MiniserverClient struct {
+	host     string
+	username string
+	password string
+	mappings *MappingManager
+	handler  func(cmd *models.LoxoneCommand)
+
+	mu          sync.RWMutex
+	conn        *miniserverConn
+	token       *miniserverToken
+	structure   *Structure
+	states      map[string]controlState // State UUID -> control
+	values      map[string]float64
+	texts       map[string]string
+	connectedAt time.Time
+	lastError   string
+
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	retryDelay time.Duration
+}
+
+// controlState is a state of a structure control
+type controlState struct {
+	Control StructureControl
+	Name    string
+}
+
+// MiniserverStatus describes the Miniserver connection
+type MiniserverStatus struct {
+	Enabled     bool       `json:"enabled"`
+	Host        string     `json:"host"`
+	Connected   bool       `json:"connected"`
+	ConnectedAt *time.Time `json:"connected_at,omitempty"`
+	Miniserver  string     `json:"miniserver,omitempty"`
+	Controls    int        `json:"controls"` // Light controls of the structure file
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// NewMiniserverClient creates a client for the Miniserver at host
+func NewMiniserverClient(host, username, password string, mappings *MappingManager) *MiniserverClient {
+	return &MiniserverClient{
+		host:       host,
+		username:   username,
+		password:   password,
+		mappings:   mappings,
+		states:     make(map[string]controlState),
+		values:     make(map[string]float64),
+		texts:      make(map[string]string),
+		stopChan:   make(chan struct{}),
+		retryDelay: miniserverRetryDelay,
+	}
+}
+
+// SetHandler sets the function that executes commands for state changes
+func (c *MiniserverClient) SetHandler(handler func(cmd *models.LoxoneCommand)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// Start connects to the Miniserver and reconnects until ctx is done
+func (c *MiniserverClient) Start(ctx context.Context) {
+	go c.loop(ctx)
+}
+
+// Close stops the client
+func (c *MiniserverClient) Close() {
+	c.stopOnce.Do(func() { close(c.stopChan) })
+
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn != nil {
+		conn.ws.Close()
+	}
+}
+
+// Status returns the connection status
+func (c *MiniserverClient) Status() MiniserverStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := MiniserverStatus{Enabled: true, Host: c.host, Connected: c.conn != nil, LastError: c.lastError}
+	if c.conn != nil {
+		connectedAt := c.connectedAt
+		status.ConnectedAt = &connectedAt
+	}
+	if c.structure != nil {
+		status.Miniserver = c.structure.MiniserverName
+		status.Controls = len(c.structure.Controls)
+	}
+	return status
+}
+
+// Structure returns the structure file loaded on the last connect
+func (c *MiniserverClient) Structure() *Structure {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.structure
+}
+
+func (c *MiniserverClient) loop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		default:
+		}
+
+		if err := c.connect(ctx); err != nil {
+			c.mu.Lock()
+			c.lastError = err.Error()
+			c.mu.Unlock()
+			log.Error().Err(err).Str("miniserver", c.host).Msg("Miniserver connection error, reconnecting...")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopChan:
+			return
+		case <-time.After(c.retryDelay):
+		}
+	}
+}
+
+// connect runs one connection: key exchange, authentication, structure
+// download and the status update subscription
+func (c *MiniserverClient) connect(ctx context.Context) error {
+	dialer := websocket.Dialer{
+		HandshakeTimeout: miniserverTimeout,
+		Subprotocols:     []string{"remotecontrol"},
+	}
+	ws, _, err := dialer.DialContext(ctx, fmt.Sprintf("ws://%s/ws/rfc6455", c.host), nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	conn := &miniserverConn{ws: ws}
+	defer ws.Close()
+
+	value, err := conn.request("jdev/sys/getPublicKey")
+	if err != nil {
+		return err
+	}
+	var publicKey string
+	if err := json.Unmarshal(value, &publicKey); err != nil {
+		return fmt.Errorf("invalid public key response: %w", err)
+	}
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	session, sessionKey, err := newMiniserverSession(key)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.request("jdev/sys/keyexchange/" + sessionKey); err != nil {
+		return fmt.Errorf("key exchange failed: %w", err)
+	}
+
+	if err := c.authenticate(conn, session); err != nil {
+		return err
+	}
+
+	// Structure file with the state UUIDs of the controls
+	if err := conn.send("data/LoxAPP3.json"); err != nil {
+		return err
+	}
+	data, err := conn.readFile()
+	if err != nil {
+		return fmt.Errorf("failed to load structure file: %w", err)
+	}
+	structure, err := ParseStructure(data)
+	if err != nil {
+		return err
+	}
+	c.setStructure(structure)
+
+	if _, err := conn.request("jdev/sps/enablebinstatusupdate"); err != nil {
+		return fmt.Errorf("failed to enable status updates: %w", err)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.connectedAt = time.Now()
+	c.lastError = ""
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+	}()
+
+	log.Info().Str("miniserver", c.host).Str("name", structure.MiniserverName).Int("controls", len(structure.Controls)).Msg("Connected to Loxone Miniserver")
+
+	done := make(chan struct{})
+	defer close(done)
+	go c.keepalive(conn, done)
+
+	for {
+		msg, err := conn.read(miniserverKeepalive * 2)
+		if err != nil {
+			select {
+			case <-c.stopChan:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		switch msg.Identifier {
+		case msgValueEvents:
+			events, err := parseValueEvents(msg.Payload)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to parse Miniserver value events")
+				continue
+			}
+			for _, event := range events {
+				c.valueChanged(event.UUID, event.Value)
+			}
+		case msgTextEvents:
+			events, err := parseTextEvents(msg.Payload)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to parse Miniserver text events")
+				continue
+			}
+			for _, event := range events {
+				c.textChanged(event.UUID, event.Text)
+			}
+		case msgOutOfService:
+			return fmt.Errorf("miniserver out of service")
+		}
+	}
+}
+
+// authenticate reuses a valid token or requests a new one
+func (c *MiniserverClient) authenticate(conn *miniserverConn, session *miniserverSession) error {
+	c.mu.RLock()
+	token := c.token
+	c.mu.RUnlock()
+
+	if token.valid() {
+		err := c.authWithToken(conn, session, token)
+		if err == nil {
+			return nil
+		}
+		log.Debug().Err(err).Msg("Miniserver token rejected, requesting a new one")
+	}
+
+	token, err := c.acquireToken(conn, session)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MiniserverClient) keepalive(conn *miniserverConn, done chan struct{}) {
+	ticker := time.NewTicker(miniserverKeepalive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.send("keepalive"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// setStructure indexes the state UUIDs of the structure controls and resets
+// the known values, the first event of every state is the initial value
+func (c *MiniserverClient) setStructure(structure *Structure) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.structure = structure
+	c.states = make(map[string]controlState)
+	c.values = make(map[string]float64)
+	c.texts = make(map[string]string)
+	for _, control := range structure.Controls {
+		for name, stateUUID := range control.States {
+			c.states[stateUUID] = controlState{Control: control, Name: name}
+		}
+	}
+}
+
+func (c *MiniserverClient) valueChanged(stateUUID string, value float64) {
+	c.mu.Lock()
+	state, ok := c.states[stateUUID]
+	previous, known := c.values[stateUUID]
+	c.values[stateUUID] = value
+	handler := c.handler
+	c.mu.Unlock()
+
+	if !ok || !known || previous == value || handler == nil {
+		return
+	}
+	if cmd := c.valueCommand(state, value); cmd != nil {
+		c.dispatch(handler, cmd)
+	}
+}
+
+func (c *MiniserverClient) textChanged(stateUUID, text string) {
+	c.mu.Lock()
+	state, ok := c.states[stateUUID]
+	previous, known := c.texts[stateUUID]
+	c.texts[stateUUID] = text
+	handler := c.handler
+	c.mu.Unlock()
+
+	if !ok || !known || previous == text || handler == nil {
+		return
+	}
+	if cmd := c.textCommand(state, text); cmd != nil {
+		c.dispatch(handler, cmd)
+	}
+}
+
+// dispatch runs a command if the control has a mapping towards HUE
+func (c *MiniserverClient) dispatch(handler func(cmd *models.LoxoneCommand), cmd *models.LoxoneCommand) {
+	var mappings []models.Mapping
+	if cmd.Action == "mood" {
+		if c.mappings.MoodTable(cmd.Target) != nil {
+			c.run(handler, cmd)
+			return
+		}
+		mood, _ := cmd.Params["mood_number"].(int)
+		mappings = c.mappings.MoodMappings(cmd.Target, mood)
+	} else {
+		mappings = c.mappings.GetAllByLoxoneID(cmd.Target)
+	}
+
+	toHue := false
+	for _, m := range mappings {
+		toHue = toHue || SendsToHue(m.Direction)
+	}
+	if !toHue {
+		return
+	}
+	c.run(handler, cmd)
+}
+
+func (c *MiniserverClient) run(handler func(cmd *models.LoxoneCommand), cmd *models.LoxoneCommand) {
+	log.Debug().Str("target", cmd.Target).Str("action", cmd.Action).Interface("params", cmd.Params).Msg("Miniserver state changed")
+	handler(cmd)
+}
+
+// valueCommand translates the dimmer position into a set command
+func (c *MiniserverClient) valueCommand(state controlState, value float64) *models.LoxoneCommand {
+	if state.Control.Type != "Dimmer" || state.Name != "position" {
+		return nil
+	}
+
+	params := map[string]interface{}{"on": value > 0}
+	if value > 0 {
+		params["brightness"] = value
+	}
+	return &models.LoxoneCommand{Type: "command", Target: state.Control.UUID, Action: "set", Params: params}
+}
+
+// textCommand translates the color of a ColorPickerV2 and the active mood of
+// a LightControllerV2 into commands
+func (c *MiniserverClient) textCommand(state controlState, text string) *models.LoxoneCommand {
+	switch {
+	case state.Control.Type == "ColorPickerV2" && state.Name == "color":
+		params, ok := parsePickerColor(text)
+		if !ok {
+			return nil
+		}
+		return &models.LoxoneCommand{Type: "command", Target: state.Control.UUID, Action: "set", Params: params}
+
+	case state.Control.Type == "LightControllerV2" && state.Name == "activeMoods":
+		var moods []int
+		if err := json.Unmarshal([]byte(text), &moods); err != nil || len(moods) == 0 {
+			return nil
+		}
+		return &models.LoxoneCommand{Type: "command", Target: state.Control.UUID, Action: "mood", Params: map[string]interface{}{"mood_number": moods[0]}}
+	}
+	return nil
+}
+
+// parsePickerColor parses the ColorPickerV2 color state, either
+// "hsv(hue,saturation,value)" or "temp(brightness,kelvin)"
+func parsePickerColor(text string) (map[string]interface{}, bool) {
+	open, close := strings.Index(text, "("), strings.LastIndex(text, ")")
+	if open < 0 || close < open {
+		return nil, false
+	}
+	parts := strings.Split(text[open+1:close], ",")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, false
+	}
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = v
+	}
+
+	switch {
+	case strings.HasPrefix(text, "hsv") && len(values) == 3:
+		if values[2] <= 0 {
+			return map[string]interface{}{"on": false}, true
+		}
+		return map[string]interface{}{
+			"on":         true,
+			"brightness": values[2],
+			"color":      hsvToHex(values[0], values[1], 100),
+		}, true
+
+	case strings.HasPrefix(text, "temp") && len(values) == 2:
+		if values[0] <= 0 {
+			return map[string]interface{}{"on": false}, true
+		}
+		return map[string]interface{}{
+			"on":         true,
+			"brightness": values[0],
+			"color_temp": int(values[1]),
+		}, true
+	}
+	return nil, false
+}
+
+// hsvToHex converts hue 0-360, saturation and value 0-100 to a hex color
+func hsvToHex(h, s, v float64) string {
+	s, v = s/100, v/100
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	channel := func(v float64) int { return int(math.Round((v + m) * 255)) }
+	return fmt.Sprintf("#%02X%02X%02X", channel(r), channel(g), channel(b))
+}
+
+// miniserverConn reads the header/payload message pairs of a Miniserver connection
+type miniserverConn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+}
+
+// miniserverMessage is a message with its header identifier
+type miniserverMessage struct {
+	Identifier byte
+	Payload    []byte
+}
+
+func (c *miniserverConn) send(command string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(miniserverTimeout))
+	return c.ws.WriteMessage(websocket.TextMessage, []byte(command))
+}
+
+// read returns the next message. Keepalive and out of service messages have
+// no payload.
+func (c *miniserverConn) read(timeout time.Duration) (miniserverMessage, error) {
+	c.ws.SetReadDeadline(time.Now().Add(timeout))
+
+	var header msgHeader
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return miniserverMessage{}, err
+		}
+		header, err = parseHeader(data)
+		if err != nil {
+			return miniserverMessage{}, err
+		}
+		// An estimated header is followed by the exact one
+		if !header.Estimated {
+			break
+		}
+	}
+
+	msg := miniserverMessage{Identifier: header.Identifier}
+	if header.Identifier == msgKeepalive || header.Identifier == msgOutOfService {
+		return msg, nil
+	}
+
+	_, payload, err := c.ws.ReadMessage()
+	if err != nil {
+		return miniserverMessage{}, err
+	}
+	msg.Payload = payload
+	return msg, nil
+}
+
+// readFile waits for a file download
+func (c *miniserverConn) readFile() ([]byte, error) {
+	for {
+		msg, err := c.read(miniserverTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Identifier == msgText || msg.Identifier == msgBinaryFile {
+			return msg.Payload, nil
+		}
+	}
+}
+
+// request sends a command and returns the value of its LL response
+func (c *miniserverConn) request(command string) (json.RawMessage, error) {
+	if err := c.send(command); err != nil {
+		return nil, err
+	}
+
+	data, err := c.readFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		LL struct {
+			Control string          `json:"control"`
+			Value   json.RawMessage `json:"value"`
+			Code    json.RawMessage `json:"Code"` // String or number, "code" on some versions
+		} `json:"LL"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response to %s: %w", commandName(command), err)
+	}
+
+	code := strings.Trim(string(resp.LL.Code), `"`)
+	if code != strconv.Itoa(http.StatusOK) {
+		return nil, fmt.Errorf("%s failed with code %s", commandName(command), code)
+	}
+	return resp.LL.Value, nil
+}
+
+// commandName strips parameters and encrypted payloads from a command for logs
+func commandName(command string) string {
+	parts := strings.SplitN(command, "/", 4)
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,211 @@
+package loxone
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tokenPermissionApp requests a long lived token
+const tokenPermissionApp = 4
+
+// tokenEpoch is the reference of the Miniserver validUntil timestamps
+var tokenEpoch = time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// miniserverToken is a JSON web token issued by the Miniserver
+type miniserverToken struct {
+	Token      string
+	ValidUntil time.Time
+	HashAlg    string
+}
+
+func (t *miniserverToken) valid() bool {
+	return t != nil && time.Until(t.ValidUntil) > time.Minute
+}
+
+// miniserverSession holds the AES key exchanged with the Miniserver
+type miniserverSession struct {
+	key []byte
+	iv  []byte
+}
+
+// parsePublicKey parses the RSA key returned by jdev/sys/getPublicKey. The
+// Miniserver wraps a PKIX key in certificate markers without line breaks.
+func parsePublicKey(value string) (*rsa.PublicKey, error) {
+	value = strings.NewReplacer("-----BEGIN CERTIFICATE-----", "", "-----END CERTIFICATE-----", "", "\n", "", "\r", "").Replace(value)
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// newMiniserverSession creates a random AES-256 key and IV and returns the
+// RSA encrypted session key for jdev/sys/keyexchange
+func newMiniserverSession(publicKey *rsa.PublicKey) (*miniserverSession, string, error) {
+	s := &miniserverSession{key: make([]byte, 32), iv: make([]byte, aes.BlockSize)}
+	if _, err := rand.Read(s.key); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(s.iv); err != nil {
+		return nil, "", err
+	}
+
+	plain := hex.EncodeToString(s.key) + ":" + hex.EncodeToString(s.iv)
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, []byte(plain))
+	if err != nil {
+		return nil, "", err
+	}
+	return s, base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// encrypt wraps a command into jdev/sys/enc/ with a random salt. The
+// plaintext is zero padded to the AES block size.
+func (s *miniserverSession) encrypt(command string) (string, error) {
+	salt := make([]byte, 2)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	plain := []byte("salt/" + hex.EncodeToString(salt) + "/" + command + "\x00")
+	if rest := len(plain) % aes.BlockSize; rest != 0 {
+		plain = append(plain, bytes.Repeat([]byte{0}, aes.BlockSize-rest)...)
+	}
+
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return "", err
+	}
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, s.iv).CryptBlocks(encrypted, plain)
+
+	return "jdev/sys/enc/" + url.QueryEscape(base64.StdEncoding.EncodeToString(encrypted)), nil
+}
+
+func hashFunc(alg string) func() hash.Hash {
+	if strings.EqualFold(alg, "SHA256") {
+		return sha256.New
+	}
+	return sha1.New
+}
+
+// hmacHex returns the hex HMAC of message with the hex encoded key of getkey/getkey2
+func hmacHex(alg, hexKey, message string) (string, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid key: %w", err)
+	}
+	mac := hmac.New(hashFunc(alg), key)
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// userHash returns the credential hash for jdev/sys/getjwt
+func userHash(user, password string, salt miniserverUserSalt) (string, error) {
+	h := hashFunc(salt.HashAlg)()
+	h.Write([]byte(password + ":" + salt.Salt))
+	pwHash := strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	return hmacHex(salt.HashAlg, salt.Key, user+":"+pwHash)
+}
+
+// miniserverUserSalt is the value of jdev/sys/getkey2/<user>
+type miniserverUserSalt struct {
+	Key     string `json:"key"`
+	Salt    string `json:"salt"`
+	HashAlg string `json:"hashAlg"`
+}
+
+// clientUUID identifies the gateway towards the Miniserver, it stays the
+// same for a user so tokens are replaced instead of piling up
+func clientUUID(user string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("loxone2hue/"+user)).String()
+}
+
+// acquireToken authenticates with user and password and requests a token
+func (c *MiniserverClient) acquireToken(conn *miniserverConn, session *miniserverSession) (*miniserverToken, error) {
+	value, err := conn.request("jdev/sys/getkey2/" + url.PathEscape(c.username))
+	if err != nil {
+		return nil, err
+	}
+	var salt miniserverUserSalt
+	if err := json.Unmarshal(value, &salt); err != nil {
+		return nil, fmt.Errorf("invalid getkey2 response: %w", err)
+	}
+
+	hash, err := userHash(c.username, c.password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	command, err := session.encrypt(fmt.Sprintf("jdev/sys/getjwt/%s/%s/%d/%s/%s",
+		hash, url.PathEscape(c.username), tokenPermissionApp, clientUUID(c.username), "loxone2hue"))
+	if err != nil {
+		return nil, err
+	}
+	value, err = conn.request(command)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+
+	var result struct {
+		Token      string `json:"token"`
+		ValidUntil int64  `json:"validUntil"`
+	}
+	if err := json.Unmarshal(value, &result); err != nil || result.Token == "" {
+		return nil, fmt.Errorf("invalid token response")
+	}
+
+	return &miniserverToken{
+		Token:      result.Token,
+		ValidUntil: tokenEpoch.Add(time.Duration(result.ValidUntil) * time.Second),
+		HashAlg:    salt.HashAlg,
+	}, nil
+}
+
+// authWithToken authenticates a new connection with an existing token
+func (c *MiniserverClient) authWithToken(conn *miniserverConn, session *miniserverSession, token *miniserverToken) error {
+	value, err := conn.request("jdev/sys/getkey")
+	if err != nil {
+		return err
+	}
+	var key string
+	if err := json.Unmarshal(value, &key); err != nil {
+		return fmt.Errorf("invalid getkey response: %w", err)
+	}
+
+	hash, err := hmacHex(token.HashAlg, key, token.Token)
+	if err != nil {
+		return err
+	}
+	command, err := session.encrypt("authwithtoken/" + hash + "/" + url.PathEscape(c.username))
+	if err != nil {
+		return err
+	}
+	_, err = conn.request(command)
+	return err
+}
@@ -0,0 +1,103 @@
+package loxone
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Message identifiers of the Miniserver binary message header
+const (
+	msgText           byte = 0
+	msgBinaryFile     byte = 1
+	msgValueEvents    byte = 2
+	msgTextEvents     byte = 3
+	msgDaytimerEvents byte = 4
+	msgOutOfService   byte = 5
+	msgKeepalive      byte = 6
+	msgWeatherEvents  byte = 7
+)
+
+// msgHeader is the 8 byte header the Miniserver sends before every message
+type msgHeader struct {
+	Identifier byte
+	Estimated  bool // Length is an estimate, the exact header follows
+	Length     uint32
+}
+
+// valueEvent is an entry of a value event table
+type valueEvent struct {
+	UUID  string
+	Value float64
+}
+
+// textEvent is an entry of a text event table
+type textEvent struct {
+	UUID string
+	Icon string
+	Text string
+}
+
+func parseHeader(data []byte) (msgHeader, error) {
+	if len(data) != 8 || data[0] != 0x03 {
+		return msgHeader{}, fmt.Errorf("invalid message header")
+	}
+	return msgHeader{
+		Identifier: data[1],
+		Estimated:  data[2]&0x80 != 0,
+		Length:     binary.LittleEndian.Uint32(data[4:8]),
+	}, nil
+}
+
+// formatUUID formats a binary Miniserver UUID, e.g. 0f1e2d3c-0123-4567-89abcdef01234567
+func formatUUID(data []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x",
+		binary.LittleEndian.Uint32(data[0:4]),
+		binary.LittleEndian.Uint16(data[4:6]),
+		binary.LittleEndian.Uint16(data[6:8]),
+		data[8:16])
+}
+
+// parseValueEvents parses a value event table (UUID + float64 per entry)
+func parseValueEvents(data []byte) ([]valueEvent, error) {
+	if len(data)%24 != 0 {
+		return nil, fmt.Errorf("invalid value event table length %d", len(data))
+	}
+
+	events := make([]valueEvent, 0, len(data)/24)
+	for i := 0; i < len(data); i += 24 {
+		events = append(events, valueEvent{
+			UUID:  formatUUID(data[i : i+16]),
+			Value: math.Float64frombits(binary.LittleEndian.Uint64(data[i+16 : i+24])),
+		})
+	}
+	return events, nil
+}
+
+// parseTextEvents parses a text event table. Every entry holds the UUID, the
+// icon UUID and a text padded to a multiple of 4 bytes.
+func parseTextEvents(data []byte) ([]textEvent, error) {
+	events := make([]textEvent, 0)
+	for i := 0; i < len(data); {
+		if len(data)-i < 36 {
+			return nil, fmt.Errorf("truncated text event")
+		}
+		length := int(binary.LittleEndian.Uint32(data[i+32 : i+36]))
+		start := i + 36
+		if length > len(data)-start {
+			return nil, fmt.Errorf("truncated text event")
+		}
+
+		events = append(events, textEvent{
+			UUID: formatUUID(data[i : i+16]),
+			Icon: formatUUID(data[i+16 : i+32]),
+			Text: string(data[start : start+length]),
+		})
+
+		i = start + length
+		if rest := i % 4; rest != 0 {
+			i += 4 - rest
+		}
+	}
+	return events, nil
+}
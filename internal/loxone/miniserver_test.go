@@ -0,0 +1,179 @@
+package loxone
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sbeyeler/loxone2hue/internal/loxone/loxonetest"
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// UUIDs of loxonetest.SampleStructure
+const (
+	simController  = "0b5a1c2d-0010-0001-ffff000000000001"
+	simActiveMoods = "0b5a1c2d-0010-0002-ffff000000000001"
+	simDimmer      = "0b5a1c2d-0011-0001-ffff000000000001"
+	simPosition    = "0b5a1c2d-0011-0002-ffff000000000001"
+	simPicker      = "0b5a1c2d-0012-0001-ffff000000000001"
+	simColor       = "0b5a1c2d-0012-0002-ffff000000000001"
+)
+
+// startSimulator runs a simulated Miniserver and a client connected to it
+// that reports its commands on the returned channel
+func startSimulator(t *testing.T, password string) (*loxonetest.Simulator, *MiniserverClient, chan *models.LoxoneCommand) {
+	t.Helper()
+
+	sim, err := loxonetest.NewSimulator("admin", "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(sim.Handler())
+	t.Cleanup(server.Close)
+
+	mappings := NewMappingManager()
+	mappings.Load([]models.Mapping{
+		{ID: "dimmer", LoxoneID: simDimmer, HueID: "light-1", HueType: "light", Enabled: true},
+		{ID: "picker", LoxoneID: simPicker, HueID: "light-2", HueType: "light", Enabled: true},
+		{ID: "room", LoxoneID: simController, HueID: "room-1", HueType: "group", Enabled: true},
+		{ID: "mood", LoxoneID: simController + "_mood_1", HueID: "scene-1", HueType: "scene", Enabled: true},
+	})
+
+	commands := make(chan *models.LoxoneCommand, 10)
+	client := NewMiniserverClient(strings.TrimPrefix(server.URL, "http://"), "admin", password, mappings)
+	client.retryDelay = 10 * time.Millisecond
+	client.SetHandler(func(cmd *models.LoxoneCommand) { commands <- cmd })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		client.Close()
+	})
+	client.Start(ctx)
+
+	return sim, client, commands
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func nextCommand(t *testing.T, commands chan *models.LoxoneCommand) *models.LoxoneCommand {
+	t.Helper()
+	select {
+	case cmd := <-commands:
+		return cmd
+	case <-time.After(5 * time.Second):
+		t.Fatal("no command received")
+		return nil
+	}
+}
+
+func TestMiniserverClientStateChanges(t *testing.T) {
+	sim, client, commands := startSimulator(t, "secret")
+	waitFor(t, "connection", func() bool { return client.Status().Connected })
+
+	status := client.Status()
+	if status.Miniserver != "Simulator" || status.LastError != "" {
+		t.Fatalf("status = %+v", status)
+	}
+
+	sim.SetValue(simPosition, 80)
+	cmd := nextCommand(t, commands)
+	if cmd.Target != simDimmer || cmd.Action != "set" || cmd.Params["brightness"] != 80.0 || cmd.Params["on"] != true {
+		t.Fatalf("dimmer command = %+v", cmd)
+	}
+
+	sim.SetText(simColor, "hsv(120,100,50)")
+	cmd = nextCommand(t, commands)
+	if cmd.Target != simPicker || cmd.Params["color"] != "#00FF00" || cmd.Params["brightness"] != 50.0 {
+		t.Fatalf("color command = %+v", cmd)
+	}
+
+	sim.SetText(simActiveMoods, "[1]")
+	cmd = nextCommand(t, commands)
+	if cmd.Target != simController || cmd.Action != "mood" || cmd.Params["mood_number"] != 1 {
+		t.Fatalf("mood command = %+v", cmd)
+	}
+
+	sim.SetText(simActiveMoods, "[778]")
+	cmd = nextCommand(t, commands)
+	if cmd.Action != "mood" || cmd.Params["mood_number"] != models.MoodAllOff {
+		t.Fatalf("off mood command = %+v", cmd)
+	}
+
+	// Unchanged values do not repeat commands
+	sim.SetValue(simPosition, 80)
+	select {
+	case cmd := <-commands:
+		t.Fatalf("unexpected command %+v", cmd)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMiniserverClientReconnectsWithToken(t *testing.T) {
+	sim, client, commands := startSimulator(t, "secret")
+	waitFor(t, "connection", func() bool { return client.Status().Connected })
+	first := client.Status().ConnectedAt
+
+	sim.DropConnections()
+	waitFor(t, "reconnect", func() bool {
+		status := client.Status()
+		return status.Connected && status.ConnectedAt.After(*first)
+	})
+
+	// The token of the first connection is reused
+	if issued := sim.TokensIssued(); issued != 1 {
+		t.Fatalf("tokens issued = %d, want 1", issued)
+	}
+
+	// The states after a reconnect are initial values again
+	sim.SetValue(simPosition, 30)
+	if cmd := nextCommand(t, commands); cmd.Params["brightness"] != 30.0 {
+		t.Fatalf("command after reconnect = %+v", cmd)
+	}
+}
+
+func TestMiniserverClientWrongPassword(t *testing.T) {
+	sim, client, _ := startSimulator(t, "wrong")
+	waitFor(t, "authentication error", func() bool { return client.Status().LastError != "" })
+
+	if status := client.Status(); status.Connected || !strings.Contains(status.LastError, "401") {
+		t.Fatalf("status = %+v", status)
+	}
+	if sim.TokensIssued() != 0 {
+		t.Fatal("token issued for a wrong password")
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	values, err := parseValueEvents(loxonetest.EncodeValueEvents(map[string]float64{simPosition: 42.5}))
+	if err != nil || len(values) != 1 || values[0].UUID != simPosition || values[0].Value != 42.5 {
+		t.Fatalf("value events = %+v, %v", values, err)
+	}
+
+	// Texts are padded to multiples of 4 bytes
+	texts := map[string]string{simColor: "hsv(1,2,3)", simActiveMoods: "[778]"}
+	events, err := parseTextEvents(loxonetest.EncodeTextEvents(texts))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("text events = %+v, %v", events, err)
+	}
+	for _, event := range events {
+		if texts[event.UUID] != event.Text {
+			t.Fatalf("text of %s = %q, want %q", event.UUID, event.Text, texts[event.UUID])
+		}
+	}
+
+	if _, err := parseValueEvents(make([]byte, 10)); err == nil {
+		t.Fatal("truncated value events accepted")
+	}
+}
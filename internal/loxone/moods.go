@@ -0,0 +1,127 @@
+package loxone
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// LoadMoodTables replaces the mood tables, later tables with the same
+// target are ignored
+func (m *MappingManager) LoadMoodTables(tables []models.MoodTable) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.moodTables = make(map[string]*models.MoodTable, len(tables))
+	for i := range tables {
+		if _, exists := m.moodTables[tables[i].Target]; exists {
+			continue
+		}
+		table := copyMoodTable(tables[i])
+		m.moodTables[table.Target] = &table
+	}
+}
+
+// MoodTable returns the mood table of a Loxone target or nil
+func (m *MappingManager) MoodTable(target string) *models.MoodTable {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	table, ok := m.moodTables[target]
+	if !ok {
+		return nil
+	}
+	result := copyMoodTable(*table)
+	return &result
+}
+
+// MoodTables returns all mood tables sorted by target
+func (m *MappingManager) MoodTables() []models.MoodTable {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]models.MoodTable, 0, len(m.moodTables))
+	for _, table := range m.moodTables {
+		result = append(result, copyMoodTable(*table))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Target < result[j].Target })
+	return result
+}
+
+func copyMoodTable(table models.MoodTable) models.MoodTable {
+	table.Moods = append([]models.MoodEntry(nil), table.Moods...)
+	return table
+}
+
+// MoodEntry returns the entry of a mood number or nil
+func MoodEntry(table *models.MoodTable, number int) *models.MoodEntry {
+	for i := range table.Moods {
+		if table.Moods[i].Number == number {
+			return &table.Moods[i]
+		}
+	}
+	return nil
+}
+
+// MigrateMoodMappings turns enabled <target>_mood_<n> scene mappings into
+// mood tables. The base mapping of the target becomes the off target of the
+// table. Moods already in a table are kept and their mappings stay.
+// Returns the updated tables, the remaining mappings and the number of
+// migrated moods.
+func MigrateMoodMappings(mappings []models.Mapping, tables []models.MoodTable) ([]models.MoodTable, []models.Mapping, int) {
+	result := make([]models.MoodTable, 0, len(tables))
+	byTarget := make(map[string]int)
+	for _, table := range tables {
+		byTarget[table.Target] = len(result)
+		result = append(result, copyMoodTable(table))
+	}
+
+	base := make(map[string]models.Mapping)
+	for _, m := range mappings {
+		if _, ok := base[m.LoxoneID]; !ok && IsStatusTarget(m.HueType) {
+			base[m.LoxoneID] = m
+		}
+	}
+
+	remaining := make([]models.Mapping, 0, len(mappings))
+	migrated := 0
+	for _, m := range mappings {
+		match := MoodPattern.FindStringSubmatch(m.LoxoneID)
+		if match == nil || m.HueType != "scene" || !m.Enabled {
+			remaining = append(remaining, m)
+			continue
+		}
+		target := match[1]
+		number, _ := strconv.Atoi(match[2])
+		if models.IsMoodOff(number) || number == models.MoodAllOn {
+			remaining = append(remaining, m)
+			continue
+		}
+
+		i, ok := byTarget[target]
+		if !ok {
+			table := models.MoodTable{Target: target, Bridge: m.Bridge, Moods: []models.MoodEntry{}}
+			if b, ok := base[target]; ok && b.HueType != "virtual_group" {
+				table.Name, table.HueID, table.HueType, table.Bridge = b.Name, b.HueID, b.HueType, b.Bridge
+			}
+			i = len(result)
+			byTarget[target] = i
+			result = append(result, table)
+		}
+
+		// Scenes of another bridge or moods already in the table stay mappings
+		if result[i].Bridge != m.Bridge || MoodEntry(&result[i], number) != nil {
+			remaining = append(remaining, m)
+			continue
+		}
+
+		result[i].Moods = append(result[i].Moods, models.MoodEntry{Number: number, Name: m.Name, SceneID: m.HueID})
+		migrated++
+	}
+
+	for i := range result {
+		sort.Slice(result[i].Moods, func(a, b int) bool { return result[i].Moods[a].Number < result[i].Moods[b].Number })
+	}
+	return result, remaining, migrated
+}
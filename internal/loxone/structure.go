@@ -0,0 +1,304 @@
+package loxone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// Control types of the structure file that can be mapped to HUE
+var structureControlTypes = map[string]bool{
+	"LightControllerV2": true,
+	"Dimmer":            true,
+	"ColorPickerV2":     true,
+}
+
+// Structure is the parsed part of a Miniserver structure file (LoxAPP3.json)
+type Structure struct {
+	MiniserverName string             `json:"miniserver_name"`
+	ProjectName    string             `json:"project_name"`
+	LastModified   string             `json:"last_modified,omitempty"`
+	Rooms          []string           `json:"rooms"`
+	Categories     []string           `json:"categories"`
+	Controls       []StructureControl `json:"controls"`
+}
+
+// StructureControl is a light related control of the structure file
+type StructureControl struct {
+	UUID     string            `json:"uuid"` // uuidAction, used as Loxone ID
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Room     string            `json:"room,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Parent   string            `json:"parent,omitempty"` // UUID of the LightControllerV2 for sub controls
+	States   map[string]string `json:"states,omitempty"` // State name -> state UUID, e.g. "position"
+}
+
+// loxAppFile mirrors the fields of LoxAPP3.json used by the gateway
+type loxAppFile struct {
+	LastModified string `json:"lastModified"`
+	MsInfo       struct {
+		MsName      string `json:"msName"`
+		ProjectName string `json:"projectName"`
+	} `json:"msInfo"`
+	Rooms map[string]struct {
+		Name string `json:"name"`
+	} `json:"rooms"`
+	Cats map[string]struct {
+		Name string `json:"name"`
+	} `json:"cats"`
+	Controls map[string]loxAppControl `json:"controls"`
+}
+
+type loxAppControl struct {
+	Name        string                     `json:"name"`
+	Type        string                     `json:"type"`
+	UUIDAction  string                     `json:"uuidAction"`
+	Room        string                     `json:"room"`
+	Cat         string                     `json:"cat"`
+	SubControls map[string]loxAppControl   `json:"subControls"`
+	States      map[string]json.RawMessage `json:"states"`
+}
+
+// ParseStructure parses a LoxAPP3.json file and keeps the rooms, categories
+// and the LightControllerV2, Dimmer and ColorPickerV2 controls
+func ParseStructure(data []byte) (*Structure, error) {
+	var file loxAppFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid structure file: %w", err)
+	}
+	if file.Controls == nil {
+		return nil, fmt.Errorf("invalid structure file: no controls")
+	}
+
+	s := &Structure{
+		MiniserverName: file.MsInfo.MsName,
+		ProjectName:    file.MsInfo.ProjectName,
+		LastModified:   file.LastModified,
+		Rooms:          make([]string, 0, len(file.Rooms)),
+		Categories:     make([]string, 0, len(file.Cats)),
+		Controls:       make([]StructureControl, 0),
+	}
+	for _, room := range file.Rooms {
+		s.Rooms = append(s.Rooms, room.Name)
+	}
+	for _, cat := range file.Cats {
+		s.Categories = append(s.Categories, cat.Name)
+	}
+
+	var add func(uuid string, c loxAppControl, parent, room, cat string)
+	add = func(uuid string, c loxAppControl, parent, room, cat string) {
+		// Sub controls inherit room and category of their parent
+		if c.Room != "" {
+			room = file.Rooms[c.Room].Name
+		}
+		if c.Cat != "" {
+			cat = file.Cats[c.Cat].Name
+		}
+		if c.UUIDAction != "" {
+			uuid = c.UUIDAction
+		}
+
+		if structureControlTypes[c.Type] {
+			// States are UUIDs, some controls also list UUID arrays
+			states := make(map[string]string, len(c.States))
+			for name, raw := range c.States {
+				var stateUUID string
+				if json.Unmarshal(raw, &stateUUID) == nil {
+					states[name] = stateUUID
+				}
+			}
+			s.Controls = append(s.Controls, StructureControl{
+				UUID:     uuid,
+				Name:     c.Name,
+				Type:     c.Type,
+				Room:     room,
+				Category: cat,
+				Parent:   parent,
+				States:   states,
+			})
+		}
+		for subUUID, sub := range c.SubControls {
+			add(subUUID, sub, uuid, room, cat)
+		}
+	}
+	for uuid, c := range file.Controls {
+		add(uuid, c, "", "", "")
+	}
+
+	sort.Strings(s.Rooms)
+	sort.Strings(s.Categories)
+	sort.Slice(s.Controls, func(i, j int) bool {
+		a, b := s.Controls[i], s.Controls[j]
+		if a.Room != b.Room {
+			return a.Room < b.Room
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.UUID < b.UUID
+	})
+
+	return s, nil
+}
+
+// FetchStructure downloads LoxAPP3.json from a Miniserver
+func FetchStructure(miniserverIP, username, password string) ([]byte, error) {
+	if miniserverIP == "" {
+		return nil, fmt.Errorf("miniserver_ip not configured")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/data/LoxAPP3.json", miniserverIP), nil)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch structure file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("miniserver returned %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// StructureMatch is a proposed mapping for a structure control
+type StructureMatch struct {
+	Control StructureControl `json:"control"`
+	Mapping models.Mapping   `json:"mapping"`
+	Score   float64          `json:"score"` // Name similarity 0-1
+}
+
+// MatchStructure proposes mappings by comparing control names with HUE
+// names. LightControllerV2 controls are matched against rooms and zones,
+// Dimmer and ColorPickerV2 controls against lights. Controls that already
+// have a mapping and matches below minScore are left out.
+func MatchStructure(s *Structure, groups []*models.Group, lights []*models.Light, existing []models.Mapping, minScore float64, bridge string) []StructureMatch {
+	mapped := make(map[string]bool)
+	for _, m := range existing {
+		mapped[m.LoxoneID] = true
+	}
+
+	result := make([]StructureMatch, 0)
+	for _, control := range s.Controls {
+		if mapped[control.UUID] {
+			continue
+		}
+
+		names := []string{control.Name, strings.TrimSpace(control.Room + " " + control.Name)}
+		best := StructureMatch{Control: control}
+
+		if control.Type == "LightControllerV2" {
+			// Light controllers usually carry a generic name, the room decides
+			names = append(names, control.Room)
+			for _, group := range groups {
+				if score := bestSimilarity(names, group.Name); score > best.Score {
+					best.Score = score
+					best.Mapping = models.Mapping{HueID: group.ID, HueType: "group", Name: group.Name}
+				}
+			}
+		} else {
+			for _, light := range lights {
+				if score := bestSimilarity(names, light.Name); score > best.Score {
+					best.Score = score
+					best.Mapping = models.Mapping{HueID: light.ID, HueType: "light", Name: light.Name}
+				}
+			}
+		}
+
+		if best.Score < minScore || best.Mapping.HueID == "" {
+			continue
+		}
+
+		best.Mapping.LoxoneID = control.UUID
+		best.Mapping.Name = strings.TrimSpace(control.Room + " " + control.Name)
+		best.Mapping.Bridge = bridge
+		best.Mapping.Enabled = true
+		best.Mapping.Description = fmt.Sprintf("Loxone %s matched to HUE %s (%.0f%%)", control.Type, best.Mapping.HueType, best.Score*100)
+		result = append(result, best)
+	}
+
+	return result
+}
+
+func bestSimilarity(names []string, target string) float64 {
+	best := 0.0
+	for _, name := range names {
+		if score := Similarity(name, target); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// Similarity compares two names after normalization and returns a score
+// between 0 and 1. Names whose words are all contained in the other name
+// score at least 0.9.
+func Similarity(a, b string) float64 {
+	a, b = Slugify(a), Slugify(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	score := 1 - float64(levenshtein(a, b))/float64(max(len(a), len(b)))
+
+	wordsA, wordsB := strings.Split(a, "_"), strings.Split(b, "_")
+	if len(wordsA) > len(wordsB) {
+		wordsA, wordsB = wordsB, wordsA
+	}
+	if containsAll(wordsB, wordsA) && score < 0.9 {
+		score = 0.9
+	}
+	return score
+}
+
+func containsAll(words, subset []string) bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	for _, w := range subset {
+		if !set[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// levenshtein returns the edit distance of two strings
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
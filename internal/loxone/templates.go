@@ -0,0 +1,256 @@
+package loxone
+
+import (
+	"encoding/xml"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// MoodPattern matches <target>_mood_<n> Loxone IDs
+var MoodPattern = regexp.MustCompile(`^(.+)_mood_(\d+)$`)
+
+// Loxone Config template types and the minimum Config version they import into
+const (
+	templateTypeVirtualIn  = "2"
+	templateTypeVirtualOut = "3"
+	templateMinVersion     = "16011106"
+)
+
+type templateInfo struct {
+	TemplateType string `xml:"templateType,attr"`
+	MinVersion   string `xml:"minVersion,attr"`
+}
+
+// virtualOut is a Loxone Config Virtual Output template
+type virtualOut struct {
+	XMLName        xml.Name        `xml:"VirtualOut"`
+	Title          string          `xml:"Title,attr"`
+	Comment        string          `xml:"Comment,attr"`
+	Address        string          `xml:"Address,attr"`
+	CmdInit        string          `xml:"CmdInit,attr"`
+	CloseAfterSend string          `xml:"CloseAfterSend,attr"`
+	CmdSep         string          `xml:"CmdSep,attr"`
+	Info           templateInfo    `xml:"Info"`
+	Commands       []virtualOutCmd `xml:"VirtualOutCmd"`
+}
+
+type virtualOutCmd struct {
+	Title        string `xml:"Title,attr"`
+	Comment      string `xml:"Comment,attr"`
+	CmdOnMethod  string `xml:"CmdOnMethod,attr"`
+	CmdOffMethod string `xml:"CmdOffMethod,attr"`
+	CmdOn        string `xml:"CmdOn,attr"`
+	CmdOnHTTP    string `xml:"CmdOnHTTP,attr"`
+	CmdOnPost    string `xml:"CmdOnPost,attr"`
+	CmdOff       string `xml:"CmdOff,attr"`
+	CmdOffHTTP   string `xml:"CmdOffHTTP,attr"`
+	CmdOffPost   string `xml:"CmdOffPost,attr"`
+	CmdAnswer    string `xml:"CmdAnswer,attr"`
+	HintText     string `xml:"HintText,attr"`
+	Analog       bool   `xml:"Analog,attr"`
+	Repeat       int    `xml:"Repeat,attr"`
+	RepeatRate   int    `xml:"RepeatRate,attr"`
+}
+
+// virtualInHTTP is a Loxone Config Virtual HTTP Input template
+type virtualInHTTP struct {
+	XMLName     xml.Name           `xml:"VirtualInHttp"`
+	Title       string             `xml:"Title,attr"`
+	Comment     string             `xml:"Comment,attr"`
+	Address     string             `xml:"Address,attr"`
+	PollingTime int                `xml:"PollingTime,attr"`
+	Info        templateInfo       `xml:"Info"`
+	Commands    []virtualInHTTPCmd `xml:"VirtualInHttpCmd"`
+}
+
+type virtualInHTTPCmd struct {
+	Title         string `xml:"Title,attr"`
+	Comment       string `xml:"Comment,attr"`
+	Check         string `xml:"Check,attr"`
+	Signed        bool   `xml:"Signed,attr"`
+	Analog        bool   `xml:"Analog,attr"`
+	SourceValLow  int    `xml:"SourceValLow,attr"`
+	DestValLow    int    `xml:"DestValLow,attr"`
+	SourceValHigh int    `xml:"SourceValHigh,attr"`
+	DestValHigh   int    `xml:"DestValHigh,attr"`
+	DefVal        int    `xml:"DefVal,attr"`
+	MinVal        int    `xml:"MinVal,attr"`
+	MaxVal        int    `xml:"MaxVal,attr"`
+}
+
+// commandURL builds the /ws?cmd= path of a text command, <v> stays the
+// Loxone value placeholder
+func commandURL(parts ...string) string {
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		// Keep the value placeholder Loxone replaces
+		escaped[i] = strings.ReplaceAll(url.QueryEscape(part), url.QueryEscape("<v>"), "<v>")
+	}
+	return "/ws?cmd=" + strings.Join(escaped, "%20")
+}
+
+// withToken appends the API token query parameter to a template path when
+// authentication is active
+func withToken(path, token string) string {
+	if path == "" || token == "" {
+		return path
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "token=" + url.QueryEscape(token)
+}
+
+// templateTargets groups enabled mappings by Loxone ID in list order
+func templateTargets(mappings []models.Mapping) ([]string, map[string][]models.Mapping) {
+	order := make([]string, 0)
+	byID := make(map[string][]models.Mapping)
+	for _, m := range mappings {
+		if !m.Enabled || m.LoxoneID == "" {
+			continue
+		}
+		if _, ok := byID[m.LoxoneID]; !ok {
+			order = append(order, m.LoxoneID)
+		}
+		byID[m.LoxoneID] = append(byID[m.LoxoneID], m)
+	}
+	return order, byID
+}
+
+func templateTitle(m models.Mapping) string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.LoxoneID
+}
+
+// OutputTemplate builds a Virtual Output template with commands for every
+// mapping and mood table. address is the gateway base URL, e.g.
+// http://192.168.1.10:8080, token the API token added to every command.
+func OutputTemplate(mappings []models.Mapping, tables []models.MoodTable, address, token string) ([]byte, error) {
+	out := virtualOut{
+		Title:          "Loxone2HUE",
+		Comment:        "Generated by Loxone2HUE Gateway",
+		Address:        address,
+		CloseAfterSend: "true",
+		Info:           templateInfo{TemplateType: templateTypeVirtualOut, MinVersion: templateMinVersion},
+		Commands:       make([]virtualOutCmd, 0),
+	}
+
+	digital := func(title, on, off, hint string) {
+		out.Commands = append(out.Commands, virtualOutCmd{Title: title, CmdOnMethod: "GET", CmdOffMethod: "GET", CmdOn: withToken(on, token), CmdOff: withToken(off, token), HintText: hint})
+	}
+	analog := func(title, cmd, hint string) {
+		out.Commands = append(out.Commands, virtualOutCmd{Title: title, CmdOnMethod: "GET", CmdOffMethod: "GET", CmdOn: withToken(cmd, token), HintText: hint, Analog: true})
+	}
+
+	order, byID := templateTargets(mappings)
+	moods := make(map[string]bool)
+
+	for _, id := range order {
+		first := byID[id][0]
+		title := templateTitle(first)
+		if !SendsToHue(first.Direction) {
+			continue
+		}
+
+		// <target>_mood_<n> mappings are switched through one MOOD command per target
+		if match := MoodPattern.FindStringSubmatch(id); match != nil {
+			target := match[1]
+			if !moods[target] {
+				moods[target] = true
+				moodTitle := target
+				if base, ok := byID[target]; ok {
+					moodTitle = templateTitle(base[0])
+				}
+				analog(moodTitle+" Stimmung", commandURL("MOOD", target, "<v>"), "Stimmung 0 und 778 = Aus, 777 = Viel Licht, 1..n = Szene")
+			}
+			continue
+		}
+
+		switch first.HueType {
+		case "light", "group", "virtual_group":
+			digital(title+" Ein/Aus", commandURL("SET", id, "ON"), commandURL("SET", id, "OFF"), "")
+			analog(title+" Helligkeit", commandURL("SET", id, "BRI", "<v>"), "Helligkeit 0-100")
+			analog(title+" Farbtemperatur", commandURL("SET", id, "CT", "<v>"), "Farbtemperatur in Kelvin")
+			analog(title+" Farbe", commandURL("SET", id, "COLOR", "rgb:<v>"), "Loxone RGB-Wert")
+		case "scene":
+			digital(title+" Szene", commandURL("SCENE", id), "", "")
+		case "entertainment":
+			digital(title+" Stream", commandURL("STREAM", id, "rainbow"), commandURL("STREAM", id, "OFF"), "")
+		}
+	}
+
+	for _, table := range tables {
+		if moods[table.Target] {
+			continue
+		}
+		moods[table.Target] = true
+		title := table.Name
+		if title == "" {
+			title = table.Target
+		}
+		analog(title+" Stimmung", commandURL("MOOD", table.Target, "<v>"), "Stimmung 0 und 778 = Aus, 777 = Viel Licht")
+	}
+
+	return marshalTemplate(out)
+}
+
+// InputTemplate builds a Virtual HTTP Input template that polls the state of
+// every light, group and virtual group mapping from statusPath, token is the
+// API token added to the status URL
+func InputTemplate(mappings []models.Mapping, address, statusPath, token string) ([]byte, error) {
+	in := virtualInHTTP{
+		Title:       "Loxone2HUE Status",
+		Comment:     "Generated by Loxone2HUE Gateway",
+		Address:     address + withToken(statusPath, token),
+		PollingTime: 10,
+		Info:        templateInfo{TemplateType: templateTypeVirtualIn, MinVersion: templateMinVersion},
+		Commands:    make([]virtualInHTTPCmd, 0),
+	}
+
+	cmd := func(title, check string, high int) virtualInHTTPCmd {
+		return virtualInHTTPCmd{
+			Title:         title,
+			Check:         check,
+			Signed:        true,
+			Analog:        true,
+			SourceValHigh: high,
+			DestValHigh:   high,
+			MinVal:        -2147483648,
+			MaxVal:        2147483647,
+		}
+	}
+
+	order, byID := templateTargets(mappings)
+	for _, id := range order {
+		first := byID[id][0]
+		if !IsStatusTarget(first.HueType) || !SendsToLoxone(first.Direction) || MoodPattern.MatchString(id) {
+			continue
+		}
+		title := templateTitle(first)
+		in.Commands = append(in.Commands,
+			cmd(title+" Status", `\i[`+id+`].on=\i\v`, 1),
+			cmd(title+" Helligkeit", `\i[`+id+`].brightness=\i\v`, 100),
+		)
+	}
+
+	return marshalTemplate(in)
+}
+
+// IsStatusTarget reports whether a mapping type has an on/brightness state
+func IsStatusTarget(hueType string) bool {
+	return hueType == "light" || hueType == "group" || hueType == "virtual_group"
+}
+
+func marshalTemplate(v interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,120 @@
+package loxone
+
+import (
+	"math"
+
+	"github.com/sbeyeler/loxone2hue/internal/models"
+)
+
+// transformRange holds a transform with defaults applied
+type transformRange struct {
+	inMin, inMax   float64
+	outMin, outMax float64
+	gamma          float64
+}
+
+func rangeOf(t *models.MappingTransform) transformRange {
+	r := transformRange{
+		inMin:  t.InputMin,
+		inMax:  t.InputMax,
+		outMin: t.OutputMin,
+		outMax: t.OutputMax,
+		gamma:  t.Gamma,
+	}
+	if r.inMax == r.inMin {
+		r.inMin, r.inMax = 0, 100
+	}
+	if r.outMax == 0 {
+		r.outMax = 100
+	}
+	if r.gamma <= 0 {
+		r.gamma = 1
+	}
+	return r
+}
+
+// TransformBrightness converts a Loxone value into a HUE brightness in percent
+func TransformBrightness(value float64, t *models.MappingTransform) float64 {
+	if t == nil {
+		return value
+	}
+	r := rangeOf(t)
+
+	n := clampUnit((value - r.inMin) / (r.inMax - r.inMin))
+	if t.Invert {
+		n = 1 - n
+	}
+	n = math.Pow(n, r.gamma)
+
+	brightness := r.outMin + n*(r.outMax-r.outMin)
+	if brightness > 0 && brightness < t.MinOnBrightness {
+		brightness = t.MinOnBrightness
+	}
+	return math.Max(0, math.Min(100, brightness))
+}
+
+// ReverseBrightness converts a HUE brightness back into the Loxone value range
+func ReverseBrightness(brightness float64, t *models.MappingTransform) float64 {
+	if t == nil {
+		return brightness
+	}
+	r := rangeOf(t)
+
+	n := 0.0
+	if r.outMax != r.outMin {
+		n = clampUnit((brightness - r.outMin) / (r.outMax - r.outMin))
+	}
+	n = math.Pow(n, 1/r.gamma)
+	if t.Invert {
+		n = 1 - n
+	}
+	return r.inMin + n*(r.inMax-r.inMin)
+}
+
+// ApplyTransform applies a mapping transform to a device command
+func ApplyTransform(cmd models.DeviceCommand, t *models.MappingTransform) models.DeviceCommand {
+	if t == nil {
+		return cmd
+	}
+
+	if cmd.Brightness != nil {
+		brightness := TransformBrightness(*cmd.Brightness, t)
+		cmd.Brightness = &brightness
+	}
+
+	if cmd.ColorTemp != nil && *cmd.ColorTemp > 0 {
+		// Limits are in Kelvin, device commands in Mirek
+		kelvin := 1000000 / *cmd.ColorTemp
+		if t.ColorTempMin > 0 && kelvin < t.ColorTempMin {
+			kelvin = t.ColorTempMin
+		}
+		if t.ColorTempMax > 0 && kelvin > t.ColorTempMax {
+			kelvin = t.ColorTempMax
+		}
+		mirek := 1000000 / kelvin
+		cmd.ColorTemp = &mirek
+	}
+
+	return cmd
+}
+
+// ReverseState converts a light or group state for status going back to Loxone
+func ReverseState(state interface{}, t *models.MappingTransform) interface{} {
+	if t == nil {
+		return state
+	}
+
+	switch s := state.(type) {
+	case models.LightState:
+		s.Brightness = ReverseBrightness(s.Brightness, t)
+		return s
+	case models.GroupState:
+		s.Brightness = ReverseBrightness(s.Brightness, t)
+		return s
+	}
+	return state
+}
+
+func clampUnit(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,29 @@
+package metrics
+
+// Gateway metrics, updated by the api and hue packages
+var (
+	HTTPRequests = NewCounterVec("loxone2hue_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "status")
+	HTTPDuration = NewHistogramVec("loxone2hue_http_request_duration_seconds",
+		"HTTP request latency by route and method.", DefBuckets, "route", "method")
+
+	LoxoneCommands = NewCounterVec("loxone2hue_loxone_commands_total",
+		"Loxone commands by action and result (ok or error).", "action", "result")
+
+	HueRequestDuration = NewHistogramVec("loxone2hue_hue_request_duration_seconds",
+		"HUE API request latency by bridge, method and endpoint.", DefBuckets, "bridge", "method", "endpoint")
+	HueRequestErrors = NewCounterVec("loxone2hue_hue_request_errors_total",
+		"Failed HUE API requests by bridge, method and endpoint.", "bridge", "method", "endpoint")
+
+	HueEventStreamConnected = NewGaugeVec("loxone2hue_hue_eventstream_connected",
+		"1 while the HUE event stream of a bridge is connected.", "bridge")
+	HueEvents = NewCounterVec("loxone2hue_hue_events_total",
+		"HUE events received by bridge and resource type.", "bridge", "type")
+	HueEventsDropped = NewCounterVec("loxone2hue_hue_events_dropped_total",
+		"HUE events dropped because the event channel was full.", "bridge")
+
+	WebSocketClients = NewGaugeVec("loxone2hue_websocket_clients",
+		"Connected WebSocket clients by kind (loxone or browser).", "kind")
+	WebSocketSendDropped = NewCounterVec("loxone2hue_websocket_send_dropped_total",
+		"WebSocket clients dropped because their send queue was full.")
+)
@@ -2,42 +2,65 @@ package models
 
 // Light represents a HUE light device
 type Light struct {
-	ID          string      `json:"id"`
-	Name        string      `json:"name"`
-	Type        string      `json:"type"`
-	ModelID     string      `json:"model_id"`
-	ProductName string      `json:"product_name"`
-	State       LightState  `json:"state"`
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Type         string       `json:"type"`
+	ModelID      string       `json:"model_id"`
+	ProductName  string       `json:"product_name"`
+	State        LightState   `json:"state"`
 	Capabilities Capabilities `json:"capabilities,omitempty"`
+	PowerUp      *PowerUp     `json:"powerup,omitempty"`
 }
 
 // LightState represents the current state of a light
 type LightState struct {
-	On         bool    `json:"on"`
-	Brightness float64 `json:"brightness"` // 0-100
-	ColorTemp  int     `json:"color_temp,omitempty"` // Mirek (153-500)
-	Color      *Color  `json:"color,omitempty"`
-	Reachable  bool    `json:"reachable"`
+	On         bool      `json:"on"`
+	Brightness float64   `json:"brightness"`           // 0-100
+	ColorTemp  int       `json:"color_temp,omitempty"` // Mirek (153-500)
+	Color      *Color    `json:"color,omitempty"`
+	Gradient   *Gradient `json:"gradient,omitempty"`
+	Reachable  bool      `json:"reachable"`
 }
 
 // Color represents color in XY color space
 type Color struct {
-	XY      [2]float64 `json:"xy"`
-	Gamut   string     `json:"gamut,omitempty"`
-	HexRGB  string     `json:"hex_rgb,omitempty"`
+	XY     [2]float64 `json:"xy"`
+	Gamut  string     `json:"gamut,omitempty"`
+	HexRGB string     `json:"hex_rgb,omitempty"`
+}
+
+// Gradient represents the color points of a gradient light
+type Gradient struct {
+	Points []Color `json:"points"`
+	Mode   string  `json:"mode,omitempty"` // e.g. "interpolated_palette"
+}
+
+// PowerUp describes how a light behaves when power is restored
+type PowerUp struct {
+	Preset     string   `json:"preset"` // "safety", "powerfail", "last_on_state" or "custom"
+	Configured bool     `json:"configured"`
+	On         *bool    `json:"on,omitempty"`         // custom only, nil keeps the previous state
+	Brightness *float64 `json:"brightness,omitempty"` // custom only, nil keeps the previous brightness
+	ColorTemp  *int     `json:"color_temp,omitempty"` // custom only (Mirek), nil keeps the previous color
 }
 
 // Capabilities describes what a light can do
 type Capabilities struct {
-	SupportsColor     bool `json:"supports_color"`
-	SupportsColorTemp bool `json:"supports_color_temp"`
-	SupportsDimming   bool `json:"supports_dimming"`
+	SupportsColor     bool     `json:"supports_color"`
+	SupportsColorTemp bool     `json:"supports_color_temp"`
+	SupportsDimming   bool     `json:"supports_dimming"`
+	SupportsGradient  bool     `json:"supports_gradient"`
+	GradientPoints    int      `json:"gradient_points_capable,omitempty"`
+	GradientModes     []string `json:"gradient_modes,omitempty"`
 }
 
 // DeviceCommand represents a command to control a device
 type DeviceCommand struct {
-	On         *bool    `json:"on,omitempty"`
-	Brightness *float64 `json:"brightness,omitempty"`
-	ColorTemp  *int     `json:"color_temp,omitempty"`
-	Color      *Color   `json:"color,omitempty"`
+	On           *bool    `json:"on,omitempty"`
+	Brightness   *float64 `json:"brightness,omitempty"`
+	ColorTemp    *int     `json:"color_temp,omitempty"`
+	Color        *Color   `json:"color,omitempty"`
+	Gradient     []Color  `json:"gradient,omitempty"`      // Gradient points, first point at the start of the strip
+	GradientMode string   `json:"gradient_mode,omitempty"` // Optional, keeps the current mode if empty
+	Transition   *int     `json:"transition,omitempty"`    // Optional transition in milliseconds
 }
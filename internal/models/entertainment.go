@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// EntertainmentConfiguration represents a HUE entertainment area
+type EntertainmentConfiguration struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`   // "screen", "music", "3dspace" or "other"
+	Status   string                 `json:"status"` // "active" or "inactive"
+	Channels []EntertainmentChannel `json:"channels"`
+	Lights   []string               `json:"lights"`
+}
+
+// EntertainmentChannel is a single addressable segment of an entertainment area
+type EntertainmentChannel struct {
+	ID       int        `json:"id"`
+	Position [3]float64 `json:"position"` // x, y, z in the range -1..1
+}
+
+// StreamOptions describes the pattern played by an entertainment stream
+type StreamOptions struct {
+	Pattern    string  `json:"pattern"`              // "static", "rainbow", "pulse", "strobe" or "party"
+	Color      string  `json:"color,omitempty"`      // Base color as hex, e.g. "#FF5500"
+	Speed      float64 `json:"speed,omitempty"`      // Pattern cycles per second
+	Brightness float64 `json:"brightness,omitempty"` // 0-100
+}
+
+// StreamStatus describes the currently running entertainment stream
+type StreamStatus struct {
+	Active    bool          `json:"active"`
+	ConfigID  string        `json:"config_id,omitempty"`
+	Options   StreamOptions `json:"options,omitempty"`
+	StartedAt *time.Time    `json:"started_at,omitempty"`
+	Frames    uint64        `json:"frames"`
+}
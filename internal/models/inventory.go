@@ -0,0 +1,20 @@
+package models
+
+// InventoryDevice represents a physical HUE device with its lights,
+// connectivity and firmware state
+type InventoryDevice struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Archetype       string   `json:"archetype"`
+	ModelID         string   `json:"model_id"`
+	Manufacturer    string   `json:"manufacturer"`
+	ProductName     string   `json:"product_name"`
+	Certified       bool     `json:"certified"`
+	SoftwareVersion string   `json:"software_version"`
+	Room            string   `json:"room,omitempty"`
+	Lights          []string `json:"lights"`
+	Connectivity    string   `json:"connectivity,omitempty"` // "connected", "disconnected", "connectivity_issue" or "unidirectional_incoming"
+	MACAddress      string   `json:"mac_address,omitempty"`
+	UpdateState     string   `json:"update_state,omitempty"` // "no_update", "update_pending", "ready_to_install" or "installing"
+	UpdateProblems  []string `json:"update_problems,omitempty"`
+}
@@ -2,13 +2,55 @@ package models
 
 // Mapping represents a mapping between Loxone and HUE resources
 type Mapping struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	LoxoneID    string `json:"loxone_id"`    // Loxone UUID or custom ID
-	HueID       string `json:"hue_id"`       // HUE resource ID
-	HueType     string `json:"hue_type"`     // "light", "group", "scene"
-	Enabled     bool   `json:"enabled"`
-	Description string `json:"description,omitempty"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	LoxoneID    string            `json:"loxone_id"`           // Loxone UUID or custom ID
+	HueID       string            `json:"hue_id"`              // HUE resource ID
+	HueType     string            `json:"hue_type"`            // "light", "group", "scene", "entertainment", "virtual_group"
+	Bridge      string            `json:"bridge,omitempty"`    // Additional bridge ID, empty for the primary bridge
+	Members     []MappingMember   `json:"members,omitempty"`   // Members of a virtual group
+	Overrides   *MappingOverrides `json:"overrides,omitempty"` // Applied to commands passing through this mapping
+	Transform   *MappingTransform `json:"transform,omitempty"` // Value conversion between Loxone and HUE
+	Direction   string            `json:"direction,omitempty"` // "to_hue", "to_loxone" or "both" (default)
+	Enabled     bool              `json:"enabled"`
+	Description string            `json:"description,omitempty"`
+}
+
+// ValidHueType reports whether a mapping can point to a HUE type
+func ValidHueType(hueType string) bool {
+	switch hueType {
+	case "light", "group", "scene", "entertainment", "virtual_group":
+		return true
+	}
+	return false
+}
+
+// MappingMember is a light or group that belongs to a virtual group
+type MappingMember struct {
+	HueID   string `json:"hue_id"`
+	HueType string `json:"hue_type"` // "light" or "group"
+	Bridge  string `json:"bridge,omitempty"`
+}
+
+// MappingOverrides are per-mapping parameters applied to commands
+type MappingOverrides struct {
+	BrightnessScale *float64 `json:"brightness_scale,omitempty"` // Factor for the requested brightness, e.g. 0.5
+	Brightness      *float64 `json:"brightness,omitempty"`       // Fixed brightness 0-100, wins over brightness_scale
+	ColorTemp       *int     `json:"color_temp,omitempty"`       // Fixed color temperature in Kelvin
+}
+
+// MappingTransform converts Loxone values to HUE values and back.
+// Zero values fall back to the defaults (0-100 ranges, linear curve).
+type MappingTransform struct {
+	InputMin        float64 `json:"input_min,omitempty"` // Loxone value range, e.g. 0-10 or 0-255
+	InputMax        float64 `json:"input_max,omitempty"`
+	OutputMin       float64 `json:"output_min,omitempty"` // HUE brightness range in percent
+	OutputMax       float64 `json:"output_max,omitempty"`
+	Invert          bool    `json:"invert,omitempty"`
+	Gamma           float64 `json:"gamma,omitempty"`             // Dimming curve exponent, 1 = linear, >1 for perceived brightness
+	MinOnBrightness float64 `json:"min_on_brightness,omitempty"` // Lowest brightness in percent while on
+	ColorTempMin    int     `json:"color_temp_min,omitempty"`    // Kelvin
+	ColorTempMax    int     `json:"color_temp_max,omitempty"`    // Kelvin
 }
 
 // LoxoneCommand represents an incoming command from Loxone
@@ -21,9 +63,9 @@ type LoxoneCommand struct {
 
 // LoxoneStatus represents a status update sent to Loxone
 type LoxoneStatus struct {
-	Type    string      `json:"type"` // "status"
-	Device  string      `json:"device"`
-	State   interface{} `json:"state"`
+	Type   string      `json:"type"` // "status"
+	Device string      `json:"device"`
+	State  interface{} `json:"state"`
 }
 
 // WebSocketMessage is a generic WebSocket message wrapper
@@ -0,0 +1,33 @@
+package models
+
+// Special mood numbers, following the LightControllerV2 of Loxone
+const (
+	MoodOff    = 0   // Off, faded with the table's off transition
+	MoodAllOn  = 777 // "Viel Licht": all lights of the target on at full brightness
+	MoodAllOff = 778 // "Aus" of the lighting controller, the same as MoodOff
+)
+
+// IsMoodOff reports whether a mood number switches the target off
+func IsMoodOff(mood int) bool {
+	return mood == MoodOff || mood == MoodAllOff
+}
+
+// MoodTable maps the mood numbers of a Loxone lighting controller to HUE scenes
+type MoodTable struct {
+	Target        string      `json:"target"` // Loxone ID used in MOOD commands
+	Name          string      `json:"name,omitempty"`
+	HueID         string      `json:"hue_id,omitempty"`         // Room, zone or light switched by moods 0, 777 and 778
+	HueType       string      `json:"hue_type,omitempty"`       // "group" or "light"
+	Bridge        string      `json:"bridge,omitempty"`         // Bridge of the target and the scenes, empty for the primary bridge
+	OffTransition int         `json:"off_transition,omitempty"` // Fade duration of mood 0 in milliseconds
+	Moods         []MoodEntry `json:"moods"`
+}
+
+// MoodEntry is a single mood of a mood table
+type MoodEntry struct {
+	Number     int      `json:"number"`
+	Name       string   `json:"name,omitempty"`
+	SceneID    string   `json:"scene_id,omitempty"`   // HUE scene, without scene only the brightness is set
+	Brightness *float64 `json:"brightness,omitempty"` // Optional brightness 0-100 for the scene
+	Transition int      `json:"transition,omitempty"` // Optional transition in milliseconds
+}
@@ -0,0 +1,434 @@
+// Package stats persists light state transitions and computes daily on-time
+// and brightness per light
+package stats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultRetentionDays is used when no retention is configured
+const DefaultRetentionDays = 365
+
+// pendingLimit is the number of transitions waiting to be written, events
+// beyond it are dropped instead of blocking the event stream
+const pendingLimit = 1024
+
+// brightnessTolerance is the brightness change still treated as the same
+// state, the bridge reports small steps while dimming
+const brightnessTolerance = 0.5
+
+var transitionsBucket = []byte("transitions")
+
+// State is the on state and brightness of a light from a point in time on
+type State struct {
+	On         bool    `json:"on"`
+	Brightness float64 `json:"bri"`
+}
+
+// Day holds the usage of one light or room on one day
+type Day struct {
+	Date          string  `json:"date,omitempty"`
+	OnHours       float64 `json:"on_hours"`
+	AvgBrightness float64 `json:"avg_brightness"`      // Weighted by on-time
+	EnergyWh      float64 `json:"energy_wh,omitempty"` // Only with a configured wattage
+	onSeconds     float64
+	briSeconds    float64
+}
+
+// Store records light transitions in a bbolt database
+type Store struct {
+	db        *bolt.DB
+	retention time.Duration
+	mu        sync.Mutex
+	last      map[string]State
+	closed    bool
+	pruned    time.Time // Only used by the writer after Open
+
+	pending chan pendingState // Transitions waiting to be written
+	done    chan struct{}     // Closed when the writer has finished
+}
+
+// pendingState is a recorded state that is not yet written
+type pendingState struct {
+	key   string
+	state State
+	at    time.Time
+}
+
+// Open opens or creates the database at path
+func Open(path string, retentionDays int) (*Store, error) {
+	if retentionDays <= 0 {
+		retentionDays = DefaultRetentionDays
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stats database: %w", err)
+	}
+
+	s := &Store{
+		db:        db,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		last:      make(map[string]State),
+		pending:   make(chan pendingState, pendingLimit),
+		done:      make(chan struct{}),
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(transitionsBucket)
+		if err != nil {
+			return err
+		}
+		return root.ForEachBucket(func(key []byte) error {
+			_, value := root.Bucket(key).Cursor().Last()
+			var state State
+			if value != nil && json.Unmarshal(value, &state) == nil {
+				s.last[string(key)] = state
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize stats database: %w", err)
+	}
+
+	s.Prune(time.Now())
+	go s.writeLoop()
+	return s, nil
+}
+
+// Key identifies a light, lights of the primary bridge use their ID
+func Key(bridge, lightID string) string {
+	if bridge == "" {
+		return lightID
+	}
+	return bridge + "/" + lightID
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
+
+// changed reports whether a state differs from the previous one
+func changed(prev, next State) bool {
+	if prev.On != next.On {
+		return true
+	}
+	return next.On && math.Abs(prev.Brightness-next.Brightness) >= brightnessTolerance
+}
+
+// Record queues the state of a light if it changed. It does not wait for
+// the database, so it can be called for every event.
+func (s *Store) Record(key string, state State, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	if prev, ok := s.last[key]; ok && !changed(prev, state) {
+		return
+	}
+
+	select {
+	case s.pending <- pendingState{key: key, state: state, at: at}:
+		s.last[key] = state
+	default:
+		log.Warn().Str("light", key).Msg("Too many pending light states, state dropped")
+	}
+}
+
+// writeLoop writes the queued states until the store is closed. States
+// queued while a write is running are written in one transaction.
+func (s *Store) writeLoop() {
+	defer close(s.done)
+
+	for first := range s.pending {
+		batch := []pendingState{first}
+	collect:
+		for len(batch) < pendingLimit {
+			select {
+			case next, ok := <-s.pending:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+		s.write(batch)
+	}
+}
+
+// write stores a batch of states and prunes old transitions once a day
+func (s *Store) write(batch []pendingState) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(transitionsBucket)
+		for _, p := range batch {
+			value, err := json.Marshal(p.state)
+			if err != nil {
+				return err
+			}
+			bucket, err := root.CreateBucketIfNotExists([]byte(p.key))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(timeKey(p.at), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Int("states", len(batch)).Msg("Failed to record light states")
+	}
+
+	if at := batch[len(batch)-1].at; at.Sub(s.pruned) > 24*time.Hour {
+		s.Prune(at)
+	}
+}
+
+// Keys returns the recorded lights
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.last))
+	for key := range s.last {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Prune deletes transitions older than the retention, the last one before
+// the cutoff is kept as the state at the start of the window
+func (s *Store) Prune(now time.Time) {
+	cutoff := timeKey(now.Add(-s.retention))
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(transitionsBucket)
+		return root.ForEachBucket(func(name []byte) error {
+			bucket := root.Bucket(name)
+			c := bucket.Cursor()
+
+			// Keep the last transition before the cutoff as the baseline
+			var old [][]byte
+			for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+				old = append(old, append([]byte(nil), k...))
+			}
+			if len(old) < 2 {
+				return nil
+			}
+			for _, k := range old[:len(old)-1] {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to prune light statistics")
+	}
+	s.pruned = now
+}
+
+// transition is a recorded state change of one light
+type transition struct {
+	at    time.Time
+	light int
+	state State
+}
+
+// transitions reads the changes of a light between from and to, starting
+// with the state valid at from
+func (s *Store) transitions(tx *bolt.Tx, key string, light int, from, to time.Time) []transition {
+	result := make([]transition, 0)
+	bucket := tx.Bucket(transitionsBucket).Bucket([]byte(key))
+	if bucket == nil {
+		return result
+	}
+
+	c := bucket.Cursor()
+	k, v := c.Seek(timeKey(from))
+	if k == nil {
+		k, v = c.Last()
+	} else if pk, pv := c.Prev(); pk != nil {
+		k, v = pk, pv
+	} else {
+		k, v = c.First()
+	}
+	for ; k != nil; k, v = c.Next() {
+		at := keyTime(k)
+		if !at.Before(to) {
+			break
+		}
+		var state State
+		if err := json.Unmarshal(v, &state); err != nil {
+			continue
+		}
+		result = append(result, transition{at: at, light: light, state: state})
+	}
+	return result
+}
+
+// Daily computes the on-time and average brightness for every day between
+// from and to (exclusive) in the location of from. With several lights, as
+// for a room, the group counts as on while any light is on and the
+// brightness is the mean of the lights that are on.
+func (s *Store) Daily(keys []string, from, to time.Time) ([]Day, error) {
+	if now := time.Now(); to.After(now) {
+		to = now
+	}
+
+	days := make([]Day, 0)
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, Day{Date: d.Format("2006-01-02")})
+	}
+	if len(days) == 0 {
+		return days, nil
+	}
+
+	var changes []transition
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for i, key := range keys {
+			changes = append(changes, s.transitions(tx, key, i, from, to)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].at.Before(changes[j].at) })
+
+	states := make([]State, len(keys))
+	for i, change := range changes {
+		states[change.light] = change.state
+
+		on, brightness := combine(states)
+		if !on {
+			continue
+		}
+		start, end := change.at, to
+		if i+1 < len(changes) {
+			end = changes[i+1].at
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		// Split the interval at day boundaries
+		for day := dayIndex(from, start); start.Before(end) && day < len(days); day++ {
+			segmentEnd := from.AddDate(0, 0, day+1)
+			if end.Before(segmentEnd) {
+				segmentEnd = end
+			}
+			seconds := segmentEnd.Sub(start).Seconds()
+			days[day].onSeconds += seconds
+			days[day].briSeconds += seconds * brightness
+			start = segmentEnd
+		}
+	}
+
+	for i := range days {
+		days[i].finish()
+	}
+	return days, nil
+}
+
+// combine returns whether any light is on and the mean brightness of the
+// lights that are on
+func combine(states []State) (bool, float64) {
+	count, sum := 0, 0.0
+	for _, state := range states {
+		if state.On {
+			count++
+			sum += state.Brightness
+		}
+	}
+	if count == 0 {
+		return false, 0
+	}
+	return true, sum / float64(count)
+}
+
+// dayIndex returns the day of t counted from the start day from
+func dayIndex(from, t time.Time) int {
+	day := 0
+	for next := from.AddDate(0, 0, 1); !t.Before(next); next = from.AddDate(0, 0, day+1) {
+		day++
+	}
+	return day
+}
+
+// finish derives the reported values from the accumulated seconds
+func (d *Day) finish() {
+	d.OnHours = round(d.onSeconds/3600, 3)
+	if d.onSeconds > 0 {
+		d.AvgBrightness = round(d.briSeconds/d.onSeconds, 1)
+	}
+}
+
+// Energy estimates the consumption in Wh for a lamp of the given wattage,
+// assuming the power scales with the brightness
+func (d *Day) Energy(watts float64) {
+	d.EnergyWh = round(watts*d.briSeconds/100/3600, 2)
+}
+
+// AddEnergy adds consumption in Wh, used to sum the lights of a room
+func (d *Day) AddEnergy(wh float64) {
+	d.EnergyWh = round(d.EnergyWh+wh, 2)
+}
+
+// Total sums days, the average brightness stays weighted by on-time
+func Total(days []Day) Day {
+	var total Day
+	for _, d := range days {
+		total.onSeconds += d.onSeconds
+		total.briSeconds += d.briSeconds
+		total.EnergyWh += d.EnergyWh
+	}
+	total.finish()
+	total.EnergyWh = round(total.EnergyWh, 2)
+	return total
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+// Close writes the pending states and closes the database
+func (s *Store) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.pending)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return s.db.Close()
+}
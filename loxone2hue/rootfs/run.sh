@@ -51,7 +51,8 @@ mkdir -p /config/loxone2hue
 
 bashio::log.info "Starting Loxone2HUE Gateway..."
 bashio::log.info "Web UI available at: $(bashio::addon.ingress_url)"
+bashio::log.info "Loxone endpoint available on port 8081 (API token required once created)"
 
 # Change to app directory and start gateway
 cd /app
-exec ./gateway -config ${APP_CONFIG_PATH}
+exec ./gateway -config ${APP_CONFIG_PATH} -ingress -loxone-port 8081
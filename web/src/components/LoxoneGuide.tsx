@@ -1,6 +1,19 @@
+import { useState } from 'react';
+import { BASE_PATH, getToken } from '../services/api';
 import { BookOpen, Settings, Code, Link2, Terminal, Lightbulb, Home, Play, CheckCircle2, AlertTriangle, Zap, HelpCircle, Layers } from 'lucide-react';
 
 export function LoxoneGuide() {
+  const [loxoneToken, setLoxoneToken] = useState('');
+
+  // The browser token authorizes the download, loxone_token ends up in the template
+  const templateURL = (path: string) => {
+    const params = new URLSearchParams();
+    if (getToken()) params.set('token', getToken());
+    if (loxoneToken) params.set('loxone_token', loxoneToken);
+    const query = params.toString();
+    return `${BASE_PATH}${path}${query ? `?${query}` : ''}`;
+  };
+
   return (
     <div className="space-y-8 max-w-4xl">
       {/* Übersicht */}
@@ -198,6 +211,33 @@ export function LoxoneGuide() {
           Schritt 2: Loxone Miniserver konfigurieren
         </h3>
         <div className="space-y-6 text-gray-300">
+          <div className="bg-gray-900 rounded-lg p-4">
+            <h4 className="font-medium text-hue-orange mb-2">Schnellstart: Vorlagen importieren</h4>
+            <p className="text-sm mb-3">
+              Der Gateway erzeugt fertige Vorlagen mit allen Befehlen deiner Mappings. In Loxone Config
+              unter <strong>Virtuelle Ausgänge</strong> bzw. <strong>Virtuelle Eingänge</strong> über
+              <strong> Vorlage importieren</strong> einlesen. Die Schritte 2.1 und 2.2 entfallen dann.
+            </p>
+            <label className="block text-sm mb-3">
+              <span className="text-gray-400">API-Token für Loxone (nur bei aktiver Authentifizierung, Rolle control)</span>
+              <input
+                type="text"
+                value={loxoneToken}
+                onChange={(e) => setLoxoneToken(e.target.value.trim())}
+                placeholder="l2h_..."
+                className="mt-1 w-full bg-gray-800 rounded px-3 py-2 text-white font-mono"
+              />
+            </label>
+            <div className="flex flex-wrap gap-3 text-sm">
+              <a href={templateURL('/api/loxone/templates/outputs.xml')} className="bg-gray-800 px-3 py-2 rounded text-hue-orange hover:bg-gray-700">
+                Virtuelle Ausgänge (outputs.xml)
+              </a>
+              <a href={templateURL('/api/loxone/templates/inputs.xml')} className="bg-gray-800 px-3 py-2 rounded text-hue-orange hover:bg-gray-700">
+                Virtuelle HTTP Eingänge (inputs.xml)
+              </a>
+            </div>
+          </div>
+
           <div>
             <h4 className="font-medium text-white mb-3">2.1 Virtuellen Ausgang erstellen</h4>
             <ol className="list-decimal list-inside space-y-2 text-sm ml-4">
@@ -369,6 +409,11 @@ export function LoxoneGuide() {
                 <td className="py-3 pr-4">Farbe setzen (RGB Hex)</td>
                 <td className="py-3 font-mono text-xs">SET wz_decke COLOR #FF5500</td>
               </tr>
+              <tr className="border-b border-gray-700/50">
+                <td className="py-3 pr-4 font-mono text-hue-orange">SET &lt;id&gt; GRADIENT &lt;hex,hex,...&gt;</td>
+                <td className="py-3 pr-4">Farbverlauf setzen (Gradient-Lampen)</td>
+                <td className="py-3 font-mono text-xs">SET wz_strip GRADIENT #FF0000,#0000FF</td>
+              </tr>
               <tr className="border-b border-gray-700/50">
                 <td className="py-3 pr-4 font-mono text-hue-orange">SCENE &lt;id&gt;</td>
                 <td className="py-3 pr-4">Szene aktivieren</td>
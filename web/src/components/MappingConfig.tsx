@@ -283,7 +283,7 @@ export function MappingConfig({ lights, groups, scenes }: MappingConfigProps) {
   );
 
   const getBaseUrl = () => {
-    return `${window.location.protocol}//${window.location.host}`;
+    return `${window.location.protocol}//${window.location.host}${api.BASE_PATH}`;
   };
 
   const getTestUrls = (mapping: Mapping) => {
@@ -1,5 +1,6 @@
 import { useEffect, useRef, useState, useCallback } from 'react';
 import { StatusMessage } from '../types';
+import { BASE_PATH, getToken } from '../services/api';
 
 interface UseWebSocketOptions {
   onMessage?: (message: StatusMessage) => void;
@@ -14,7 +15,8 @@ export function useWebSocket(options: UseWebSocketOptions = {}) {
 
   const connect = useCallback(() => {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws`;
+    const token = getToken();
+    const wsUrl = `${protocol}//${window.location.host}${BASE_PATH}/ws${token ? `?token=${encodeURIComponent(token)}` : ''}`;
 
     const ws = new WebSocket(wsUrl);
     wsRef.current = ws;
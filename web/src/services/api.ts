@@ -1,18 +1,56 @@
-import { Light, Group, Scene, Mapping, BridgeInfo, DeviceCommand } from '../types';
+import { Light, Group, Scene, Mapping, MappingConflict, MoodTable, BridgeInfo, DeviceCommand } from '../types';
 
-const API_BASE = '/api';
+declare global {
+  interface Window {
+    __INGRESS_PATH__?: string;
+  }
+}
+
+// Path prefix when the UI is served through Home Assistant ingress
+export const BASE_PATH = window.__INGRESS_PATH__ || '';
+
+const API_BASE = `${BASE_PATH}/api`;
+
+const TOKEN_KEY = 'loxone2hue_token';
+
+// API token of this browser, only needed when authentication is active
+export function getToken(): string {
+  return localStorage.getItem(TOKEN_KEY) || '';
+}
 
-async function fetchJSON<T>(url: string, options?: RequestInit): Promise<T> {
+export function setToken(token: string) {
+  if (token) {
+    localStorage.setItem(TOKEN_KEY, token);
+  } else {
+    localStorage.removeItem(TOKEN_KEY);
+  }
+}
+
+async function fetchJSON<T>(url: string, options?: RequestInit, retry = true): Promise<T> {
+  const token = getToken();
   const response = await fetch(url, {
     ...options,
     headers: {
       'Content-Type': 'application/json',
+      ...(token ? { Authorization: `Bearer ${token}` } : {}),
       ...options?.headers,
     },
   });
 
+  if (response.status === 401 && retry) {
+    const entered = window.prompt('API-Token eingeben');
+    if (entered) {
+      setToken(entered.trim());
+      return fetchJSON(url, options, false);
+    }
+  }
+
   if (!response.ok) {
     const error = await response.json().catch(() => ({ error: response.statusText }));
+    if (error.fields) {
+      const details = error.fields.map((f: { field: string; message: string }) => `${f.field}: ${f.message}`);
+      throw new Error(`${error.error}: ${details.join(', ')}`);
+    }
     throw new Error(error.error || 'API request failed');
   }
 
@@ -75,7 +113,7 @@ export async function activateScene(id: string): Promise<void> {
 }
 
 // Mapping endpoints
-export async function getMappings(): Promise<{ mappings: Mapping[] }> {
+export async function getMappings(): Promise<{ mappings: Mapping[]; conflicts?: MappingConflict[] }> {
   return fetchJSON(`${API_BASE}/mappings`);
 }
 
@@ -112,6 +150,151 @@ export interface ImportResult {
   updated: number;
   skipped: number;
   total: number;
+  conflicts?: MappingConflict[];
+  invalid?: MappingIssue[];
+}
+
+export interface FieldError {
+  field: string;
+  code: string;
+  message: string;
+}
+
+export interface MappingIssue {
+  mapping_id: string;
+  loxone_id: string;
+  name?: string;
+  errors: FieldError[];
+}
+
+export interface ValidationReport {
+  total: number;
+  valid: number;
+  orphaned: MappingIssue[];
+  duplicates: MappingConflict[];
+  invalid: MappingIssue[];
+  unverified?: string[];
+}
+
+export interface GenerateRequest {
+  dry_run?: boolean;
+  bridge?: string;
+  groups?: boolean;
+  lights?: boolean;
+  scenes?: boolean;
+}
+
+export interface GenerateResult {
+  dry_run: boolean;
+  mappings: Mapping[];
+  imported?: number;
+  updated?: number;
+  total: number;
+  conflicts?: MappingConflict[];
+}
+
+// Generate mappings from HUE rooms, lights and scenes
+export async function generateMappings(req: GenerateRequest): Promise<GenerateResult> {
+  return fetchJSON(`${API_BASE}/mappings/generate`, {
+    method: 'POST',
+    body: JSON.stringify(req),
+  });
+}
+
+export interface LoxoneControl {
+  uuid: string;
+  name: string;
+  type: 'LightControllerV2' | 'Dimmer' | 'ColorPickerV2';
+  room?: string;
+  category?: string;
+  parent?: string;
+  states?: Record<string, string>;
+}
+
+export interface MiniserverStatus {
+  enabled: boolean;
+  host?: string;
+  connected?: boolean;
+  connected_at?: string;
+  miniserver?: string;
+  controls?: number;
+  last_error?: string;
+}
+
+// Status of the direct Miniserver WebSocket connection
+export async function getMiniserverStatus(): Promise<MiniserverStatus> {
+  return fetchJSON(`${API_BASE}/loxone/miniserver`);
+}
+
+export interface LoxoneStructure {
+  miniserver_name: string;
+  project_name: string;
+  last_modified?: string;
+  rooms: string[];
+  categories: string[];
+  controls: LoxoneControl[];
+}
+
+export interface StructureMatch {
+  control: LoxoneControl;
+  mapping: Mapping;
+  score: number;
+}
+
+// Upload a LoxAPP3.json structure file
+export async function uploadStructure(file: File): Promise<LoxoneStructure> {
+  return fetchJSON(`${API_BASE}/loxone/structure`, {
+    method: 'POST',
+    body: await file.text(),
+  });
+}
+
+// Fetch the structure file from the configured Miniserver
+export async function fetchStructure(): Promise<LoxoneStructure> {
+  return fetchJSON(`${API_BASE}/loxone/structure/fetch`, { method: 'POST' });
+}
+
+// Propose mappings for the structure by name matching
+export async function matchStructure(req: {
+  dry_run?: boolean;
+  min_score?: number;
+  bridge?: string;
+}): Promise<{ dry_run: boolean; matches: StructureMatch[]; imported?: number; total: number }> {
+  return fetchJSON(`${API_BASE}/loxone/structure/match`, {
+    method: 'POST',
+    body: JSON.stringify(req),
+  });
+}
+
+// Mood tables
+export async function getMoodTables(): Promise<MoodTable[]> {
+  return fetchJSON(`${API_BASE}/moods`);
+}
+
+export async function saveMoodTable(table: MoodTable): Promise<MoodTable> {
+  return fetchJSON(`${API_BASE}/moods/${encodeURIComponent(table.target)}`, {
+    method: 'PUT',
+    body: JSON.stringify(table),
+  });
+}
+
+export async function deleteMoodTable(target: string): Promise<void> {
+  await fetchJSON(`${API_BASE}/moods/${encodeURIComponent(target)}`, { method: 'DELETE' });
+}
+
+// Convert <target>_mood_<n> mappings into mood tables
+export async function migrateMoodMappings(
+  dryRun = false
+): Promise<{ dry_run: boolean; migrated: number; mood_tables: MoodTable[] }> {
+  return fetchJSON(`${API_BASE}/moods/migrate`, {
+    method: 'POST',
+    body: JSON.stringify({ dry_run: dryRun }),
+  });
+}
+
+// Validate all mappings against the bridges
+export async function validateMappings(): Promise<ValidationReport> {
+  return fetchJSON(`${API_BASE}/mappings/validate`);
 }
 
 // Export mappings - triggers file download
@@ -139,11 +322,16 @@ export interface ConfigResponse {
   hue: {
     bridge_ip: string;
     configured: boolean;
+    has_client_key: boolean;
   };
   loxone: {
     host: string;
     port: number;
   };
+  auth?: {
+    enabled: boolean;
+    allowed_origins?: string[];
+  };
   logging: {
     level: string;
   };
@@ -153,11 +341,141 @@ export async function getConfig(): Promise<ConfigResponse> {
   return fetchJSON(`${API_BASE}/config`);
 }
 
+// Config versions
+export interface ConfigVersion {
+  id: string;
+  created_at: string;
+  size: number;
+  mappings: number;
+}
+
+export async function getConfigVersions(): Promise<{ versions: ConfigVersion[]; count: number }> {
+  return fetchJSON(`${API_BASE}/config/versions`);
+}
+
+export async function restoreConfigVersion(id: string): Promise<{
+  status: string;
+  version: string;
+  mappings: number;
+  conflicts: MappingConflict[];
+}> {
+  return fetchJSON(`${API_BASE}/config/versions/${encodeURIComponent(id)}/restore`, {
+    method: 'POST',
+  });
+}
+
 // Health check
 export async function getHealth(): Promise<{ status: string; hue_configured: boolean }> {
   return fetchJSON(`${API_BASE}/health`);
 }
 
+// Audit log
+export interface HistoryEntry {
+  id: number;
+  time: string;
+  kind: 'command' | 'state';
+  source: 'ws' | 'http' | 'miniserver' | 'hue';
+  client?: string;
+  remote_addr?: string;
+  raw?: string;
+  target?: string;
+  action?: string;
+  params?: Record<string, unknown>;
+  bridge?: string;
+  hue_id?: string;
+  hue_type?: string;
+  result?: 'ok' | 'error';
+  error?: string;
+  latency_ms?: number;
+  state?: unknown;
+}
+
+export async function getHistory(filter: {
+  target?: string;
+  source?: string;
+  kind?: string;
+  since?: string;
+  until?: string;
+  limit?: number;
+} = {}): Promise<{ entries: HistoryEntry[]; count: number }> {
+  const params = new URLSearchParams();
+  Object.entries(filter).forEach(([key, value]) => {
+    if (value !== undefined && value !== '') params.set(key, String(value));
+  });
+  const query = params.toString();
+  return fetchJSON(`${API_BASE}/history${query ? `?${query}` : ''}`);
+}
+
+// Usage statistics
+export interface StatsDay {
+  date?: string;
+  on_hours: number;
+  avg_brightness: number;
+  energy_wh?: number;
+}
+
+export interface LightStats {
+  id: string;
+  bridge?: string;
+  name?: string;
+  model_id?: string;
+  room?: string;
+  watts?: number;
+  total: StatsDay;
+  days: StatsDay[];
+}
+
+export interface RoomStats {
+  id: string;
+  bridge?: string;
+  name: string;
+  lights: string[];
+  total: StatsDay;
+  days: StatsDay[];
+}
+
+export async function getStats(range: { days?: number; from?: string; to?: string } = {}): Promise<{
+  from: string;
+  to: string;
+  lights: LightStats[];
+  rooms: RoomStats[];
+}> {
+  const params = new URLSearchParams();
+  Object.entries(range).forEach(([key, value]) => {
+    if (value !== undefined && value !== '') params.set(key, String(value));
+  });
+  const query = params.toString();
+  return fetchJSON(`${API_BASE}/stats${query ? `?${query}` : ''}`);
+}
+
+// API tokens
+export interface APIToken {
+  id: string;
+  name: string;
+  role: 'read' | 'control' | 'admin';
+  prefix: string;
+  created_at: string;
+}
+
+export async function getAuthStatus(): Promise<{ enabled: boolean; active: boolean; token?: APIToken }> {
+  return fetchJSON(`${API_BASE}/auth`);
+}
+
+export async function getTokens(): Promise<APIToken[]> {
+  return fetchJSON(`${API_BASE}/auth/tokens`);
+}
+
+export async function createToken(name: string, role: APIToken['role']): Promise<{ token: string; details: APIToken }> {
+  return fetchJSON(`${API_BASE}/auth/tokens`, {
+    method: 'POST',
+    body: JSON.stringify({ name, role }),
+  });
+}
+
+export async function deleteToken(id: string): Promise<void> {
+  await fetchJSON(`${API_BASE}/auth/tokens/${id}`, { method: 'DELETE' });
+}
+
 // Bridge connection test
 export interface BridgeTestResult {
   bridge_ip: string;
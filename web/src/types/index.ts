@@ -6,6 +6,15 @@ export interface Light {
   product_name: string;
   state: LightState;
   capabilities: Capabilities;
+  powerup?: PowerUp;
+}
+
+export interface PowerUp {
+  preset: 'safety' | 'powerfail' | 'last_on_state' | 'custom';
+  configured: boolean;
+  on?: boolean;
+  brightness?: number;
+  color_temp?: number;
 }
 
 export interface LightState {
@@ -13,6 +22,7 @@ export interface LightState {
   brightness: number;
   color_temp?: number;
   color?: Color;
+  gradient?: Gradient;
   reachable: boolean;
 }
 
@@ -22,16 +32,25 @@ export interface Color {
   hex_rgb?: string;
 }
 
+export interface Gradient {
+  points: Color[];
+  mode?: string;
+}
+
 export interface Capabilities {
   supports_color: boolean;
   supports_color_temp: boolean;
   supports_dimming: boolean;
+  supports_gradient: boolean;
+  gradient_points_capable?: number;
+  gradient_modes?: string[];
 }
 
 export interface Group {
   id: string;
   name: string;
   type: string;
+  archetype?: string;
   lights: string[];
   state: GroupState;
   scenes?: Scene[];
@@ -56,10 +75,64 @@ export interface Mapping {
   loxone_id: string;
   hue_id: string;
   hue_type: string;
+  bridge?: string;
+  members?: MappingMember[];
+  overrides?: MappingOverrides;
+  transform?: MappingTransform;
+  direction?: 'to_hue' | 'to_loxone' | 'both';
   enabled: boolean;
   description?: string;
 }
 
+export interface MoodEntry {
+  number: number;
+  name?: string;
+  scene_id?: string;
+  brightness?: number;
+  transition?: number;
+}
+
+export interface MoodTable {
+  target: string;
+  name?: string;
+  hue_id?: string;
+  hue_type?: 'group' | 'light';
+  bridge?: string;
+  off_transition?: number;
+  moods: MoodEntry[];
+}
+
+export interface MappingOverrides {
+  brightness_scale?: number;
+  brightness?: number;
+  color_temp?: number;
+}
+
+export interface MappingTransform {
+  input_min?: number;
+  input_max?: number;
+  output_min?: number;
+  output_max?: number;
+  invert?: boolean;
+  gamma?: number;
+  min_on_brightness?: number;
+  color_temp_min?: number;
+  color_temp_max?: number;
+}
+
+export interface MappingConflict {
+  mapping_id: string;
+  loxone_id: string;
+  conflicts_with?: string;
+  reason: string;
+}
+
+export interface MappingMember {
+  hue_id: string;
+  hue_type: 'light' | 'group';
+  bridge?: string;
+}
+
 export interface BridgeInfo {
   id: string;
   ip: string;
@@ -71,6 +144,8 @@ export interface DeviceCommand {
   brightness?: number;
   color_temp?: number;
   color?: Color;
+  gradient?: Color[];
+  gradient_mode?: string;
 }
 
 export interface WebSocketMessage {